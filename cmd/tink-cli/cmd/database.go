@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/cmd/tink-cli/cmd/database"
+)
+
+func NewDatabaseCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "database",
+		Short:   "tink database client",
+		Example: "tink database [command]",
+		Args: func(c *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("%v requires arguments", c.UseLine())
+			}
+			return nil
+		},
+		// The database commands connect to Postgres, not to tink server.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+	}
+
+	cmd.AddCommand(database.NewMigrationsCommand())
+	return cmd
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"text/tabwriter"
+	"time"
+
+	plog "github.com/packethost/pkg/log"
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/db"
+)
+
+type migrationsOptions struct {
+	database string
+	user     string
+	password string
+	sslMode  string
+	check    bool
+}
+
+// NewMigrationsCommand returns the command that reports the database migrations.
+func NewMigrationsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrations",
+		Short: "inspect the database migrations",
+		Args: func(c *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("%v requires arguments", c.UseLine())
+			}
+			return nil
+		},
+	}
+	cmd.AddCommand(newMigrationsStatusCommand())
+	return cmd
+}
+
+func newMigrationsStatusCommand() *cobra.Command {
+	opts := migrationsOptions{}
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "show the applied and pending database migrations",
+		Long: `The status command lists the migrations of this version of tink and whether
+they are applied to the database. The host and port of the database are read
+from the PGHOST and PGPORT environment variables. With --check it exits with a
+non-zero status when migrations are pending:
+$ tink database migrations status
+$ tink database migrations status --check
+`,
+		Run: func(c *cobra.Command, args []string) {
+			connInfo := fmt.Sprintf("dbname=%s user=%s password=%s sslmode=%s",
+				opts.database,
+				opts.user,
+				opts.password,
+				opts.sslMode,
+			)
+			dbCon, err := sql.Open("postgres", connInfo)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer dbCon.Close()
+			logger, err := plog.Init("github.com/tinkerbell/tink")
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer logger.Close()
+
+			if err := migrationsStatus(db.Connect(dbCon, logger), opts.check, c.OutOrStdout()); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&opts.database, "postgres-database", "tinkerbell", "the Postgres database name")
+	flags.StringVar(&opts.user, "postgres-user", "tinkerbell", "the Postgres database username")
+	flags.StringVar(&opts.password, "postgres-password", "tinkerbell", "the Postgres database password")
+	flags.StringVar(&opts.sslMode, "postgres-sslmode", "disable", "enable or disable SSL mode in Postgres")
+	flags.BoolVar(&opts.check, "check", false, "exit with a non-zero status when migrations are pending")
+	return cmd
+}
+
+// migrator is the subset of db.TinkDB reporting the migrations.
+type migrator interface {
+	CheckRequiredMigrations() (int, error)
+	MigrationStatus() ([]db.MigrationState, error)
+}
+
+// migrationsStatus writes the state of every migration and a summary to out.
+// With check, an error is returned when migrations are pending, as counted by
+// tink server on startup.
+func migrationsStatus(m migrator, check bool, out io.Writer) error {
+	states, err := m.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	applied := 0
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tAPPLIED AT")
+	for _, s := range states {
+		status, appliedAt := "pending", ""
+		if s.Applied {
+			status, appliedAt = "applied", s.AppliedAt.UTC().Format(time.RFC3339)
+			applied++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.ID, status, appliedAt)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%d applied, %d pending\n", applied, len(states)-applied)
+
+	if !check {
+		return nil
+	}
+	pending, err := m.CheckRequiredMigrations()
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d migrations are pending", pending)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/tinkerbell/tink/db"
+)
+
+type fakeMigrator struct {
+	states []db.MigrationState
+}
+
+func (f fakeMigrator) MigrationStatus() ([]db.MigrationState, error) {
+	return f.states, nil
+}
+
+func (f fakeMigrator) CheckRequiredMigrations() (int, error) {
+	pending := 0
+	for _, s := range f.states {
+		if !s.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func TestMigrationsStatus(t *testing.T) {
+	appliedAt := time.Date(2022, 10, 16, 14, 0, 0, 0, time.UTC)
+	m := fakeMigrator{states: []db.MigrationState{
+		{ID: "202009171251", Applied: true, AppliedAt: appliedAt},
+		{ID: "2022101615000"},
+	}}
+
+	out := &bytes.Buffer{}
+	if err := migrationsStatus(m, false, out); err != nil {
+		t.Fatal(err)
+	}
+	want := `ID             STATUS   APPLIED AT
+202009171251   applied  2022-10-16T14:00:00Z
+2022101615000  pending  
+1 applied, 1 pending
+`
+	if out.String() != want {
+		t.Errorf("unexpected output\nwant: %s\ngot: %s", want, out.String())
+	}
+
+	err := migrationsStatus(m, true, &bytes.Buffer{})
+	if err == nil || err.Error() != "1 migrations are pending" {
+		t.Errorf("expected a pending migrations error, got %v", err)
+	}
+
+	m.states[1].Applied = true
+	if err := migrationsStatus(m, true, &bytes.Buffer{}); err != nil {
+		t.Errorf("expected no error once every migration is applied, got %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestDatabaseCommandWithoutAuthority(t *testing.T) {
+	viper.Set("tinkerbell-grpc-authority", "")
+	defer viper.Set("tinkerbell-grpc-authority", nil)
+
+	ran := ""
+	noop := func(name string) *cobra.Command {
+		return &cobra.Command{Use: name, Run: func(*cobra.Command, []string) { ran = name }}
+	}
+	root := &cobra.Command{Use: testCommand, PersistentPreRunE: rootCmd.PersistentPreRunE}
+	database := NewDatabaseCommand()
+	database.AddCommand(noop("noop"))
+	root.AddCommand(database, noop("server"))
+
+	// The database commands don't talk to tink server.
+	root.SetArgs([]string{"database", "noop"})
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if ran != "noop" {
+		t.Error("expected the database command to run")
+	}
+
+	root.SetArgs([]string{"server"})
+	err := root.Execute()
+	if err == nil || !strings.Contains(err.Error(), "tinkerbell-grpc-authority") {
+		t.Errorf("expected the authority to be required, got %v", err)
+	}
+	if ran == "server" {
+		t.Error("expected the command not to run without the authority")
+	}
+}
@@ -29,6 +29,7 @@ func NewHardwareCommand() *cobra.Command {
 	cmd.AddCommand(hardware.NewListCmd())
 	cmd.AddCommand(hardware.NewGetByMACCmd())
 	cmd.AddCommand(hardware.NewPushCmd())
+	cmd.AddCommand(hardware.NewExportCmd())
 
 	return cmd
 }
@@ -0,0 +1,177 @@
+package hardware
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/tinkerbell/tink/pkg"
+	hwpb "github.com/tinkerbell/tink/protos/hardware"
+)
+
+// csvFields are the hardware fields that can be read from a CSV column.
+var csvFields = []string{"id", "mac", "ip", "hostname"}
+
+// parseMapping parses the --mapping flag, a comma separated list of
+// field=column pairs. The fields not mapped are read from the column with the
+// same name as the field.
+func parseMapping(mapping string) (map[string]string, error) {
+	columns := map[string]string{}
+	for _, f := range csvFields {
+		columns[f] = f
+	}
+	if mapping == "" {
+		return columns, nil
+	}
+	for _, pair := range strings.Split(mapping, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field, column = strings.TrimSpace(field), strings.TrimSpace(column)
+		if !ok || column == "" {
+			return nil, fmt.Errorf("invalid mapping %q, expected field=column", pair)
+		}
+		if _, known := columns[field]; !known {
+			return nil, fmt.Errorf("invalid mapping %q, field must be one of %s", pair, strings.Join(csvFields, ", "))
+		}
+		columns[field] = column
+	}
+	return columns, nil
+}
+
+// csvRecord is the hardware data read from a CSV row.
+type csvRecord struct {
+	line int
+	data string
+	err  error
+}
+
+// readCSV converts every row of the CSV data into hardware data. The first row
+// is the header naming the columns used by mapping.
+func readCSV(in io.Reader, mapping map[string]string) ([]csvRecord, error) {
+	r := csv.NewReader(in)
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+	index := map[string]int{}
+	for field, column := range mapping {
+		for i, h := range header {
+			if strings.TrimSpace(h) == column {
+				index[field] = i
+			}
+		}
+	}
+	if _, ok := index["id"]; !ok {
+		return nil, fmt.Errorf("CSV header has no %q column for the hardware id", mapping["id"])
+	}
+
+	var records []csvRecord
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			var perr *csv.ParseError
+			if !errors.As(err, &perr) {
+				return nil, err
+			}
+			records = append(records, csvRecord{line: perr.Line, err: perr.Err})
+			continue
+		}
+		line, _ := r.FieldPos(0)
+		value := func(field string) string {
+			if i, ok := index[field]; ok {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		data, err := csvHardware(value("id"), value("mac"), value("ip"), value("hostname"))
+		records = append(records, csvRecord{line: line, data: data, err: err})
+	}
+}
+
+// csvHardware returns the hardware data of a CSV row.
+func csvHardware(id, mac, ip, hostname string) (string, error) {
+	if id == "" {
+		return "", fmt.Errorf("id is required")
+	}
+	dhcp := &hwpb.Hardware_DHCP{Hostname: hostname}
+	if mac != "" {
+		hw, err := net.ParseMAC(mac)
+		if err != nil {
+			return "", fmt.Errorf("invalid mac: %q", mac)
+		}
+		dhcp.Mac = hw.String()
+	}
+	if ip != "" {
+		if net.ParseIP(ip) == nil {
+			return "", fmt.Errorf("invalid ip: %q", ip)
+		}
+		dhcp.Ip = &hwpb.Hardware_DHCP_IP{Address: ip}
+	}
+
+	data, err := json.Marshal(pkg.HardwareWrapper{Hardware: &hwpb.Hardware{
+		Id: id,
+		Network: &hwpb.Hardware_Network{
+			Interfaces: []*hwpb.Hardware_Network_Interface{{Dhcp: dhcp}},
+		},
+	}})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// pushCSV pushes the hardware data of every row of the CSV data. The invalid
+// rows are reported with their line number and nothing is pushed, unless
+// skipInvalid is set.
+func (o *pushOptions) pushCSV(in io.Reader, out io.Writer) error {
+	mapping, err := parseMapping(o.mapping)
+	if err != nil {
+		return err
+	}
+	records, err := readCSV(in, mapping)
+	if err != nil {
+		return err
+	}
+
+	invalid := 0
+	for i, r := range records {
+		if r.err == nil {
+			r.data, r.err = o.overrideData(r.data)
+		}
+		if r.err == nil {
+			r.err = o.checkSchema(out, fmt.Sprintf("line %d", r.line), r.data)
+		}
+		if r.err == nil {
+			_, r.err = parseData(r.data)
+		}
+		if r.err != nil {
+			invalid++
+			fmt.Fprintf(out, "line %d: %v\n", r.line, r.err)
+		}
+		records[i] = r
+	}
+	if invalid > 0 && !o.skipInvalid {
+		return fmt.Errorf("%d of %d CSV rows are invalid, nothing was pushed", invalid, len(records))
+	}
+
+	pushed := 0
+	for _, r := range records {
+		if r.err != nil {
+			continue
+		}
+		hw, _ := parseData(r.data)
+		if err := o.push(hw); err != nil {
+			return fmt.Errorf("line %d: push failed after %d records: %w", r.line, pushed, err)
+		}
+		pushed++
+	}
+	o.infof(out, "%d records pushed, %d skipped\n", pushed, invalid)
+	return nil
+}
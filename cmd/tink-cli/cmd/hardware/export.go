@@ -0,0 +1,159 @@
+package hardware
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/client"
+	"github.com/tinkerbell/tink/pkg"
+	hwpb "github.com/tinkerbell/tink/protos/hardware"
+)
+
+// exportNDJSONFile is the name of the file written by --single-file.
+const exportNDJSONFile = "hardware.ndjson"
+
+type exportOptions struct {
+	dir        string
+	singleFile bool
+}
+
+// NewExportCmd returns the command that writes every hardware record to a directory.
+func NewExportCmd() *cobra.Command {
+	opts := exportOptions{}
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "export all the hardware to a directory",
+		Long: `The export command writes every hardware record to its own JSON file in a
+directory, named after the hardware ID. With --single-file the records are
+written to a single newline-delimited JSON file instead. The exported hardware
+can be pushed back:
+$ tink hardware export --dir ./hw
+$ tink hardware push --dir ./hw
+$ tink hardware export --dir ./hw --single-file
+$ tink hardware push --ndjson --file ./hw/hardware.ndjson
+`,
+		Run: func(c *cobra.Command, args []string) {
+			if err := exportHardware(c.Context(), client.HardwareClient, opts, c.OutOrStdout()); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&opts.dir, "dir", "", "directory the hardware is written to")
+	flags.BoolVar(&opts.singleFile, "single-file", false, "write all the hardware to "+exportNDJSONFile+" in the directory")
+	_ = cmd.MarkPersistentFlagRequired("dir")
+	return cmd
+}
+
+func exportHardware(ctx context.Context, cl hwpb.HardwareServiceClient, opts exportOptions, out io.Writer) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := os.MkdirAll(opts.dir, 0o750); err != nil {
+		return err
+	}
+
+	var ndjson *bufio.Writer
+	if opts.singleFile {
+		file := filepath.Join(opts.dir, exportNDJSONFile)
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		ndjson = bufio.NewWriter(f)
+	}
+
+	list, err := cl.All(ctx, &hwpb.Empty{})
+	if err != nil {
+		return err
+	}
+	used := map[string]struct{}{}
+	exported, skipped := 0, 0
+	var hw *hwpb.Hardware
+	for hw, err = list.Recv(); err == nil && hw != nil; hw, err = list.Recv() {
+		if hw.GetId() == "" {
+			fmt.Fprintf(out, "warning: skipped hardware without an ID: %s\n", hardwareDescription(hw))
+			skipped++
+			continue
+		}
+		data, err := json.Marshal(pkg.HardwareWrapper{Hardware: hw})
+		if err != nil {
+			return fmt.Errorf("marshal hardware %s: %w", hw.GetId(), err)
+		}
+
+		if ndjson != nil {
+			if _, err := ndjson.Write(append(data, '\n')); err != nil {
+				return err
+			}
+		} else {
+			file := filepath.Join(opts.dir, exportFileName(hw.GetId(), used))
+			if err := os.WriteFile(file, data, 0o600); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "%s: exported to %s\n", hw.GetId(), file)
+		}
+		exported++
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if ndjson != nil {
+		if err := ndjson.Flush(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(out, "%d hardware exported, %d skipped\n", exported, skipped)
+	return nil
+}
+
+// hardwareDescription identifies the hardware by its MAC addresses in the
+// warnings, when it has no ID.
+func hardwareDescription(hw *hwpb.Hardware) string {
+	macs := []string{}
+	for _, iface := range hw.GetNetwork().GetInterfaces() {
+		if mac := iface.GetDhcp().GetMac(); mac != "" {
+			macs = append(macs, mac)
+		}
+	}
+	if len(macs) == 0 {
+		return "no MAC address"
+	}
+	return strings.Join(macs, ", ")
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// exportFileName returns a file name safe for every file system for the
+// hardware ID. A numeric suffix is added to the names already used, which are
+// compared regardless of the case.
+func exportFileName(id string, used map[string]struct{}) string {
+	base := strings.Trim(unsafeFileNameChars.ReplaceAllString(id, "_"), "._")
+	if base == "" {
+		base = "hardware"
+	}
+	candidate := base
+	for i := 1; ; i++ {
+		if _, ok := used[strings.ToLower(candidate)]; !ok {
+			break
+		}
+		candidate = base + "-" + strconv.Itoa(i)
+	}
+	used[strings.ToLower(candidate)] = struct{}{}
+	return candidate + ".json"
+}
@@ -0,0 +1,109 @@
+package hardware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/tink/client"
+	hwpb "github.com/tinkerbell/tink/protos/hardware"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func hardwareServiceMock(hardware []*hwpb.Hardware, pushed *[]*hwpb.Hardware) *hwpb.HardwareServiceClientMock {
+	return &hwpb.HardwareServiceClientMock{
+		AllFunc: func(ctx context.Context, in *hwpb.Empty, opts ...grpc.CallOption) (hwpb.HardwareService_AllClient, error) {
+			counter := 0
+			return &hwpb.HardwareService_AllClientMock{
+				RecvFunc: func() (*hwpb.Hardware, error) {
+					counter++
+					if counter > len(hardware) {
+						return nil, io.EOF
+					}
+					return hardware[counter-1], nil
+				},
+			}, nil
+		},
+		PushFunc: func(ctx context.Context, in *hwpb.PushRequest, opts ...grpc.CallOption) (*hwpb.Empty, error) {
+			*pushed = append(*pushed, in.GetData())
+			return &hwpb.Empty{}, nil
+		},
+	}
+}
+
+func TestExportHardware(t *testing.T) {
+	hardware := []*hwpb.Hardware{
+		{
+			Id:       "0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94",
+			Metadata: `{"facility":{"facility_code":"sjc1"}}`,
+			Network: &hwpb.Hardware_Network{
+				Interfaces: []*hwpb.Hardware_Network_Interface{
+					{Dhcp: &hwpb.Hardware_DHCP{Mac: "08:00:27:00:00:01", Hostname: "one"}},
+				},
+			},
+		},
+		{
+			Network: &hwpb.Hardware_Network{
+				Interfaces: []*hwpb.Hardware_Network_Interface{
+					{Dhcp: &hwpb.Hardware_DHCP{Mac: "08:00:27:00:00:02"}},
+				},
+			},
+		},
+		{Id: "../0eba0bf8-3772-4b4a-ab9f-6ebe93b90a95"},
+	}
+	want := []*hwpb.Hardware{hardware[0], hardware[2]}
+
+	t.Run("directory", func(t *testing.T) {
+		dir := t.TempDir()
+		var pushed []*hwpb.Hardware
+		cl := hardwareServiceMock(hardware, &pushed)
+		out := &bytes.Buffer{}
+		if err := exportHardware(context.Background(), cl, exportOptions{dir: dir}, out); err != nil {
+			t.Fatal(err)
+		}
+
+		wantOut := "0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94: exported to " + filepath.Join(dir, "0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94.json") + "\n" +
+			"warning: skipped hardware without an ID: 08:00:27:00:00:02\n" +
+			"../0eba0bf8-3772-4b4a-ab9f-6ebe93b90a95: exported to " + filepath.Join(dir, "0eba0bf8-3772-4b4a-ab9f-6ebe93b90a95.json") + "\n" +
+			"2 hardware exported, 1 skipped\n"
+		if diff := cmp.Diff(wantOut, out.String()); diff != "" {
+			t.Errorf("unexpected output (-want +got):\n%s", diff)
+		}
+
+		client.HardwareClient = cl
+		opts := &pushOptions{dir: dir, concurrency: 1}
+		if err := opts.pushDir(&bytes.Buffer{}); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, pushed, protocmp.Transform()); diff != "" {
+			t.Errorf("unexpected hardware pushed back (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("single file", func(t *testing.T) {
+		dir := t.TempDir()
+		var pushed []*hwpb.Hardware
+		cl := hardwareServiceMock(hardware, &pushed)
+		if err := exportHardware(context.Background(), cl, exportOptions{dir: dir, singleFile: true}, &bytes.Buffer{}); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := os.Open(filepath.Join(dir, exportNDJSONFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		client.HardwareClient = cl
+		if err := (&pushOptions{}).pushNDJSON(f, &bytes.Buffer{}); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, pushed, protocmp.Transform()); diff != "" {
+			t.Errorf("unexpected hardware pushed back (-want +got):\n%s", diff)
+		}
+	})
+}
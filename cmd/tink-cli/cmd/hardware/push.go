@@ -3,79 +3,469 @@
 package hardware
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"github.com/tinkerbell/tink/client"
 	"github.com/tinkerbell/tink/pkg"
 	hwpb "github.com/tinkerbell/tink/protos/hardware"
+	"github.com/xeipuuv/gojsonschema"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
 )
 
-var (
-	file  string
-	sFile = "file"
+type pushOptions struct {
+	file            string
+	dir             string
+	concurrency     int
+	url             string
+	token           string
+	timeout         time.Duration
+	schemaFile      string
+	validate        bool
+	ndjson          bool
+	skipInvalid     bool
+	progress        int
+	format          string
+	mapping         string
+	set             []string
+	quiet           bool
+	verbose         bool
+	stripTimestamps bool
+	setUpdatedNow   bool
+
+	schema *gojsonschema.Schema
+	sets   []hardwareSet
+	// updatedAt is the update timestamp set with --set-updated-now.
+	updatedAt time.Time
+	// logOut receives the steps logged with --verbose.
+	logOut io.Writer
+}
+
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
 )
 
 // pushCmd represents the push command.
 func NewPushCmd() *cobra.Command {
+	opts := &pushOptions{}
 	cmd := &cobra.Command{
 		Use:   "push",
 		Short: "push new hardware to tink",
 		Example: `cat /tmp/data.json | tink hardware push
-tink hardware push --file /tmp/data.json`,
+tink hardware push --file /tmp/data.json
+tink hardware push --dir /tmp/hardware --concurrency 8
+tink hardware push --url https://inventory.example.com/hardware/data.json --token $TOKEN
+tink hardware push --file /tmp/data.json --validate
+tink hardware push --dir /tmp/hardware --schema /tmp/hardware-schema.json
+cat /tmp/inventory.ndjson | tink hardware push --ndjson --skip-invalid
+tink hardware push --file /tmp/inventory.csv --format csv --mapping id=uuid,mac=mac_address
+tink hardware push --file /tmp/data.json --set id=0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94 --set metadata.facility.facility_code=sjc1
+tink hardware push --dir /tmp/hardware --quiet
+tink hardware push --file /tmp/data.json --verbose
+tink hardware push --file /tmp/inventory.yaml --skip-invalid
+tink hardware push --dir /tmp/hardware --strip-timestamps --set-updated-now`,
 		PreRunE: func(c *cobra.Command, args []string) error {
-			if !isInputFromPipe() {
-				path, _ := c.Flags().GetString(sFile)
-				if path == "" {
-					return fmt.Errorf("either pipe the data or provide the required '--file' flag")
+			if !isInputFromPipe() && opts.file == "" && opts.url == "" && opts.dir == "" {
+				return fmt.Errorf("either pipe the data or provide the required '--file', '--dir' or '--url' flag")
+			}
+			if opts.concurrency < 1 {
+				return fmt.Errorf("--concurrency must be at least 1")
+			}
+			switch opts.format {
+			case formatJSON:
+			case formatCSV:
+				if opts.dir != "" || opts.ndjson {
+					return fmt.Errorf("--format csv cannot be used with --dir or --ndjson")
 				}
+			default:
+				return fmt.Errorf("invalid --format %q, expected %s or %s", opts.format, formatJSON, formatCSV)
+			}
+			if opts.mapping != "" && opts.format != formatCSV {
+				return fmt.Errorf("--mapping requires --format csv")
+			}
+			if opts.skipInvalid && opts.dir != "" {
+				return fmt.Errorf("--skip-invalid cannot be used with --dir")
+			}
+			sets, err := parseSets(opts.set)
+			if err != nil {
+				return err
+			}
+			opts.sets = sets
+			if opts.setUpdatedNow {
+				opts.updatedAt = time.Now()
 			}
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			var data string
-			var err error
-
-			if isInputFromPipe() {
-				data = readDataFromStdin()
-			} else {
-				data, err = readDataFromFile()
+			opts.logOut = cmd.ErrOrStderr()
+			opts.debugf("connecting to tink server at %s", viper.GetString("tinkerbell-grpc-authority"))
+			if opts.validate || opts.schemaFile != "" {
+				opts.debugf("loading the JSON Schema")
+				schema, err := loadSchema(opts.schemaFile)
 				if err != nil {
-					log.Fatalf("read data from file failed: %v", err)
+					log.Fatal(err)
 				}
+				opts.schema = schema
 			}
-			s := struct {
-				ID string
-			}{}
-			if json.NewDecoder(strings.NewReader(data)).Decode(&s) != nil {
-				log.Fatalf("invalid json: %s", data)
-			} else if s.ID == "" {
-				log.Fatalf("invalid json, ID is required: %s", data)
+
+			if opts.dir != "" {
+				if err := opts.pushDir(cmd.OutOrStdout()); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+
+			if opts.ndjson {
+				in := os.Stdin
+				if !isInputFromPipe() {
+					f, err := os.Open(filepath.Clean(opts.file))
+					if err != nil {
+						log.Fatalf("read data failed: %v", err)
+					}
+					defer f.Close()
+					in = f
+				}
+				if err := opts.pushNDJSON(in, cmd.OutOrStdout()); err != nil {
+					log.Fatal(err)
+				}
+				return
 			}
 
-			var hw pkg.HardwareWrapper
-			err = json.Unmarshal([]byte(data), &hw)
+			data, err := opts.readData(cmd.Context())
 			if err != nil {
+				log.Fatalf("read data failed: %v", err)
+			}
+			opts.debugf("read %d bytes of hardware data", len(data))
+			if opts.format == formatCSV {
+				if err := opts.pushCSV(strings.NewReader(data), cmd.OutOrStdout()); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+			if docs := splitYAMLDocuments(data); len(docs) > 1 {
+				opts.debugf("read %d YAML documents", len(docs))
+				if err := opts.pushDocuments(docs, cmd.OutOrStdout()); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+			if opts.skipInvalid {
+				log.Fatal("--skip-invalid requires --ndjson, --format csv or a multi-document YAML input")
+			}
+			if data, err = opts.overrideData(data); err != nil {
 				log.Fatal(err)
 			}
-			if _, err := client.HardwareClient.Push(context.Background(), &hwpb.PushRequest{Data: hw.Hardware}); err != nil {
+			if err := opts.checkSchema(cmd.OutOrStdout(), "", data); err != nil {
 				log.Fatal(err)
 			}
-			log.Println("Hardware data pushed successfully")
+			if err := opts.pushData(data); err != nil {
+				log.Fatal(err)
+			}
+			if !opts.quiet {
+				log.Println("Hardware data pushed successfully")
+			}
 		},
 	}
 	flags := cmd.PersistentFlags()
-	flags.StringVarP(&file, "file", "", "", "hardware data file")
+	flags.StringVarP(&opts.file, "file", "", "", "hardware data file")
+	flags.StringVar(&opts.dir, "dir", "", "directory of hardware data files (*.json) to push")
+	flags.IntVar(&opts.concurrency, "concurrency", 1, "number of files from --dir pushed in parallel")
+	flags.StringVar(&opts.url, "url", "", "HTTP(S) URL to fetch the hardware data from")
+	flags.StringVar(&opts.token, "token", "", "bearer token used to authenticate against --url")
+	flags.DurationVar(&opts.timeout, "timeout", 30*time.Second, "timeout for fetching the hardware data from --url")
+	flags.StringVar(&opts.schemaFile, "schema", "", "JSON Schema file to validate the hardware data against, implies --validate")
+	flags.BoolVar(&opts.validate, "validate", false, "validate the hardware data against the JSON Schema before pushing anything")
+	flags.BoolVar(&opts.ndjson, "ndjson", false, "read one hardware data record per line and push them as they are read")
+	flags.BoolVar(&opts.skipInvalid, "skip-invalid", false, "report and skip the malformed records instead of stopping, with --ndjson, --format csv or a multi-document YAML input")
+	flags.IntVar(&opts.progress, "progress", 1000, "report the progress every N records with --ndjson, 0 disables it")
+	flags.StringVar(&opts.format, "format", formatJSON, "format of the hardware data, json or csv")
+	flags.StringVar(&opts.mapping, "mapping", "", "comma separated field=column pairs mapping the CSV columns to the id, mac, ip and hostname fields")
+	flags.StringArrayVar(&opts.set, "set", nil, "override a field of the hardware data with a dot separated key=value pair, can be repeated")
+	flags.BoolVarP(&opts.quiet, "quiet", "q", false, "only report the errors")
+	flags.BoolVar(&opts.verbose, "verbose", false, "log every step of the push, with the duration of the calls to tink server")
+	flags.BoolVar(&opts.stripTimestamps, "strip-timestamps", false, "remove the createdAt, updatedAt and similar timestamps of the hardware data and its metadata")
+	flags.BoolVar(&opts.setUpdatedNow, "set-updated-now", false, "set the update timestamps of the hardware data and its metadata to the current time, adding updatedAt when there is none")
+	cmd.MarkFlagsMutuallyExclusive("file", "dir", "url")
+	cmd.MarkFlagsMutuallyExclusive("ndjson", "dir")
+	cmd.MarkFlagsMutuallyExclusive("ndjson", "url")
+	cmd.MarkFlagsMutuallyExclusive("quiet", "verbose")
 	return cmd
 }
 
+// pushData validates the hardware data and pushes it to tink server.
+func (o *pushOptions) pushData(data string) error {
+	o.debugf("validating the hardware data")
+	hw, err := parseData(data)
+	if err != nil {
+		return err
+	}
+	return o.push(hw)
+}
+
+// push pushes the hardware to tink server.
+func (o *pushOptions) push(hw *hwpb.Hardware) error {
+	o.debugf("pushing hardware %s", hw.GetId())
+	start := time.Now()
+	_, err := client.HardwareClient.Push(context.Background(), &hwpb.PushRequest{Data: hw})
+	if err != nil {
+		o.debugf("push of hardware %s failed after %s", hw.GetId(), time.Since(start).Round(time.Millisecond))
+		return readablePushError(err)
+	}
+	o.debugf("pushed hardware %s in %s", hw.GetId(), time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// readablePushError returns the rejection of the hardware data by tink server
+// as one line per invalid field, when the server tells which fields are
+// invalid, instead of the opaque status string. Other errors are returned
+// unchanged.
+func readablePushError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	var violations []string
+	for _, detail := range st.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range br.GetFieldViolations() {
+				violations = append(violations, fmt.Sprintf("  %s: %s", v.GetField(), v.GetDescription()))
+			}
+		}
+	}
+	if len(violations) == 0 {
+		return err
+	}
+	return fmt.Errorf("hardware data rejected by tink server:\n%s", strings.Join(violations, "\n"))
+}
+
+// infof writes a success message to out, unless --quiet is set.
+func (o *pushOptions) infof(out io.Writer, format string, args ...interface{}) {
+	if !o.quiet {
+		fmt.Fprintf(out, format, args...)
+	}
+}
+
+// debugf logs a step of the push when --verbose is set.
+func (o *pushOptions) debugf(format string, args ...interface{}) {
+	if o.verbose && o.logOut != nil {
+		fmt.Fprintf(o.logOut, format+"\n", args...)
+	}
+}
+
+// parseData validates the hardware data and decodes it.
+func parseData(data string) (*hwpb.Hardware, error) {
+	s := struct {
+		ID string
+	}{}
+	if json.NewDecoder(strings.NewReader(data)).Decode(&s) != nil {
+		return nil, fmt.Errorf("invalid json: %s", data)
+	} else if s.ID == "" {
+		return nil, fmt.Errorf("invalid json, ID is required: %s", data)
+	}
+
+	var hw pkg.HardwareWrapper
+	if err := json.Unmarshal([]byte(data), &hw); err != nil {
+		return nil, err
+	}
+	return hw.Hardware, nil
+}
+
+// maxRecordSize is the size of the longest line accepted by pushNDJSON.
+const maxRecordSize = 16 * 1024 * 1024
+
+// pushNDJSON pushes every hardware data record of the newline-delimited JSON
+// stream as soon as it is read, so the memory used does not depend on the
+// size of the stream. Malformed records are reported with their line number
+// and stop the push, unless skipInvalid is set.
+func (o *pushOptions) pushNDJSON(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+
+	line, pushed, skipped := 0, 0, 0
+	for scanner.Scan() {
+		line++
+		data := strings.TrimSpace(scanner.Text())
+		if data == "" {
+			continue
+		}
+
+		data, err := o.overrideData(data)
+		if err == nil {
+			err = o.checkSchema(out, fmt.Sprintf("line %d", line), data)
+		}
+		var hw *hwpb.Hardware
+		if err == nil {
+			hw, err = parseData(data)
+		}
+		if err != nil {
+			if !o.skipInvalid {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			fmt.Fprintf(out, "line %d: skipped: %v\n", line, err)
+			skipped++
+			continue
+		}
+
+		if err := o.push(hw); err != nil {
+			return fmt.Errorf("line %d: push failed after %d records: %w", line, pushed, err)
+		}
+		pushed++
+		if o.progress > 0 && pushed%o.progress == 0 {
+			o.infof(out, "%d records pushed\n", pushed)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("line %d: %w", line+1, err)
+	}
+	o.infof(out, "%d records pushed, %d skipped\n", pushed, skipped)
+	return nil
+}
+
+type pushResult struct {
+	file string
+	err  error
+}
+
+// pushDir pushes every hardware data file in the directory using a bounded
+// pool of workers. A summary sorted by file name is written to out, and an
+// error is returned if any of the files failed to push.
+func (o *pushOptions) pushDir(out io.Writer) error {
+	files, err := filepath.Glob(filepath.Join(o.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no hardware data files found in %s", o.dir)
+	}
+	sort.Strings(files)
+
+	if o.schema != nil {
+		invalid := 0
+		for _, file := range files {
+			data, err := o.readFile(file)
+			if err == nil {
+				err = o.checkSchema(out, filepath.Base(file), data)
+			}
+			if err != nil {
+				invalid++
+			}
+		}
+		if invalid > 0 {
+			return fmt.Errorf("%d of %d hardware data files do not match the schema, nothing was pushed", invalid, len(files))
+		}
+	}
+
+	results := make([]pushResult, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < o.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				data, err := o.readFile(files[i])
+				if err == nil {
+					err = o.pushData(data)
+				}
+				results[i] = pushResult{file: files[i], err: err}
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := 0
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+			fmt.Fprintf(out, "%s: failed: %v\n", filepath.Base(r.file), r.err)
+			continue
+		}
+		o.infof(out, "%s: pushed\n", filepath.Base(r.file))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d hardware data files failed to push", failed, len(files))
+	}
+	return nil
+}
+
+// checkSchema writes every violation of the schema found in the hardware data
+// to out, prefixed by name when set, and returns an error if there is any.
+// It does nothing when no schema is configured.
+func (o *pushOptions) checkSchema(out io.Writer, name, data string) error {
+	if o.schema == nil {
+		return nil
+	}
+	violations, err := schemaViolations(o.schema, data)
+	if err != nil {
+		violations = []string{err.Error()}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	for _, v := range violations {
+		if name != "" {
+			v = name + ": " + v
+		}
+		fmt.Fprintln(out, v)
+	}
+	return fmt.Errorf("hardware data does not match the schema")
+}
+
+// readData returns the hardware data from the URL, the standard input or
+// the file, in this order.
+func (o *pushOptions) readData(ctx context.Context) (string, error) {
+	switch {
+	case o.url != "":
+		o.debugf("reading the hardware data from %s", o.url)
+		return readDataFromURL(ctx, o.url, o.token, o.timeout)
+	case isInputFromPipe():
+		o.debugf("reading the hardware data from the standard input")
+		return readDataFromStdin(), nil
+	default:
+		o.debugf("reading the hardware data from %s", o.file)
+		return readDataFromFile(o.file)
+	}
+}
+
+// readFile returns the hardware data of the file with the overrides applied.
+func (o *pushOptions) readFile(file string) (string, error) {
+	o.debugf("reading %s", file)
+	data, err := readDataFromFile(file)
+	if err != nil {
+		return "", err
+	}
+	return o.overrideData(data)
+}
+
+// overrideData returns the hardware data with the --set overrides applied and
+// its timestamps stripped or set as requested.
+func (o *pushOptions) overrideData(data string) (string, error) {
+	data, err := applySets(data, o.sets)
+	if err != nil {
+		return "", err
+	}
+	return applyTimestamps(data, o.stripTimestamps, o.updatedAt)
+}
+
 func isInputFromPipe() bool {
 	fileInfo, _ := os.Stdin.Stat()
 	return fileInfo.Mode()&os.ModeCharDevice == 0
@@ -89,7 +479,7 @@ func readDataFromStdin() string {
 	return string(data)
 }
 
-func readDataFromFile() (string, error) {
+func readDataFromFile(file string) (string, error) {
 	f, err := os.Open(filepath.Clean(file))
 	if err != nil {
 		return "", err
@@ -102,3 +492,35 @@ func readDataFromFile() (string, error) {
 	}
 	return string(data), nil
 }
+
+// readDataFromURL fetches the hardware data over HTTP(S). The default transport
+// honors the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func readDataFromURL(ctx context.Context, url, token string, timeout time.Duration) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	c := &http.Client{Timeout: timeout}
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return "", fmt.Errorf("unexpected status fetching %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,454 @@
+package hardware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tinkerbell/tink/client"
+	hwpb "github.com/tinkerbell/tink/protos/hardware"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestReadDataFromURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94"}`))
+	}))
+	defer srv.Close()
+
+	data, err := readDataFromURL(context.Background(), srv.URL, "secret", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94"}`; data != want {
+		t.Errorf("expected %q, got %q", want, data)
+	}
+
+	if _, err := readDataFromURL(context.Background(), srv.URL, "", time.Second); err == nil {
+		t.Error("expected an error for an unauthorized request, got nil")
+	}
+}
+
+func TestPushDir(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 5; i++ {
+		data := fmt.Sprintf(`{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a9%d"}`, i)
+		if i == 3 {
+			data = `{"metadata":{}}`
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("hw-%d.json", i)), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var pushed int32
+	client.HardwareClient = &hwpb.HardwareServiceClientMock{
+		PushFunc: func(ctx context.Context, in *hwpb.PushRequest, opts ...grpc.CallOption) (*hwpb.Empty, error) {
+			atomic.AddInt32(&pushed, 1)
+			return &hwpb.Empty{}, nil
+		},
+	}
+
+	out := &bytes.Buffer{}
+	opts := &pushOptions{dir: dir, concurrency: 3}
+	if err := opts.pushDir(out); err == nil {
+		t.Error("expected an error because one of the files is invalid, got nil")
+	}
+	if pushed != 4 {
+		t.Errorf("expected 4 hardware pushed, got %d", pushed)
+	}
+	want := `hw-0.json: pushed
+hw-1.json: pushed
+hw-2.json: pushed
+hw-3.json: failed: invalid json, ID is required: {"metadata":{}}
+hw-4.json: pushed
+`
+	if out.String() != want {
+		t.Errorf("unexpected summary\nwant: %s\ngot: %s", want, out.String())
+	}
+}
+
+func TestPushDirSchema(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"hw-0.json": `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","network":{"interfaces":[{"dhcp":{"mac":"08:00:27:00:00:01"}}]}}`,
+		"hw-1.json": `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a95","network":{"interfaces":[{"dhcp":{"mac":"not-a-mac","lease_time":"1d"}}]}}`,
+		"hw-2.json": `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a96"}`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var pushed int32
+	client.HardwareClient = &hwpb.HardwareServiceClientMock{
+		PushFunc: func(ctx context.Context, in *hwpb.PushRequest, opts ...grpc.CallOption) (*hwpb.Empty, error) {
+			atomic.AddInt32(&pushed, 1)
+			return &hwpb.Empty{}, nil
+		},
+	}
+
+	schema, err := loadSchema("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	opts := &pushOptions{dir: dir, concurrency: 1, schema: schema}
+	if err := opts.pushDir(out); err == nil {
+		t.Error("expected an error because two of the files do not match the schema, got nil")
+	}
+	if pushed != 0 {
+		t.Errorf("expected nothing to be pushed, got %d", pushed)
+	}
+	for _, want := range []string{
+		"hw-1.json: network.interfaces.0.dhcp.mac: Does not match pattern",
+		"hw-1.json: network.interfaces.0.dhcp.lease_time: Invalid type. Expected: integer, given: string",
+		"hw-2.json: (root): network is required",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected the output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "hw-0.json") {
+		t.Errorf("expected no violation for hw-0.json, got:\n%s", out.String())
+	}
+}
+
+func TestPushNDJSON(t *testing.T) {
+	input := `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a90"}
+{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a91"}
+
+{"id":
+{"metadata":{}}
+{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a92"}
+`
+	tests := []struct {
+		name        string
+		skipInvalid bool
+		wantErr     string
+		wantPushed  int32
+		wantOut     string
+	}{
+		{
+			name:       "stop at the first malformed line",
+			wantErr:    `line 4: invalid json: {"id":`,
+			wantPushed: 2,
+			wantOut:    "2 records pushed\n",
+		},
+		{
+			name:        "skip malformed lines",
+			skipInvalid: true,
+			wantPushed:  3,
+			wantOut: `2 records pushed
+line 4: skipped: invalid json: {"id":
+line 5: skipped: invalid json, ID is required: {"metadata":{}}
+3 records pushed, 2 skipped
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pushed int32
+			client.HardwareClient = &hwpb.HardwareServiceClientMock{
+				PushFunc: func(ctx context.Context, in *hwpb.PushRequest, opts ...grpc.CallOption) (*hwpb.Empty, error) {
+					atomic.AddInt32(&pushed, 1)
+					return &hwpb.Empty{}, nil
+				},
+			}
+
+			out := &bytes.Buffer{}
+			opts := &pushOptions{skipInvalid: tt.skipInvalid, progress: 2}
+			err := opts.pushNDJSON(strings.NewReader(input), out)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("expected error %q, got %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if pushed != tt.wantPushed {
+				t.Errorf("expected %d hardware pushed, got %d", tt.wantPushed, pushed)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("unexpected output\nwant: %s\ngot: %s", tt.wantOut, out.String())
+			}
+		})
+	}
+}
+
+func TestPushCSV(t *testing.T) {
+	tests := []struct {
+		name        string
+		skipInvalid bool
+		wantErr     string
+		wantPushed  []string
+	}{
+		{
+			name:    "invalid rows push nothing",
+			wantErr: "2 of 5 CSV rows are invalid, nothing was pushed",
+		},
+		{
+			name:        "skip invalid rows",
+			skipInvalid: true,
+			wantPushed: []string{
+				"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94 08:00:27:00:00:01 192.168.1.5 server001",
+				"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a95 08:00:27:00:00:02 192.168.1.6 server002",
+				"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a98 08:00:27:00:00:05  server005",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pushed []string
+			client.HardwareClient = &hwpb.HardwareServiceClientMock{
+				PushFunc: func(ctx context.Context, in *hwpb.PushRequest, opts ...grpc.CallOption) (*hwpb.Empty, error) {
+					dhcp := in.Data.GetNetwork().GetInterfaces()[0].GetDhcp()
+					pushed = append(pushed, fmt.Sprintf("%s %s %s %s", in.Data.GetId(), dhcp.GetMac(), dhcp.GetIp().GetAddress(), dhcp.GetHostname()))
+					return &hwpb.Empty{}, nil
+				},
+			}
+
+			f, err := os.Open("./testdata/hardware.csv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			out := &bytes.Buffer{}
+			opts := &pushOptions{
+				skipInvalid: tt.skipInvalid,
+				mapping:     "id=uuid,mac=mac_address,ip=ip_address,hostname=name",
+			}
+			err = opts.pushCSV(f, out)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("expected error %q, got %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if len(pushed) != len(tt.wantPushed) || strings.Join(pushed, "\n") != strings.Join(tt.wantPushed, "\n") {
+				t.Errorf("unexpected hardware pushed\nwant: %v\ngot: %v", tt.wantPushed, pushed)
+			}
+			for _, want := range []string{"line 4: id is required", `line 5: invalid mac: "08:00:27:00:00"`} {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("expected the output to contain %q, got:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	got, err := parseMapping("id=uuid, mac=mac_address")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"id": "uuid", "mac": "mac_address", "ip": "ip", "hostname": "hostname"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	for _, mapping := range []string{"id", "id=", "rack=rack"} {
+		if _, err := parseMapping(mapping); err == nil {
+			t.Errorf("expected an error for mapping %q", mapping)
+		}
+	}
+}
+
+func TestPushDirQuietVerbose(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"hw-0.json": `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94"}`,
+		"hw-1.json": `{"metadata":{}}`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.HardwareClient = &hwpb.HardwareServiceClientMock{
+		PushFunc: func(ctx context.Context, in *hwpb.PushRequest, opts ...grpc.CallOption) (*hwpb.Empty, error) {
+			return &hwpb.Empty{}, nil
+		},
+	}
+
+	t.Run("quiet", func(t *testing.T) {
+		out, logOut := &bytes.Buffer{}, &bytes.Buffer{}
+		opts := &pushOptions{dir: dir, concurrency: 1, quiet: true, logOut: logOut}
+		_ = opts.pushDir(out)
+		want := "hw-1.json: failed: invalid json, ID is required: {\"metadata\":{}}\n"
+		if out.String() != want {
+			t.Errorf("expected only the failures\nwant: %s\ngot: %s", want, out.String())
+		}
+		if logOut.Len() != 0 {
+			t.Errorf("expected no log, got %s", logOut.String())
+		}
+	})
+
+	t.Run("verbose", func(t *testing.T) {
+		out, logOut := &bytes.Buffer{}, &bytes.Buffer{}
+		opts := &pushOptions{dir: dir, concurrency: 1, verbose: true, logOut: logOut}
+		_ = opts.pushDir(out)
+		if !strings.Contains(out.String(), "hw-0.json: pushed") {
+			t.Errorf("expected the pushed files to be reported, got %s", out.String())
+		}
+		for _, step := range []string{
+			"reading " + filepath.Join(dir, "hw-0.json"),
+			"validating the hardware data",
+			"pushing hardware 0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94",
+			"pushed hardware 0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94 in ",
+		} {
+			if !strings.Contains(logOut.String(), step) {
+				t.Errorf("expected the log to contain %q, got %s", step, logOut.String())
+			}
+		}
+	})
+}
+
+func TestPushDocuments(t *testing.T) {
+	input := `---
+id: 0eba0bf8-3772-4b4a-ab9f-6ebe93b90a90
+metadata:
+  facility:
+    facility_code: sjc1
+---
+# nothing here
+---
+id: [
+---
+metadata: {}
+--- # last one
+{"id": "0eba0bf8-3772-4b4a-ab9f-6ebe93b90a91"}
+`
+	docs := splitYAMLDocuments(input)
+	if len(docs) != 4 {
+		t.Fatalf("expected 4 documents, got %d", len(docs))
+	}
+
+	tests := []struct {
+		name        string
+		skipInvalid bool
+		wantErr     string
+		wantPushed  []string
+		wantOut     []string
+	}{
+		{
+			name:    "stop on invalid documents",
+			wantErr: "2 of 4 documents are invalid, nothing was pushed",
+			wantOut: []string{
+				"document 3: invalid: invalid yaml:",
+				"document 4: invalid: invalid json, ID is required",
+			},
+		},
+		{
+			name:        "skip invalid documents",
+			skipInvalid: true,
+			wantPushed:  []string{"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a90", "0eba0bf8-3772-4b4a-ab9f-6ebe93b90a91"},
+			wantOut: []string{
+				"document 3: skipped: invalid yaml:",
+				"document 4: skipped: invalid json, ID is required",
+				"document 1: pushed 0eba0bf8-3772-4b4a-ab9f-6ebe93b90a90",
+				"document 5: pushed 0eba0bf8-3772-4b4a-ab9f-6ebe93b90a91",
+				"2 documents pushed, 2 skipped",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pushed []string
+			client.HardwareClient = &hwpb.HardwareServiceClientMock{
+				PushFunc: func(ctx context.Context, in *hwpb.PushRequest, opts ...grpc.CallOption) (*hwpb.Empty, error) {
+					pushed = append(pushed, in.Data.Id)
+					if in.Data.Id == "0eba0bf8-3772-4b4a-ab9f-6ebe93b90a90" && in.Data.Metadata == "" {
+						t.Error("expected the metadata to be pushed")
+					}
+					return &hwpb.Empty{}, nil
+				},
+			}
+
+			out := &bytes.Buffer{}
+			opts := &pushOptions{skipInvalid: tt.skipInvalid}
+			err := opts.pushDocuments(docs, out)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("expected error %q, got %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(pushed) != fmt.Sprint(tt.wantPushed) {
+				t.Errorf("expected %v pushed, got %v", tt.wantPushed, pushed)
+			}
+			for _, want := range tt.wantOut {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("expected output to contain %q, got:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+
+	if docs := splitYAMLDocuments(`{"id": "0eba0bf8-3772-4b4a-ab9f-6ebe93b90a90"}`); len(docs) != 1 {
+		t.Errorf("expected a single document, got %d", len(docs))
+	}
+}
+
+func TestPushReadableError(t *testing.T) {
+	st, err := status.New(codes.InvalidArgument, "invalid hardware data").WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "id", Description: "must be set to a UUID, got id: "},
+			{Field: "network.interfaces[0].dhcp.mac", Description: "conflicting hardware MAC address 08:00:27:00:00:01"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		pushErr error
+		wantErr string
+	}{
+		{
+			name:    "field violations",
+			pushErr: st.Err(),
+			wantErr: "hardware data rejected by tink server:\n" +
+				"  id: must be set to a UUID, got id: \n" +
+				"  network.interfaces[0].dhcp.mac: conflicting hardware MAC address 08:00:27:00:00:01",
+		},
+		{
+			name:    "status without details",
+			pushErr: status.Error(codes.Unavailable, "connection refused"),
+			wantErr: "rpc error: code = Unavailable desc = connection refused",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.HardwareClient = &hwpb.HardwareServiceClientMock{
+				PushFunc: func(ctx context.Context, in *hwpb.PushRequest, opts ...grpc.CallOption) (*hwpb.Empty, error) {
+					return nil, tt.pushErr
+				},
+			}
+			opts := &pushOptions{}
+			err := opts.pushData(`{"id": "0eba0bf8-3772-4b4a-ab9f-6ebe93b90a90"}`)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
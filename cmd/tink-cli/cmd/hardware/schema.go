@@ -0,0 +1,47 @@
+package hardware
+
+import (
+	_ "embed"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// defaultSchema is the JSON Schema of pkg.HardwareWrapper, used when no
+// schema file is given.
+//
+//go:embed schema.json
+var defaultSchema []byte
+
+// loadSchema compiles the JSON Schema in file, or the default schema when file
+// is empty.
+func loadSchema(file string) (*gojsonschema.Schema, error) {
+	data := defaultSchema
+	if file != "" {
+		var err error
+		data, err = os.ReadFile(filepath.Clean(file))
+		if err != nil {
+			return nil, err
+		}
+	}
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid JSON Schema")
+	}
+	return schema, nil
+}
+
+// schemaViolations returns every violation of the schema found in the hardware data.
+func schemaViolations(schema *gojsonschema.Schema, data string) ([]string, error) {
+	result, err := schema.Validate(gojsonschema.NewStringLoader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid json")
+	}
+	violations := make([]string, 0, len(result.Errors()))
+	for _, e := range result.Errors() {
+		violations = append(violations, e.String())
+	}
+	return violations, nil
+}
@@ -0,0 +1,95 @@
+package hardware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// hardwareSet is a --set override of the hardware data.
+type hardwareSet struct {
+	path  []string
+	value interface{}
+}
+
+// parseSets parses the key=value overrides of the --set flags. The keys are
+// dot separated paths, like metadata.facility.facility_code.
+func parseSets(sets []string) ([]hardwareSet, error) {
+	parsed := make([]hardwareSet, 0, len(sets))
+	for _, set := range sets {
+		key, value, ok := strings.Cut(set, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid --set %q, expected key=value", set)
+		}
+		path := strings.Split(key, ".")
+		for _, p := range path {
+			if p == "" {
+				return nil, fmt.Errorf("invalid --set %q, the key has an empty segment", set)
+			}
+		}
+		parsed = append(parsed, hardwareSet{path: path, value: parseSetValue(value)})
+	}
+	return parsed, nil
+}
+
+// parseSetValue returns the value as a bool or a number when it looks like
+// one, and as a string otherwise. A double quoted value is always a string.
+func parseSetValue(value string) interface{} {
+	if s, err := strconv.Unquote(value); err == nil && strings.HasPrefix(value, `"`) {
+		return s
+	}
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil && !strings.ContainsAny(value, "xXpPnN") {
+		return f
+	}
+	return value
+}
+
+// applySets returns the hardware data with the overrides applied. The objects
+// missing along the paths are created.
+func applySets(data string, sets []hardwareSet) (string, error) {
+	if len(sets) == 0 {
+		return data, nil
+	}
+	hw := map[string]interface{}{}
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&hw); err != nil {
+		return "", fmt.Errorf("invalid json: %w", err)
+	}
+
+	for _, set := range sets {
+		obj := hw
+		for i, key := range set.path[:len(set.path)-1] {
+			next, ok := obj[key]
+			if !ok || next == nil {
+				next = map[string]interface{}{}
+				obj[key] = next
+			}
+			m, ok := next.(map[string]interface{})
+			if !ok {
+				return "", fmt.Errorf("cannot set %s: %s is not an object", strings.Join(set.path, "."), strings.Join(set.path[:i+1], "."))
+			}
+			obj = m
+		}
+		obj[set.path[len(set.path)-1]] = set.value
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(hw); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
@@ -0,0 +1,91 @@
+package hardware
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestApplySets(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		sets    []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no override",
+			data: `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94"}`,
+			want: `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94"}`,
+		},
+		{
+			name: "existing field",
+			data: `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","metadata":{"facility":{"facility_code":"onprem"}}}`,
+			sets: []string{"metadata.facility.facility_code=sjc1"},
+			want: `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","metadata":{"facility":{"facility_code":"sjc1"}}}`,
+		},
+		{
+			name: "nested path creation",
+			data: `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94"}`,
+			sets: []string{"metadata.facility.facility_code=sjc1", "metadata.instance.storage.disks=2"},
+			want: `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","metadata":{"facility":{"facility_code":"sjc1"},"instance":{"storage":{"disks":2}}}}`,
+		},
+		{
+			name: "value types",
+			data: `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","lease_time":86400}`,
+			sets: []string{"a=true", "b=false", "c=-3", "d=1.5", "e=08:00:27:00:00:01", `f="42"`, "g=", "h=NaN"},
+			want: `{"a":true,"b":false,"c":-3,"d":1.5,"e":"08:00:27:00:00:01","f":"42","g":"","h":"NaN","id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","lease_time":86400}`,
+		},
+		{
+			name:    "field is not an object",
+			data:    `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","metadata":"x"}`,
+			sets:    []string{"metadata.facility=sjc1"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			data:    `{"id":`,
+			sets:    []string{"id=x"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sets, err := parseSets(tt.sets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := applySets(tt.data, sets)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotJSON, wantJSON interface{}
+			if err := json.Unmarshal([]byte(got), &gotJSON); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &wantJSON); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(wantJSON, gotJSON); diff != "" {
+				t.Errorf("unexpected data (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseSets(t *testing.T) {
+	for _, set := range []string{"metadata", "metadata..facility=x", "=x", "metadata.=x"} {
+		if _, err := parseSets([]string{set}); err == nil {
+			t.Errorf("%s: expected an error, got nil", set)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package hardware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// createdFields are the creation timestamps the inventories add to the
+	// hardware data.
+	createdFields = []string{"createdAt", "created_at", "creationTimestamp"}
+	// updatedFields are the update timestamps the inventories add to the
+	// hardware data.
+	updatedFields = []string{"updatedAt", "updated_at"}
+)
+
+// defaultUpdatedField is the update timestamp set by --set-updated-now when
+// the hardware data has none.
+const defaultUpdatedField = "updatedAt"
+
+// applyTimestamps returns the hardware data without its timestamps when strip
+// is set, and with its update timestamps set to updated when it is not zero.
+// The timestamps are looked up at the top level of the hardware data and of
+// its metadata, which is the only place tink server keeps them. The existing
+// update timestamps are set, and the top-level updatedAt is added when there
+// is none. When both are requested, the update timestamps are set rather than
+// stripped.
+func applyTimestamps(data string, strip bool, updated time.Time) (string, error) {
+	if !strip && updated.IsZero() {
+		return data, nil
+	}
+	hw := map[string]interface{}{}
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&hw); err != nil {
+		return "", fmt.Errorf("invalid json: %w", err)
+	}
+
+	objects := []map[string]interface{}{hw}
+	if metadata, ok := hw["metadata"].(map[string]interface{}); ok {
+		objects = append(objects, metadata)
+	}
+	if strip {
+		stripped := createdFields
+		if updated.IsZero() {
+			stripped = append(append([]string{}, createdFields...), updatedFields...)
+		}
+		for _, obj := range objects {
+			for _, field := range stripped {
+				delete(obj, field)
+			}
+		}
+	}
+	if !updated.IsZero() {
+		value := updated.UTC().Format(time.RFC3339)
+		set := false
+		for _, obj := range objects {
+			for _, field := range updatedFields {
+				if _, ok := obj[field]; ok {
+					obj[field] = value
+					set = true
+				}
+			}
+		}
+		if !set {
+			hw[defaultUpdatedField] = value
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(hw); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
@@ -0,0 +1,124 @@
+package hardware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/tink/client"
+	hwpb "github.com/tinkerbell/tink/protos/hardware"
+	"google.golang.org/grpc"
+)
+
+func TestApplyTimestamps(t *testing.T) {
+	now := time.Date(2022, 5, 4, 10, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+	tests := []struct {
+		name    string
+		data    string
+		strip   bool
+		updated time.Time
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "unchanged",
+			data: `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","createdAt":"2019-01-01T00:00:00Z"}`,
+			want: `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","createdAt":"2019-01-01T00:00:00Z"}`,
+		},
+		{
+			name:  "strip",
+			data:  `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","createdAt":"2019-01-01T00:00:00Z","updatedAt":"2019-01-02T00:00:00Z","metadata":{"created_at":"2019-01-01T00:00:00Z","updated_at":"2019-01-02T00:00:00Z","facility":{"updated_at":"kept"}}}`,
+			strip: true,
+			want:  `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","metadata":{"facility":{"updated_at":"kept"}}}`,
+		},
+		{
+			name:    "set the existing update timestamps",
+			data:    `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","updatedAt":"2019-01-02T00:00:00Z","metadata":{"created_at":"2019-01-01T00:00:00Z","updated_at":"2019-01-02T00:00:00Z"}}`,
+			updated: now,
+			want:    `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","updatedAt":"2022-05-04T08:30:00Z","metadata":{"created_at":"2019-01-01T00:00:00Z","updated_at":"2022-05-04T08:30:00Z"}}`,
+		},
+		{
+			name:    "set a missing update timestamp",
+			data:    `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","metadata":{"created_at":"2019-01-01T00:00:00Z"}}`,
+			updated: now,
+			want:    `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","updatedAt":"2022-05-04T08:30:00Z","metadata":{"created_at":"2019-01-01T00:00:00Z"}}`,
+		},
+		{
+			name:    "strip and set",
+			data:    `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","updatedAt":"2019-01-02T00:00:00Z","metadata":{"creationTimestamp":"2019-01-01T00:00:00Z"}}`,
+			strip:   true,
+			updated: now,
+			want:    `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","updatedAt":"2022-05-04T08:30:00Z","metadata":{}}`,
+		},
+		{
+			name:    "strip and set without update timestamp",
+			data:    `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","metadata":{"creationTimestamp":"2019-01-01T00:00:00Z"}}`,
+			strip:   true,
+			updated: now,
+			want:    `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94","updatedAt":"2022-05-04T08:30:00Z","metadata":{}}`,
+		},
+		{
+			name:    "invalid json",
+			data:    `{"id":`,
+			strip:   true,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyTimestamps(tt.data, tt.strip, tt.updated)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotJSON, wantJSON interface{}
+			if err := json.Unmarshal([]byte(got), &gotJSON); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &wantJSON); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(wantJSON, gotJSON); diff != "" {
+				t.Errorf("unexpected data (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPushTimestamps(t *testing.T) {
+	input := `{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a90","metadata":{"created_at":"2019-01-01T00:00:00Z","updated_at":"2019-01-02T00:00:00Z"}}
+{"id":"0eba0bf8-3772-4b4a-ab9f-6ebe93b90a91","metadata":{"creationTimestamp":"2019-01-01T00:00:00Z","updatedAt":"2019-01-02T00:00:00Z","facility":{"facility_code":"onprem"}}}
+`
+	var metadata []string
+	client.HardwareClient = &hwpb.HardwareServiceClientMock{
+		PushFunc: func(ctx context.Context, in *hwpb.PushRequest, opts ...grpc.CallOption) (*hwpb.Empty, error) {
+			metadata = append(metadata, in.GetData().GetMetadata())
+			return &hwpb.Empty{}, nil
+		},
+	}
+
+	opts := &pushOptions{
+		stripTimestamps: true,
+		updatedAt:       time.Date(2022, 5, 4, 8, 30, 0, 0, time.UTC),
+	}
+	if err := opts.pushNDJSON(strings.NewReader(input), &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`{"updated_at":"2022-05-04T08:30:00Z"}`,
+		`{"facility":{"facility_code":"onprem"},"updatedAt":"2022-05-04T08:30:00Z"}`,
+	}
+	if diff := cmp.Diff(want, metadata); diff != "" {
+		t.Errorf("unexpected metadata pushed (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,134 @@
+package hardware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	hwpb "github.com/tinkerbell/tink/protos/hardware"
+	"gopkg.in/yaml.v3"
+)
+
+// yamlDocument is a hardware data document of a multi-document YAML input.
+type yamlDocument struct {
+	// index is the position of the document in the input, from 1.
+	index int
+	data  string
+}
+
+// splitYAMLDocuments splits the data on the '---' separator lines. Documents
+// containing only blanks and comments are dropped, the index of the others
+// still counts them so that it matches the position in the input.
+func splitYAMLDocuments(data string) []yamlDocument {
+	var (
+		docs  []yamlDocument
+		lines []string
+		index = 1
+	)
+	flush := func() {
+		doc := strings.Join(lines, "\n")
+		if !isEmptyYAMLDocument(doc) {
+			docs = append(docs, yamlDocument{index: index, data: doc})
+		}
+		lines = nil
+	}
+	for _, line := range strings.Split(data, "\n") {
+		if isYAMLSeparator(line) {
+			// The separator preceded by nothing but comments starts the
+			// first document.
+			first := len(docs) == 0 && index == 1 && isEmptyYAMLDocument(strings.Join(lines, "\n"))
+			flush()
+			if !first {
+				index++
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+	return docs
+}
+
+func isYAMLSeparator(line string) bool {
+	if !strings.HasPrefix(line, "---") {
+		return false
+	}
+	rest := strings.TrimSpace(line[3:])
+	return rest == "" || strings.HasPrefix(rest, "#")
+}
+
+func isEmptyYAMLDocument(data string) bool {
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// yamlToJSON converts a YAML hardware data document to JSON.
+func yamlToJSON(data string) (string, error) {
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(data), &v); err != nil {
+		return "", fmt.Errorf("invalid yaml: %w", err)
+	}
+	if _, ok := v.(map[string]interface{}); !ok {
+		return "", fmt.Errorf("invalid yaml: the document is not a mapping")
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("invalid yaml: %w", err)
+	}
+	return string(out), nil
+}
+
+// pushDocuments pushes the hardware data documents of a multi-document YAML
+// input. Every document is checked before anything is pushed: the invalid
+// ones are reported with their index and stop the push, unless skipInvalid is
+// set. The result of every document is written to out.
+func (o *pushOptions) pushDocuments(docs []yamlDocument, out io.Writer) error {
+	type valid struct {
+		index int
+		hw    *hwpb.Hardware
+	}
+	var hws []valid
+	invalid := 0
+	for _, doc := range docs {
+		name := fmt.Sprintf("document %d", doc.index)
+		data, err := yamlToJSON(doc.data)
+		if err == nil {
+			data, err = o.overrideData(data)
+		}
+		if err == nil {
+			err = o.checkSchema(out, name, data)
+		}
+		var hw *hwpb.Hardware
+		if err == nil {
+			hw, err = parseData(data)
+		}
+		if err != nil {
+			invalid++
+			if o.skipInvalid {
+				fmt.Fprintf(out, "%s: skipped: %v\n", name, err)
+			} else {
+				fmt.Fprintf(out, "%s: invalid: %v\n", name, err)
+			}
+			continue
+		}
+		hws = append(hws, valid{index: doc.index, hw: hw})
+	}
+	if invalid > 0 && !o.skipInvalid {
+		return fmt.Errorf("%d of %d documents are invalid, nothing was pushed", invalid, len(docs))
+	}
+
+	for i, v := range hws {
+		if err := o.push(v.hw); err != nil {
+			return fmt.Errorf("document %d: push failed after %d documents: %w", v.index, i, err)
+		}
+		o.infof(out, "document %d: pushed %s\n", v.index, v.hw.GetId())
+	}
+	o.infof(out, "%d documents pushed, %d skipped\n", len(hws), invalid)
+	return nil
+}
@@ -27,6 +27,10 @@ func NewTemplateCommand() *cobra.Command {
 	cmd.AddCommand(delete.NewDeleteCommand(template.NewDeleteOptions()))
 	cmd.AddCommand(template.NewListCommand())
 	cmd.AddCommand(template.NewUpdateCommand())
+	cmd.AddCommand(template.NewLintCommand())
+	cmd.AddCommand(template.NewExportCommand())
+	cmd.AddCommand(template.NewImportCommand())
+	cmd.AddCommand(template.NewValidateCommand())
 
 	// If the variable TINK_CLI_VERSION is set to 0.0.0 use the old get command.
 	// This is a way to keep retro-compatibility with the old get command.
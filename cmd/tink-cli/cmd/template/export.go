@@ -0,0 +1,148 @@
+package template
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/client"
+	"github.com/tinkerbell/tink/protos/template"
+)
+
+const (
+	formatYAML = "yaml"
+	formatJSON = "json"
+)
+
+// exportedTemplate is the JSON representation of an exported template.
+type exportedTemplate struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Data string `json:"data"`
+}
+
+type exportOptions struct {
+	dir    string
+	format string
+}
+
+// NewExportCommand returns the command that writes every template to a directory.
+func NewExportCommand() *cobra.Command {
+	opts := exportOptions{}
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "export all the templates to a directory",
+		Long: `The export command writes every template to its own file in a directory,
+named after the template. YAML files contain the template data as is, JSON
+files also record the ID and the name of the template:
+$ tink template export --dir ./backup
+$ tink template export --dir ./backup --format json
+`,
+		PreRunE: func(c *cobra.Command, args []string) error {
+			if opts.format != formatYAML && opts.format != formatJSON {
+				return fmt.Errorf("invalid --format %q, expected %s or %s", opts.format, formatYAML, formatJSON)
+			}
+			return nil
+		},
+		Run: func(c *cobra.Command, args []string) {
+			if err := exportTemplates(c.Context(), client.TemplateClient, opts, c.OutOrStdout()); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&opts.dir, "dir", "", "directory the templates are written to")
+	flags.StringVar(&opts.format, "format", formatYAML, "format of the exported files, yaml or json")
+	_ = cmd.MarkPersistentFlagRequired("dir")
+	return cmd
+}
+
+func exportTemplates(ctx context.Context, cl template.TemplateServiceClient, opts exportOptions, out io.Writer) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := os.MkdirAll(opts.dir, 0o750); err != nil {
+		return err
+	}
+
+	templates, err := listAllTemplates(ctx, cl)
+	if err != nil {
+		return err
+	}
+
+	used := map[string]struct{}{}
+	for _, tmp := range templates {
+		full, err := cl.GetTemplate(ctx, &template.GetRequest{
+			GetBy: &template.GetRequest_Id{Id: tmp.Id},
+		})
+		if err != nil {
+			return fmt.Errorf("get template %s: %w", tmp.Name, err)
+		}
+
+		data := []byte(full.Data)
+		if opts.format == formatJSON {
+			data, err = json.MarshalIndent(exportedTemplate{ID: tmp.Id, Name: tmp.Name, Data: full.Data}, "", "  ")
+			if err != nil {
+				return err
+			}
+		}
+
+		file := filepath.Join(opts.dir, exportFileName(tmp.Name, opts.format, used))
+		if err := os.WriteFile(file, data, 0o600); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s: exported to %s\n", tmp.Name, file)
+	}
+	return nil
+}
+
+// listAllTemplates returns every template known to the server, without their data.
+func listAllTemplates(ctx context.Context, cl template.TemplateServiceClient) ([]*template.WorkflowTemplate, error) {
+	list, err := cl.ListTemplates(ctx, &template.ListRequest{
+		FilterBy: &template.ListRequest_Name{
+			Name: "*",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var templates []*template.WorkflowTemplate
+	var tmp *template.WorkflowTemplate
+	for tmp, err = list.Recv(); err == nil && tmp.Name != ""; tmp, err = list.Recv() {
+		templates = append(templates, tmp)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return templates, nil
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// exportFileName returns a file name safe for every file system for the
+// template name. A numeric suffix is added to the names already used, which
+// are compared regardless of the case.
+func exportFileName(name, ext string, used map[string]struct{}) string {
+	base := strings.Trim(unsafeFileNameChars.ReplaceAllString(name, "_"), "._")
+	if base == "" {
+		base = "template"
+	}
+	candidate := base
+	for i := 1; ; i++ {
+		if _, ok := used[strings.ToLower(candidate)]; !ok {
+			break
+		}
+		candidate = base + "-" + strconv.Itoa(i)
+	}
+	used[strings.ToLower(candidate)] = struct{}{}
+	return candidate + "." + ext
+}
@@ -0,0 +1,100 @@
+package template
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/tink/protos/template"
+	"google.golang.org/grpc"
+)
+
+func templateServiceMock(templates []*template.WorkflowTemplate) *template.TemplateServiceClientMock {
+	return &template.TemplateServiceClientMock{
+		ListTemplatesFunc: func(ctx context.Context, in *template.ListRequest, opts ...grpc.CallOption) (template.TemplateService_ListTemplatesClient, error) {
+			counter := 0
+			return &template.TemplateService_ListTemplatesClientMock{
+				RecvFunc: func() (*template.WorkflowTemplate, error) {
+					counter++
+					if counter > len(templates) {
+						return nil, io.EOF
+					}
+					tmp := templates[counter-1]
+					return &template.WorkflowTemplate{Id: tmp.Id, Name: tmp.Name}, nil
+				},
+			}, nil
+		},
+		GetTemplateFunc: func(ctx context.Context, in *template.GetRequest, opts ...grpc.CallOption) (*template.WorkflowTemplate, error) {
+			for _, tmp := range templates {
+				if tmp.Id == in.GetId() {
+					return tmp, nil
+				}
+			}
+			return nil, os.ErrNotExist
+		},
+	}
+}
+
+func TestExportTemplates(t *testing.T) {
+	templates := []*template.WorkflowTemplate{
+		{Id: "1", Name: "ubuntu/focal", Data: "name: ubuntu/focal"},
+		{Id: "2", Name: "ubuntu:focal", Data: "name: ubuntu:focal"},
+		{Id: "3", Name: "Ubuntu_Focal", Data: "name: Ubuntu_Focal"},
+		{Id: "4", Name: "../..", Data: "name: ../.."},
+	}
+
+	t.Run("yaml", func(t *testing.T) {
+		dir := t.TempDir()
+		out := &bytes.Buffer{}
+		err := exportTemplates(context.Background(), templateServiceMock(templates), exportOptions{dir: dir, format: formatYAML}, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]string{
+			"ubuntu_focal.yaml":   "name: ubuntu/focal",
+			"ubuntu_focal-1.yaml": "name: ubuntu:focal",
+			"Ubuntu_Focal-2.yaml": "name: Ubuntu_Focal",
+			"template.yaml":       "name: ../..",
+		}
+		got := map[string]string{}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got[e.Name()] = string(data)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected files (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		dir := t.TempDir()
+		err := exportTemplates(context.Background(), templateServiceMock(templates[:1]), exportOptions{dir: dir, format: formatJSON}, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "ubuntu_focal.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got exportedTemplate
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(exportedTemplate{ID: "1", Name: "ubuntu/focal", Data: "name: ubuntu/focal"}, got); diff != "" {
+			t.Errorf("unexpected template (-want +got):\n%s", diff)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package template
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/client"
+	"github.com/tinkerbell/tink/protos/template"
+	"github.com/tinkerbell/tink/workflow"
+)
+
+type importOptions struct {
+	dir            string
+	updateExisting bool
+	failFast       bool
+}
+
+// NewImportCommand returns the command that creates templates from the files of a directory.
+func NewImportCommand() *cobra.Command {
+	opts := importOptions{}
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "import templates from a directory",
+		Long: `The import command creates a template for every *.yaml and *.json file of a
+directory, as written by the export command. Invalid files are reported and
+skipped, templates whose name already exists are only updated with --update-existing:
+$ tink template import --dir ./backup
+$ tink template import --dir ./backup --update-existing --fail-fast
+`,
+		Run: func(c *cobra.Command, args []string) {
+			if err := importTemplates(c.Context(), client.TemplateClient, opts, c.OutOrStdout()); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&opts.dir, "dir", "", "directory the templates are read from")
+	flags.BoolVar(&opts.updateExisting, "update-existing", false, "update the templates whose name already exists")
+	flags.BoolVar(&opts.failFast, "fail-fast", false, "stop at the first file that cannot be imported")
+	_ = cmd.MarkPersistentFlagRequired("dir")
+	return cmd
+}
+
+func importTemplates(ctx context.Context, cl template.TemplateServiceClient, opts importOptions, out io.Writer) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	entries, err := os.ReadDir(opts.dir)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for _, e := range entries {
+		ext := strings.TrimPrefix(filepath.Ext(e.Name()), ".")
+		if !e.IsDir() && (ext == formatYAML || ext == formatJSON) {
+			files = append(files, filepath.Join(opts.dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	templates, err := listAllTemplates(ctx, cl)
+	if err != nil {
+		return err
+	}
+	existing := map[string]string{}
+	for _, tmp := range templates {
+		existing[tmp.Name] = tmp.Id
+	}
+
+	failed := 0
+	for _, file := range files {
+		msg, err := importTemplate(ctx, cl, file, existing, opts.updateExisting)
+		if err != nil {
+			if opts.failFast {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+			fmt.Fprintf(out, "%s: skipped: %v\n", file, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(out, "%s: %s\n", file, msg)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files could not be imported", failed, len(files))
+	}
+	return nil
+}
+
+// importTemplate creates or updates the template stored in file and returns
+// a message describing what has been done. Created templates are added to existing.
+func importTemplate(ctx context.Context, cl template.TemplateServiceClient, file string, existing map[string]string, update bool) (string, error) {
+	content, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return "", err
+	}
+	data := string(content)
+	if filepath.Ext(file) == "."+formatJSON {
+		tmp := exportedTemplate{}
+		if err := json.Unmarshal(content, &tmp); err != nil {
+			return "", fmt.Errorf("invalid JSON: %w", err)
+		}
+		data = tmp.Data
+	}
+
+	wf, err := workflow.Parse([]byte(data))
+	if err != nil {
+		return "", err
+	}
+
+	if id, ok := existing[wf.Name]; ok {
+		if !update {
+			return "", fmt.Errorf("template %s already exists", wf.Name)
+		}
+		if _, err := cl.UpdateTemplate(ctx, &template.WorkflowTemplate{Id: id, Name: wf.Name, Data: data}); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("updated template %s (%s)", wf.Name, id), nil
+	}
+
+	res, err := cl.CreateTemplate(ctx, &template.WorkflowTemplate{Name: wf.Name, Data: data})
+	if err != nil {
+		return "", err
+	}
+	existing[wf.Name] = res.Id
+	return fmt.Sprintf("created template %s (%s)", wf.Name, res.Id), nil
+}
@@ -0,0 +1,113 @@
+package template
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tinkerbell/tink/protos/template"
+	"google.golang.org/grpc"
+)
+
+const importTemplateData = `version: "0.1"
+name: %s
+global_timeout: 600
+tasks:
+  - name: "hello world"
+    worker: "{{.device_1}}"
+    actions:
+      - name: "hello_world"
+        image: hello-world
+        timeout: 60
+`
+
+func writeImportFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestImportTemplates(t *testing.T) {
+	files := map[string]string{
+		"existing.yaml": fmt.Sprintf(importTemplateData, "existing"),
+		"new.json":      `{"id": "old", "name": "new", "data": ` + strconv.Quote(fmt.Sprintf(importTemplateData, "new")) + `}`,
+		"invalid.yaml":  "name: [",
+		"README.md":     "not a template",
+	}
+	existing := []*template.WorkflowTemplate{{Id: "1", Name: "existing"}}
+
+	tests := []struct {
+		name        string
+		opts        importOptions
+		wantErr     string
+		wantCreated []string
+		wantUpdated []string
+		wantOutput  []string
+	}{
+		{
+			name:        "skip invalid and existing",
+			wantErr:     "2 of 3 files could not be imported",
+			wantCreated: []string{"new"},
+			wantOutput:  []string{"existing.yaml: skipped: template existing already exists", "invalid.yaml: skipped:", "new.json: created template new (2)"},
+		},
+		{
+			name:        "update existing",
+			opts:        importOptions{updateExisting: true},
+			wantErr:     "1 of 3 files could not be imported",
+			wantCreated: []string{"new"},
+			wantUpdated: []string{"1"},
+			wantOutput:  []string{"existing.yaml: updated template existing (1)"},
+		},
+		{
+			name:        "fail fast",
+			opts:        importOptions{updateExisting: true, failFast: true},
+			wantErr:     "invalid.yaml",
+			wantUpdated: []string{"1"},
+			wantOutput:  []string{"existing.yaml: updated template existing (1)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created, updated []string
+			cl := templateServiceMock(existing)
+			cl.CreateTemplateFunc = func(ctx context.Context, in *template.WorkflowTemplate, opts ...grpc.CallOption) (*template.CreateResponse, error) {
+				created = append(created, in.Name)
+				return &template.CreateResponse{Id: "2"}, nil
+			}
+			cl.UpdateTemplateFunc = func(ctx context.Context, in *template.WorkflowTemplate, opts ...grpc.CallOption) (*template.Empty, error) {
+				updated = append(updated, in.Id)
+				return &template.Empty{}, nil
+			}
+
+			opts := tt.opts
+			opts.dir = writeImportFiles(t, files)
+			out := &bytes.Buffer{}
+			err := importTemplates(context.Background(), cl, opts, out)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if strings.Join(created, ",") != strings.Join(tt.wantCreated, ",") {
+				t.Errorf("expected created templates %v, got %v", tt.wantCreated, created)
+			}
+			if strings.Join(updated, ",") != strings.Join(tt.wantUpdated, ",") {
+				t.Errorf("expected updated templates %v, got %v", tt.wantUpdated, updated)
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("expected output to contain %q, got:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/workflow"
+)
+
+type lintOptions struct {
+	file     string
+	hardware string
+}
+
+// NewLintCommand returns the command that checks a template against hardware data.
+func NewLintCommand() *cobra.Command {
+	opts := lintOptions{}
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "lint a workflow template against hardware data",
+		Long: `The lint command reports the keys a template references but the hardware data does not define,
+and the hardware keys the template never uses. Unused keys are only warnings:
+# Pipe the template to lint it:
+$ cat /tmp/example.tmpl | tink template lint --hardware /tmp/hardware.json
+# Lint a template using the --file flag:
+$ tink template lint --file /tmp/example.tmpl --hardware /tmp/hardware.json
+`,
+		PreRunE: func(c *cobra.Command, args []string) error {
+			if !isInputFromPipe() && opts.file == "" {
+				return fmt.Errorf("%v requires the '--file' flag", c.UseLine())
+			}
+			return nil
+		},
+		Run: func(c *cobra.Command, args []string) {
+			var reader io.Reader
+			if isInputFromPipe() {
+				reader = os.Stdin
+			} else {
+				f, err := os.Open(filepath.Clean(opts.file))
+				if err != nil {
+					log.Fatal(err)
+				}
+				defer f.Close()
+				reader = f
+			}
+			data := readAll(reader)
+
+			hw, err := os.ReadFile(filepath.Clean(opts.hardware))
+			if err != nil {
+				log.Fatal(err)
+			}
+			hardware := map[string]interface{}{}
+			if err := json.Unmarshal(hw, &hardware); err != nil {
+				log.Fatalf("invalid hardware data: %v", err)
+			}
+
+			failed := false
+			for _, w := range workflow.Lint(string(data), hardware) {
+				fmt.Fprintln(c.OutOrStdout(), w)
+				if w.Kind != workflow.LintUnusedKey {
+					failed = true
+				}
+			}
+			if failed {
+				os.Exit(1)
+			}
+		},
+	}
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&opts.file, "file", "", "path to the template file")
+	flags.StringVar(&opts.hardware, "hardware", "", "path to the hardware data file (JSON)")
+	_ = cmd.MarkPersistentFlagRequired("hardware")
+	return cmd
+}
@@ -0,0 +1,165 @@
+package template
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/workflow"
+)
+
+type validateOptions struct {
+	file   string
+	stream bool
+}
+
+// NewValidateCommand returns the command that validates templates without creating them.
+func NewValidateCommand() *cobra.Command {
+	opts := validateOptions{}
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "validate workflow templates",
+		Long: `The validate command checks templates without creating them:
+# Validate a template using the --file flag:
+$ tink template validate --file /tmp/example.tmpl
+# Validate every '---' separated template read from stdin:
+$ generate-templates | tink template validate --stream
+`,
+		PreRunE: func(c *cobra.Command, args []string) error {
+			if opts.stream && opts.file != "" {
+				return fmt.Errorf("%v the '--stream' and '--file' flags are mutually exclusive", c.UseLine())
+			}
+			if !opts.stream && !isInputFromPipe() && opts.file == "" {
+				return fmt.Errorf("%v requires the '--file' flag", c.UseLine())
+			}
+			return nil
+		},
+		Run: func(c *cobra.Command, args []string) {
+			var reader io.Reader = os.Stdin
+			if opts.file != "" {
+				f, err := os.Open(filepath.Clean(opts.file))
+				if err != nil {
+					log.Fatal(err)
+				}
+				defer f.Close()
+				reader = f
+			}
+
+			if opts.stream {
+				if err := validateStream(reader, c.OutOrStdout()); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+			if _, err := workflow.Parse(readAll(reader)); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Fprintln(c.OutOrStdout(), "template is valid")
+		},
+	}
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&opts.file, "file", "", "path to the template file")
+	flags.BoolVar(&opts.stream, "stream", false, "validate every '---' separated template read from stdin")
+	return cmd
+}
+
+// document is a template read from a stream, line is where it starts.
+type document struct {
+	index int
+	line  int
+	data  []byte
+}
+
+// splitDocuments splits the stream on the '---' separator lines. Documents
+// containing only blanks and comments are dropped, the index of the others
+// still counts them so that it matches the position in the stream.
+func splitDocuments(r io.Reader) ([]document, error) {
+	var (
+		docs []document
+		buf  bytes.Buffer
+		doc  = document{index: 1, line: 1}
+		line = 0
+	)
+	flush := func() {
+		if !isEmptyDocument(buf.String()) {
+			doc.data = append([]byte(nil), buf.Bytes()...)
+			docs = append(docs, doc)
+		}
+		buf.Reset()
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if isDocumentSeparator(text) {
+			// The separator preceded by nothing but comments starts the
+			// first document.
+			index := doc.index + 1
+			if len(docs) == 0 && doc.index == 1 && isEmptyDocument(buf.String()) {
+				index = 1
+			}
+			flush()
+			doc = document{index: index, line: line + 1}
+			continue
+		}
+		buf.WriteString(text)
+		buf.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return docs, nil
+}
+
+func isDocumentSeparator(line string) bool {
+	if !strings.HasPrefix(line, "---") {
+		return false
+	}
+	rest := strings.TrimSpace(line[3:])
+	return rest == "" || strings.HasPrefix(rest, "#")
+}
+
+func isEmptyDocument(data string) bool {
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// validateStream validates every template of the stream, reports the invalid
+// ones and a summary to out, and returns an error if any of them is invalid.
+func validateStream(r io.Reader, out io.Writer) error {
+	docs, err := splitDocuments(r)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return fmt.Errorf("no template found")
+	}
+
+	failed := 0
+	for _, doc := range docs {
+		if _, err := workflow.Parse(doc.data); err != nil {
+			fmt.Fprintf(out, "document %d (line %d): %v\n", doc.index, doc.line, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(out, "FAIL: %d of %d templates are invalid\n", failed, len(docs))
+		return fmt.Errorf("%d of %d templates are invalid", failed, len(docs))
+	}
+	fmt.Fprintf(out, "PASS: %d templates are valid\n", len(docs))
+	return nil
+}
@@ -0,0 +1,69 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidateStream(t *testing.T) {
+	valid := fmt.Sprintf(importTemplateData, "valid")
+	tests := []struct {
+		name       string
+		input      string
+		wantErr    string
+		wantOutput []string
+	}{
+		{
+			name:       "all valid",
+			input:      valid + "---\n" + fmt.Sprintf(importTemplateData, "other"),
+			wantOutput: []string{"PASS: 2 templates are valid"},
+		},
+		{
+			name:       "empty documents",
+			input:      "---\n" + valid + "---\n\n# nothing here\n---\n--- # comment\n" + valid + "---\n",
+			wantOutput: []string{"PASS: 2 templates are valid"},
+		},
+		{
+			name:    "invalid documents",
+			input:   valid + "---\nname: [\n---\n" + valid + "---\n" + strings.Replace(valid, `version: "0.1"`, `version: "9"`, 1),
+			wantErr: "2 of 4 templates are invalid",
+			wantOutput: []string{
+				"document 2 (line 12): parsing yaml data",
+				"document 4 (line 25): validating workflow template",
+				"FAIL: 2 of 4 templates are invalid",
+			},
+		},
+		{
+			name:    "leading separator",
+			input:   "# generated\n---\n" + valid + "---\nname: [\n",
+			wantErr: "1 of 2 templates are invalid",
+			wantOutput: []string{
+				"document 2 (line 14): parsing yaml data",
+			},
+		},
+		{
+			name:    "no template",
+			input:   "---\n# nothing\n---\n",
+			wantErr: "no template found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			err := validateStream(strings.NewReader(tt.input), out)
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("expected output to contain %q, got:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
@@ -7,14 +7,20 @@ import (
 	"io"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/jedib0t/go-pretty/table"
 	"github.com/spf13/cobra"
 	"github.com/tinkerbell/tink/client"
 	"github.com/tinkerbell/tink/protos/workflow"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 var (
+	hTimestamp     = "Timestamp"
 	hWorkerID      = "Worker ID"
 	hTaskName      = "Task Name"
 	hActionName    = "Action Name"
@@ -23,45 +29,173 @@ var (
 	hStatus        = "Action Status"
 )
 
+type eventsOptions struct {
+	follow       bool
+	pollInterval time.Duration
+}
+
 func NewShowCommand() *cobra.Command {
+	opts := eventsOptions{}
 	cmd := &cobra.Command{
-		Use:                   "events [id]",
-		Short:                 "show all events for a workflow",
+		Use:   "events [id]",
+		Short: "show all events for a workflow",
+		Long: `The events command prints the events of a workflow. With --follow it keeps
+polling the workflow and prints the new events as they arrive, until it is
+interrupted:
+$ tink workflow events [id] --follow --poll-interval 5s
+`,
 		DisableFlagsInUseLine: true,
 		Example:               "tink workflow events [id]",
 		Args: func(c *cobra.Command, args []string) error {
 			if len(args) == 0 {
 				return fmt.Errorf("%v takes an arguments", c.UseLine())
 			}
+			if opts.follow && len(args) > 1 {
+				return fmt.Errorf("--follow takes a single workflow")
+			}
+			return nil
+		},
+		PreRunE: func(c *cobra.Command, args []string) error {
+			if opts.pollInterval <= 0 {
+				return fmt.Errorf("invalid --poll-interval %s, it must be positive", opts.pollInterval)
+			}
 			return nil
 		},
 		Run: func(c *cobra.Command, args []string) {
+			if opts.follow {
+				ctx := c.Context()
+				if ctx == nil {
+					ctx = context.Background()
+				}
+				ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+				defer stop()
+				if err := followEvents(ctx, client.WorkflowClient, args[0], opts.pollInterval, c.OutOrStdout()); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
 			t := table.NewWriter()
 			t.SetOutputMirror(os.Stdout)
-			t.AppendHeader(table.Row{hWorkerID, hTaskName, hActionName, hExecutionTime, hMessage, hStatus})
+			t.AppendHeader(table.Row{hTimestamp, hWorkerID, hTaskName, hActionName, hExecutionTime, hMessage, hStatus})
 			listEvents(t, args)
 			t.Render()
 		},
 	}
+	flags := cmd.Flags()
+	flags.BoolVarP(&opts.follow, "follow", "f", false, "keep printing the new events as they arrive")
+	flags.DurationVar(&opts.pollInterval, "poll-interval", 2*time.Second, "interval between two polls of the events with --follow")
 	return cmd
 }
 
 func listEvents(t table.Writer, args []string) {
 	for _, arg := range args {
-		req := workflow.GetRequest{Id: arg}
-		events, err := client.WorkflowClient.ShowWorkflowEvents(context.Background(), &req)
+		events, err := fetchEvents(context.Background(), client.WorkflowClient, arg)
 		if err != nil {
 			log.Fatal(err)
 		}
-		// var wf *workflow.Workflow
-		err = nil
-		for event, err := events.Recv(); err == nil && event != nil; event, err = events.Recv() {
+		for _, event := range events {
 			t.AppendRows([]table.Row{
-				{event.WorkerId, event.TaskName, event.ActionName, event.Seconds, event.Message, event.ActionStatus},
+				{eventTimestamp(event), event.WorkerId, event.TaskName, event.ActionName, event.Seconds, event.Message, event.ActionStatus},
 			})
 		}
-		if err != nil && !errors.Is(err, io.EOF) {
-			log.Fatal(err)
+	}
+}
+
+// followEvents prints the events of the workflow, then polls it every interval
+// and prints the events that arrived since the previous poll. It returns nil
+// once ctx is done.
+func followEvents(ctx context.Context, cl workflow.WorkflowServiceClient, id string, interval time.Duration, out io.Writer) error {
+	fmt.Fprintf(out, "%s\t%s\t%s\t%s\n", hTimestamp, hActionName, hStatus, hMessage)
+	// The creation time of the last event printed is the cursor of the next
+	// poll, no cursor asks for all the events. The events created at the
+	// cursor are returned again, so that the ones sharing the creation time of
+	// the last event printed are not missed; printed holds the events created
+	// at the cursor which are printed already.
+	var (
+		after   *timestamppb.Timestamp
+		printed []*workflow.WorkflowActionStatus
+	)
+	for {
+		events, err := fetchEventsAfter(ctx, cl, id, after)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		seen := append([]*workflow.WorkflowActionStatus(nil), printed...)
+		for _, event := range events {
+			if after != nil && event.CreatedAt.AsTime().Equal(after.AsTime()) {
+				if i := indexEvent(seen, event); i >= 0 {
+					seen = append(seen[:i], seen[i+1:]...)
+					continue
+				}
+			} else {
+				printed = nil
+			}
+			fmt.Fprintf(out, "%s\t%s\t%s\t%s\n", eventTimestamp(event), event.ActionName, event.ActionStatus, event.Message)
+			after = event.CreatedAt
+			printed = append(printed, event)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// indexEvent returns the index of the first event of events equal to event,
+// -1 when there is none.
+func indexEvent(events []*workflow.WorkflowActionStatus, event *workflow.WorkflowActionStatus) int {
+	for i, e := range events {
+		if proto.Equal(e, event) {
+			return i
+		}
+	}
+	return -1
+}
+
+func fetchEvents(ctx context.Context, cl workflow.WorkflowServiceClient, id string) ([]*workflow.WorkflowActionStatus, error) {
+	stream, err := cl.ShowWorkflowEvents(ctx, &workflow.GetRequest{Id: id})
+	if err != nil {
+		return nil, err
+	}
+	var events []*workflow.WorkflowActionStatus
+	for {
+		event, err := stream.Recv()
+		if errors.Is(err, io.EOF) || (err == nil && event == nil) {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
 		}
+		events = append(events, event)
+	}
+}
+
+func fetchEventsAfter(ctx context.Context, cl workflow.WorkflowServiceClient, id string, after *timestamppb.Timestamp) ([]*workflow.WorkflowActionStatus, error) {
+	stream, err := cl.ShowWorkflowEventsAfter(ctx, &workflow.WorkflowEventsRequest{Id: id, After: after})
+	if err != nil {
+		return nil, err
+	}
+	var events []*workflow.WorkflowActionStatus
+	for {
+		event, err := stream.Recv()
+		if errors.Is(err, io.EOF) || (err == nil && event == nil) {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+}
+
+func eventTimestamp(event *workflow.WorkflowActionStatus) string {
+	if event.CreatedAt == nil {
+		return ""
 	}
+	return event.CreatedAt.AsTime().Local().Format(time.RFC3339)
 }
@@ -0,0 +1,81 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/tinkerbell/tink/protos/workflow"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type eventsStream struct {
+	grpc.ClientStream
+	events []*workflow.WorkflowActionStatus
+}
+
+func (s *eventsStream) Recv() (*workflow.WorkflowActionStatus, error) {
+	if len(s.events) == 0 {
+		return nil, io.EOF
+	}
+	event := s.events[0]
+	s.events = s.events[1:]
+	return event, nil
+}
+
+func TestFollowEvents(t *testing.T) {
+	created := time.Date(2022, 10, 16, 12, 0, 0, 0, time.UTC)
+	events := []*workflow.WorkflowActionStatus{
+		{ActionName: "disk-wipe", ActionStatus: workflow.State_STATE_RUNNING, CreatedAt: timestamppb.New(created)},
+		{ActionName: "disk-wipe", ActionStatus: workflow.State_STATE_SUCCESS, CreatedAt: timestamppb.New(created.Add(time.Second))},
+		{ActionName: "install", ActionStatus: workflow.State_STATE_RUNNING, CreatedAt: timestamppb.New(created.Add(2 * time.Second))},
+		{ActionName: "install", ActionStatus: workflow.State_STATE_SUCCESS, CreatedAt: timestamppb.New(created.Add(2 * time.Second))},
+	}
+	// The workflow has one event on the first two polls, two more on the
+	// third, when the first one is truncated, and on the fourth one created
+	// at the same time as the last one printed. The last poll interrupts the
+	// command.
+	polls := [][]*workflow.WorkflowActionStatus{events[:1], events[:1], events[1:3], events[1:4]}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var cursors []*timestamppb.Timestamp
+	cl := &workflow.WorkflowServiceClientMock{
+		ShowWorkflowEventsAfterFunc: func(ctx context.Context, in *workflow.WorkflowEventsRequest, opts ...grpc.CallOption) (workflow.WorkflowService_ShowWorkflowEventsAfterClient, error) {
+			if len(cursors) == len(polls) {
+				cancel()
+				return nil, ctx.Err()
+			}
+			cursors = append(cursors, in.GetAfter())
+			var after []*workflow.WorkflowActionStatus
+			for _, event := range polls[len(cursors)-1] {
+				if in.GetAfter() == nil || !event.CreatedAt.AsTime().Before(in.GetAfter().AsTime()) {
+					after = append(after, event)
+				}
+			}
+			return &eventsStream{events: after}, nil
+		},
+	}
+
+	out := &bytes.Buffer{}
+	if err := followEvents(ctx, cl, "id", time.Millisecond, out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Timestamp\tAction Name\tAction Status\tMessage\n"
+	for _, event := range events {
+		want += eventTimestamp(event) + "\t" + event.ActionName + "\t" + event.ActionStatus.String() + "\t\n"
+	}
+	if got := out.String(); got != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+	wantCursors := []*timestamppb.Timestamp{nil, events[0].CreatedAt, events[0].CreatedAt, events[2].CreatedAt}
+	for i, cursor := range cursors {
+		if cursor != wantCursors[i] {
+			t.Errorf("unexpected cursor of poll %d: got %v, want %v", i, cursor, wantCursors[i])
+		}
+	}
+}
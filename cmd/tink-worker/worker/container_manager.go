@@ -1,9 +1,12 @@
 package worker
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
 	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
@@ -57,6 +60,8 @@ func (m *containerManager) CreateContainer(ctx context.Context, cmd []string, wf
 		Cmd:          cmd,
 		Tty:          true,
 		Env:          action.GetEnvironment(),
+		User:         action.GetUser(),
+		WorkingDir:   action.GetWorkingDir(),
 	}
 	if !captureLogs {
 		config.AttachStdout = false
@@ -74,15 +79,54 @@ func (m *containerManager) CreateContainer(ctx context.Context, cmd []string, wf
 		hostConfig.PidMode = container.PidMode(pidConfig)
 	}
 
+	if network := action.GetNetwork(); network != "" {
+		hostConfig.NetworkMode = container.NetworkMode(network)
+	}
+	hostConfig.CapAdd = action.GetCapabilities()
+
 	hostConfig.Binds = append(hostConfig.Binds, action.GetVolumes()...)
 	l.With("command", cmd).Info("creating container")
 	resp, err := m.cli.ContainerCreate(ctx, config, hostConfig, nil, nil, action.GetName())
 	if err != nil {
 		return "", errors.Wrap(err, "DOCKER CREATE")
 	}
+
+	if files := action.GetFiles(); len(files) > 0 {
+		archive, err := filesArchive(files)
+		if err != nil {
+			return "", errors.Wrap(err, "DOCKER COPY")
+		}
+		if err := m.cli.CopyToContainer(ctx, resp.ID, "/", archive, types.CopyToContainerOptions{}); err != nil {
+			return "", errors.Wrap(err, "DOCKER COPY")
+		}
+	}
 	return resp.ID, nil
 }
 
+// filesArchive returns a tar archive of the files, relative to the root of
+// the container.
+func filesArchive(files []*pb.WorkflowActionFile) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, f := range files {
+		hdr := &tar.Header{
+			Name: strings.TrimPrefix(path.Clean(f.GetPath()), "/"),
+			Mode: 0o644,
+			Size: int64(len(f.GetContent())),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write([]byte(f.GetContent())); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
 func (m *containerManager) StartContainer(ctx context.Context, id string) error {
 	m.getLogger(ctx).With("containerID", id).Debug("starting container")
 	return errors.Wrap(m.cli.ContainerStart(ctx, id, types.ContainerStartOptions{}), "DOCKER START")
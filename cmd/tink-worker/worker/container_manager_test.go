@@ -1,8 +1,10 @@
 package worker
 
 import (
+	"archive/tar"
 	"context"
 	"errors"
+	"io"
 	"testing"
 	"time"
 
@@ -156,6 +158,39 @@ func TestContainerManagerCreate(t *testing.T) {
 	}
 }
 
+func TestFilesArchive(t *testing.T) {
+	files := []*pb.WorkflowActionFile{
+		{Path: "/etc/motd", Content: "welcome to the rebellion"},
+		{Path: "/tmp/../opt/empty", Content: ""},
+	}
+	archive, err := filesArchive(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantNames := []string{"etc/motd", "opt/empty"}
+	tr := tar.NewReader(archive)
+	for i, f := range files {
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Name != wantNames[i] {
+			t.Errorf("Unexpected name: got '%s', expected '%s'", hdr.Name, wantNames[i])
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != f.GetContent() {
+			t.Errorf("Unexpected content: got '%s', expected '%s'", content, f.GetContent())
+		}
+	}
+	if _, err := tr.Next(); err != io.EOF {
+		t.Errorf("Expected the end of the archive, got %v", err)
+	}
+}
+
 func TestContainerManagerStart(t *testing.T) {
 	cases := []struct {
 		name        string
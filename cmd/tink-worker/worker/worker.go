@@ -296,6 +296,8 @@ func (w *Worker) ProcessWorkflowActions(ctx context.Context) error {
 					continue
 				case pb.State_STATE_TIMEOUT:
 					continue
+				case pb.State_STATE_CANCELLED:
+					continue
 				default:
 					nextAction = actions.GetActionList()[wfContext.GetCurrentActionIndex()]
 					actionIndex = int(wfContext.GetCurrentActionIndex())
@@ -373,24 +375,29 @@ func (w *Worker) ProcessWorkflowActions(ctx context.Context) error {
 					WorkerId:   action.GetWorkerId(),
 				}
 
+				actionStatus.ActionStatus = pb.State_STATE_SUCCESS
+				actionStatus.Message = "finished execution successfully"
 				if err != nil || st != pb.State_STATE_SUCCESS {
-					if st == pb.State_STATE_TIMEOUT {
-						actionStatus.ActionStatus = pb.State_STATE_TIMEOUT
-					} else {
-						actionStatus.ActionStatus = pb.State_STATE_FAILED
-					}
-					l = l.With("actionStatus", actionStatus.ActionStatus.String())
-					l.Error(err)
-					if reportErr := w.reportActionStatus(ctx, actionStatus); reportErr != nil {
-						exitWithGrpcError(reportErr, l)
+					if !action.GetIgnoreFailure() {
+						if st == pb.State_STATE_TIMEOUT {
+							actionStatus.ActionStatus = pb.State_STATE_TIMEOUT
+						} else {
+							actionStatus.ActionStatus = pb.State_STATE_FAILED
+						}
+						l = l.With("actionStatus", actionStatus.ActionStatus.String())
+						l.Error(err)
+						if reportErr := w.reportActionStatus(ctx, actionStatus); reportErr != nil {
+							exitWithGrpcError(reportErr, l)
+						}
+						delete(workflowcontexts, wfID)
+						return err
 					}
-					delete(workflowcontexts, wfID)
-					return err
+					// A failed or timed out state would end the workflow on the
+					// server, so the ignored failure is reported as a success.
+					l.With("actionStatus", st.String(), "error", err).Info("ignoring action failure")
+					actionStatus.Message = fmt.Sprintf("execution ended in %s, failure ignored", st)
 				}
 
-				actionStatus.ActionStatus = pb.State_STATE_SUCCESS
-				actionStatus.Message = "finished execution successfully"
-
 				err = w.reportActionStatus(ctx, actionStatus)
 				if err != nil {
 					exitWithGrpcError(err, l)
@@ -0,0 +1,198 @@
+package db
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// CachedDB is a Database that keeps the hardware returned by GetByMAC, GetByIP
+// and GetByID in a LRU cache. Entries expire after a TTL and are invalidated
+// when the hardware they belong to is inserted or deleted through the CachedDB.
+// Lookups that do not find any hardware are not cached. Every other method is
+// served by the wrapped Database.
+type CachedDB struct {
+	Database
+
+	size int
+	ttl  time.Duration
+
+	mu         sync.Mutex
+	lru        *list.List
+	entries    map[string]*list.Element
+	generation uint64
+}
+
+type cacheEntry struct {
+	key     string
+	id      string
+	data    string
+	expires time.Time
+}
+
+// NewCachedDB returns a CachedDB in front of inner holding at most size
+// entries for ttl. A size lower than 1 disables the cache.
+func NewCachedDB(inner Database, size int, ttl time.Duration) *CachedDB {
+	return &CachedDB{
+		Database: inner,
+		size:     size,
+		ttl:      ttl,
+		lru:      list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+// GetByMAC : get data by machine mac.
+func (c *CachedDB) GetByMAC(ctx context.Context, mac string) (string, error) {
+	return c.get("mac/"+mac, func() (string, error) {
+		return c.Database.GetByMAC(ctx, mac)
+	})
+}
+
+// GetByIP : get data by machine ip.
+func (c *CachedDB) GetByIP(ctx context.Context, ip string) (string, error) {
+	return c.get("ip/"+ip, func() (string, error) {
+		return c.Database.GetByIP(ctx, ip)
+	})
+}
+
+// GetByID : get data by machine id.
+func (c *CachedDB) GetByID(ctx context.Context, id string) (string, error) {
+	return c.get("id/"+id, func() (string, error) {
+		return c.Database.GetByID(ctx, id)
+	})
+}
+
+// InsertIntoDB : insert data into hardware table.
+func (c *CachedDB) InsertIntoDB(ctx context.Context, data string) error {
+	err := c.Database.InsertIntoDB(ctx, data)
+	c.invalidate(data)
+	return err
+}
+
+// DeleteFromDB : delete data from hardware table.
+func (c *CachedDB) DeleteFromDB(ctx context.Context, id string) error {
+	err := c.Database.DeleteFromDB(ctx, id)
+	c.invalidate(`{"id":"` + id + `"}`)
+	return err
+}
+
+func (c *CachedDB) get(key string, fetch func() (string, error)) (string, error) {
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		if time.Now().Before(entry.expires) {
+			c.lru.MoveToFront(el)
+			c.mu.Unlock()
+			return entry.data, nil
+		}
+		c.remove(el)
+	}
+	generation := c.generation
+	c.mu.Unlock()
+
+	data, err := fetch()
+	if err != nil || data == "" {
+		return data, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Do not cache what was read while the hardware was being modified, the
+	// data may already be stale.
+	if generation != c.generation || c.size < 1 {
+		return data, nil
+	}
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:     key,
+		id:      hardwareKeys(data).id,
+		data:    data,
+		expires: time.Now().Add(c.ttl),
+	})
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+	return data, nil
+}
+
+// invalidate drops the entries of the hardware described by data, as well
+// as the entries of the MAC and IP addresses data now claims.
+func (c *CachedDB) invalidate(data string) {
+	keys := hardwareKeys(data)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		if entry := el.Value.(*cacheEntry); keys.id != "" && entry.id == keys.id {
+			c.remove(el)
+		}
+		el = next
+	}
+	for _, key := range keys.lookups {
+		if el, ok := c.entries[key]; ok {
+			c.remove(el)
+		}
+	}
+}
+
+func (c *CachedDB) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+type cacheKeys struct {
+	id      string
+	lookups []string
+}
+
+// hardwareKeys returns the ID of the hardware and the cache keys it can be
+// looked up with. Invalid data has no keys.
+func hardwareKeys(data string) cacheKeys {
+	hw := struct {
+		ID      string `json:"id"`
+		Network struct {
+			Interfaces []struct {
+				DHCP struct {
+					MAC string `json:"mac"`
+					IP  struct {
+						Address string `json:"address"`
+					} `json:"ip"`
+				} `json:"dhcp"`
+			} `json:"interfaces"`
+		} `json:"network"`
+		Instance struct {
+			IPAddresses []struct {
+				Address string `json:"address"`
+			} `json:"ip_addresses"`
+		} `json:"instance"`
+	}{}
+	if err := json.Unmarshal([]byte(data), &hw); err != nil {
+		return cacheKeys{}
+	}
+
+	keys := cacheKeys{id: hw.ID}
+	if hw.ID != "" {
+		keys.lookups = append(keys.lookups, "id/"+hw.ID)
+	}
+	for _, iface := range hw.Network.Interfaces {
+		if iface.DHCP.MAC != "" {
+			keys.lookups = append(keys.lookups, "mac/"+iface.DHCP.MAC)
+		}
+		if iface.DHCP.IP.Address != "" {
+			keys.lookups = append(keys.lookups, "ip/"+iface.DHCP.IP.Address)
+		}
+	}
+	for _, ip := range hw.Instance.IPAddresses {
+		if ip.Address != "" {
+			keys.lookups = append(keys.lookups, "ip/"+ip.Address)
+		}
+	}
+	return keys
+}
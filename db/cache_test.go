@@ -0,0 +1,202 @@
+package db_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinkerbell/tink/db"
+)
+
+// hardwareStore is an in-memory hardware backend counting the lookups that
+// reach it.
+type hardwareStore struct {
+	db.Database
+
+	mu      sync.Mutex
+	data    map[string]string
+	lookups int
+}
+
+func newHardwareStore() *hardwareStore {
+	return &hardwareStore{data: map[string]string{}}
+}
+
+func (s *hardwareStore) InsertIntoDB(_ context.Context, data string) error {
+	hw := struct {
+		ID string `json:"id"`
+	}{}
+	if err := json.Unmarshal([]byte(data), &hw); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[hw.ID] = data
+	return nil
+}
+
+func (s *hardwareStore) DeleteFromDB(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, id)
+	return nil
+}
+
+func (s *hardwareStore) GetByID(_ context.Context, id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookups++
+	return s.data[id], nil
+}
+
+func (s *hardwareStore) GetByMAC(_ context.Context, mac string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookups++
+	for _, data := range s.data {
+		hw := struct {
+			Network struct {
+				Interfaces []struct {
+					DHCP struct {
+						MAC string `json:"mac"`
+					} `json:"dhcp"`
+				} `json:"interfaces"`
+			} `json:"network"`
+		}{}
+		_ = json.Unmarshal([]byte(data), &hw)
+		for _, iface := range hw.Network.Interfaces {
+			if iface.DHCP.MAC == mac {
+				return data, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func (s *hardwareStore) lookupCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lookups
+}
+
+func hardwareWithMAC(id, mac, hostname string) string {
+	return fmt.Sprintf(`{"id":%q,"metadata":{"hostname":%q},"network":{"interfaces":[{"dhcp":{"mac":%q}}]}}`, id, hostname, mac)
+}
+
+func TestCachedDB(t *testing.T) {
+	ctx := context.Background()
+	const (
+		id  = "fde7c87c-d154-447e-9fce-7eb7bdec90c0"
+		mac = "ec:0d:9a:c0:01:0c"
+	)
+
+	t.Run("cache hit", func(t *testing.T) {
+		store := newHardwareStore()
+		cached := db.NewCachedDB(store, 10, time.Minute)
+		_ = cached.InsertIntoDB(ctx, hardwareWithMAC(id, mac, "one"))
+
+		for i := 0; i < 3; i++ {
+			if _, err := cached.GetByMAC(ctx, mac); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if count := store.lookupCount(); count != 1 {
+			t.Errorf("expected a single lookup, got %d", count)
+		}
+	})
+
+	t.Run("invalidation on update", func(t *testing.T) {
+		store := newHardwareStore()
+		cached := db.NewCachedDB(store, 10, time.Minute)
+		_ = cached.InsertIntoDB(ctx, hardwareWithMAC(id, mac, "one"))
+		_, _ = cached.GetByMAC(ctx, mac)
+		_, _ = cached.GetByID(ctx, id)
+
+		updated := hardwareWithMAC(id, mac, "two")
+		if err := cached.InsertIntoDB(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := cached.GetByMAC(ctx, mac); got != updated {
+			t.Errorf("expected the updated hardware by MAC, got %s", got)
+		}
+		if got, _ := cached.GetByID(ctx, id); got != updated {
+			t.Errorf("expected the updated hardware by ID, got %s", got)
+		}
+	})
+
+	t.Run("invalidation when a MAC moves", func(t *testing.T) {
+		const other = "0d1ab2ee-5cb1-4b6b-bb0a-8a9a2d3f1c0e"
+		store := newHardwareStore()
+		cached := db.NewCachedDB(store, 10, time.Minute)
+		_ = cached.InsertIntoDB(ctx, hardwareWithMAC(id, mac, "one"))
+		_, _ = cached.GetByMAC(ctx, mac)
+
+		_ = store.DeleteFromDB(ctx, id)
+		moved := hardwareWithMAC(other, mac, "other")
+		_ = cached.InsertIntoDB(ctx, moved)
+		if got, _ := cached.GetByMAC(ctx, mac); got != moved {
+			t.Errorf("expected the hardware now owning the MAC, got %s", got)
+		}
+	})
+
+	t.Run("invalidation on delete", func(t *testing.T) {
+		store := newHardwareStore()
+		cached := db.NewCachedDB(store, 10, time.Minute)
+		_ = cached.InsertIntoDB(ctx, hardwareWithMAC(id, mac, "one"))
+		_, _ = cached.GetByMAC(ctx, mac)
+
+		_ = cached.DeleteFromDB(ctx, id)
+		if got, _ := cached.GetByMAC(ctx, mac); got != "" {
+			t.Errorf("expected no hardware, got %s", got)
+		}
+	})
+
+	t.Run("expiration", func(t *testing.T) {
+		store := newHardwareStore()
+		cached := db.NewCachedDB(store, 10, 10*time.Millisecond)
+		_ = cached.InsertIntoDB(ctx, hardwareWithMAC(id, mac, "one"))
+		_, _ = cached.GetByMAC(ctx, mac)
+		time.Sleep(20 * time.Millisecond)
+		_, _ = cached.GetByMAC(ctx, mac)
+		if count := store.lookupCount(); count != 2 {
+			t.Errorf("expected the expired entry to be looked up again, got %d lookups", count)
+		}
+	})
+
+	t.Run("eviction", func(t *testing.T) {
+		store := newHardwareStore()
+		cached := db.NewCachedDB(store, 1, time.Minute)
+		_ = cached.InsertIntoDB(ctx, hardwareWithMAC(id, mac, "one"))
+		_, _ = cached.GetByMAC(ctx, mac)
+		_, _ = cached.GetByID(ctx, id)
+		_, _ = cached.GetByMAC(ctx, mac)
+		if count := store.lookupCount(); count != 3 {
+			t.Errorf("expected the least recently used entry to be evicted, got %d lookups", count)
+		}
+	})
+}
+
+func BenchmarkCachedDBGetByMAC(b *testing.B) {
+	ctx := context.Background()
+	store := newHardwareStore()
+	cached := db.NewCachedDB(store, 1000, time.Minute)
+	macs := make([]string, 100)
+	for i := range macs {
+		macs[i] = fmt.Sprintf("ec:0d:9a:c0:01:%02x", i)
+		_ = cached.InsertIntoDB(ctx, hardwareWithMAC(fmt.Sprintf("hw-%d", i), macs[i], "host"))
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, err := cached.GetByMAC(ctx, macs[i%len(macs)]); err != nil {
+				b.Fatal(err)
+			}
+			i++
+		}
+	})
+}
@@ -17,6 +17,15 @@ import (
 	pb "github.com/tinkerbell/tink/protos/workflow"
 )
 
+var (
+	// ErrNotFound is returned when the requested record does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrNoWork is returned when there is no action for a worker to claim.
+	ErrNoWork = errors.New("no work available")
+	// ErrConflict is returned when a record collides with an existing one.
+	ErrConflict = errors.New("conflict")
+)
+
 // Database interface for tinkerbell database operations.
 type Database interface {
 	hardware
@@ -49,9 +58,11 @@ type workflow interface {
 	GetWorkflow(ctx context.Context, id string) (Workflow, error)
 	DeleteWorkflow(ctx context.Context, id string, state int32) error
 	ListWorkflows(fn func(wf Workflow) error) error
+	ListWorkflowsCtx(ctx context.Context, fn func(wf Workflow) error) error
 	UpdateWorkflow(ctx context.Context, wf Workflow, state int32) error
 	InsertIntoWorkflowEventTable(ctx context.Context, wfEvent *pb.WorkflowActionStatus, t time.Time) error
 	ShowWorkflowEvents(wfID string, fn func(wfs *pb.WorkflowActionStatus) error) error
+	ShowWorkflowEventsAfter(ctx context.Context, wfID string, after time.Time, fn func(wfs *pb.WorkflowActionStatus) error) error
 }
 
 // WorkerWorkflow is an interface for methods invoked by APIs that the worker calls.
@@ -66,28 +77,101 @@ type WorkerWorkflow interface {
 
 // TinkDB implements the Database interface.
 type TinkDB struct {
-	instance *sql.DB
+	instance querier
+	db       *sql.DB
 	logger   log.Logger
+	metrics  *metrics
 }
 
+// Option configures the TinkDB returned by Connect.
+type Option func(*TinkDB)
+
 // Connect returns a connection to postgres database.
-func Connect(db *sql.DB, lg log.Logger) *TinkDB {
-	return &TinkDB{instance: db, logger: lg}
+func Connect(db *sql.DB, lg log.Logger, opts ...Option) *TinkDB {
+	d := &TinkDB{db: db, logger: lg}
+	for _, opt := range opts {
+		opt(d)
+	}
+	d.instance = d.instrument(dbQuerier{db})
+	return d
+}
+
+// maxConnectBackoff caps the delay between two pings of ConnectWithRetry.
+const maxConnectBackoff = time.Minute
+
+// ConnectWithRetry pings the database until it answers and returns a
+// connection to it, like Connect. The delay between two pings starts at
+// backoff and doubles after every failure, up to a minute. It gives up with
+// the last ping error after maxAttempts pings, or when ctx is done; a
+// maxAttempts lower than 1 retries until ctx is done.
+func ConnectWithRetry(ctx context.Context, db *sql.DB, lg log.Logger, maxAttempts int, backoff time.Duration, opts ...Option) (*TinkDB, error) {
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return Connect(db, lg, opts...), nil
+		}
+		if maxAttempts > 0 && attempt >= maxAttempts {
+			return nil, errors.Wrapf(err, "database not ready after %d attempts", attempt)
+		}
+		lg.With("attempt", attempt, "retry_in", backoff.String(), "error", err).Info("database not ready")
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, errors.Wrapf(ctx.Err(), "database not ready after %d attempts: %v", attempt, err)
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
 }
 
 func (d *TinkDB) Migrate() (int, error) {
-	return migrate.Exec(d.instance, "postgres", migration.GetMigrations(), migrate.Up)
+	return migrate.Exec(d.db, "postgres", migration.GetMigrations(), migrate.Up)
 }
 
 func (d *TinkDB) CheckRequiredMigrations() (int, error) {
 	migrations := migration.GetMigrations().Migrations
-	records, err := migrate.GetMigrationRecords(d.instance, "postgres")
+	records, err := migrate.GetMigrationRecords(d.db, "postgres")
 	if err != nil {
 		return 0, err
 	}
 	return len(migrations) - len(records), nil
 }
 
+// MigrationState tells whether a migration is applied to the database.
+type MigrationState struct {
+	ID        string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// MigrationStatus returns the state of every migration known to this version
+// of tink, in the order they are applied.
+func (d *TinkDB) MigrationStatus() ([]MigrationState, error) {
+	records, err := migrate.GetMigrationRecords(d.db, "postgres")
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[string]time.Time, len(records))
+	for _, r := range records {
+		applied[r.Id] = r.AppliedAt
+	}
+
+	migrations, err := migration.GetMigrations().FindMigrations()
+	if err != nil {
+		return nil, err
+	}
+	states := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		at, ok := applied[m.Id]
+		states = append(states, MigrationState{ID: m.Id, Applied: ok, AppliedAt: at})
+	}
+	return states, nil
+}
+
 // Error returns the underlying cause for error.
 func Error(err error) *pq.Error {
 	if pqErr, ok := errors.Cause(err).(*pq.Error); ok {
@@ -96,7 +180,7 @@ func Error(err error) *pq.Error {
 	return nil
 }
 
-func get(ctx context.Context, db *sql.DB, query string, args ...interface{}) (string, error) {
+func get(ctx context.Context, db querier, query string, args ...interface{}) (string, error) {
 	row := db.QueryRowContext(ctx, query, args...)
 
 	buf := []byte{}
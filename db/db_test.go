@@ -3,12 +3,14 @@ package db_test
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/packethost/pkg/log"
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
 	"github.com/tinkerbell/tink/db"
@@ -82,3 +84,47 @@ func NewPostgresDatabaseClient(ctx context.Context, t *testing.T, req NewPostgre
 		return postgresC.Terminate(ctx)
 	}
 }
+
+// flakyConnector is a driver.Connector failing to connect the first failures
+// times, like a database that is still starting.
+type flakyConnector struct {
+	failures int
+	attempts int
+}
+
+func (c *flakyConnector) Connect(context.Context) (driver.Conn, error) {
+	c.attempts++
+	if c.attempts <= c.failures {
+		return nil, errors.New("connection refused")
+	}
+	return fakeConn{}, nil
+}
+
+func (c *flakyConnector) Driver() driver.Driver { return nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not implemented") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not implemented") }
+
+func TestConnectWithRetry(t *testing.T) {
+	ctx := context.Background()
+
+	conn := &flakyConnector{failures: 3}
+	tinkDB, err := db.ConnectWithRetry(ctx, sql.OpenDB(conn), log.Test(t, "db-test"), 5, time.Millisecond)
+	assert.NoError(t, err)
+	assert.NotNil(t, tinkDB)
+	assert.Equal(t, 4, conn.attempts)
+
+	conn = &flakyConnector{failures: 3}
+	_, err = db.ConnectWithRetry(ctx, sql.OpenDB(conn), log.Test(t, "db-test"), 3, time.Millisecond)
+	assert.EqualError(t, err, "database not ready after 3 attempts: connection refused")
+	assert.Equal(t, 3, conn.attempts)
+
+	cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	conn = &flakyConnector{failures: 1000}
+	_, err = db.ConnectWithRetry(cctx, sql.OpenDB(conn), log.Test(t, "db-test"), 0, 10*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
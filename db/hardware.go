@@ -3,9 +3,14 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -68,6 +73,39 @@ func (d TinkDB) InsertIntoDB(ctx context.Context, data string) error {
 	return nil
 }
 
+// UpsertHardware stores the hardware data under the ID, replacing the data
+// already stored under it, if any, in a single statement. A deleted hardware is
+// restored. The ID of the data, when set, has to match the ID.
+func (d TinkDB) UpsertHardware(ctx context.Context, id, data string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return errors.Wrapf(err, "invalid hardware id %q", id)
+	}
+	var hw struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(data), &hw); err != nil {
+		return errors.Wrap(err, "invalid hardware data")
+	}
+	if hw.ID != "" && hw.ID != id {
+		return errors.Errorf("hardware data id %s does not match id %s", hw.ID, id)
+	}
+
+	_, err := d.instance.ExecContext(ctx, `
+	INSERT INTO
+		hardware (inserted_at, id, data)
+	VALUES
+		($1, $2, $3)
+	ON CONFLICT (id)
+	DO
+	UPDATE SET
+		(inserted_at, deleted_at, data) = ($1, NULL, $3);
+	`, time.Now(), id, data)
+	if err != nil {
+		return errors.Wrap(err, "INSERT")
+	}
+	return nil
+}
+
 // GetByMAC : get data by machine mac.
 func (d TinkDB) GetByMAC(ctx context.Context, mac string) (string, error) {
 	arg := `
@@ -188,3 +226,166 @@ func (d TinkDB) GetAll(fn func([]byte) error) error {
 	}
 	return err
 }
+
+// hardwarePathRegexp matches the dot separated paths accepted by DistinctHardwareValues.
+var hardwarePathRegexp = regexp.MustCompile(`^[A-Za-z0-9_]+(\.[A-Za-z0-9_]+)*$`)
+
+// DistinctHardwareValues returns the sorted distinct values found at the dot
+// separated jsonPath of the hardware data, like "metadata.facility.facility_code".
+// The metadata is stored as a JSON string, so paths starting with "metadata."
+// are looked up inside of it. Hardware without a value at jsonPath is ignored.
+func (d TinkDB) DistinctHardwareValues(ctx context.Context, jsonPath string) ([]string, error) {
+	if !hardwarePathRegexp.MatchString(jsonPath) {
+		return nil, errors.Errorf("invalid hardware data path: %q", jsonPath)
+	}
+
+	document := "data"
+	path := strings.Split(jsonPath, ".")
+	if path[0] == "metadata" && len(path) > 1 {
+		document = "NULLIF(data ->> 'metadata', '')::jsonb"
+		path = path[1:]
+	}
+	rows, err := d.instance.QueryContext(ctx, `
+	SELECT DISTINCT value
+	FROM (
+		SELECT `+document+` #>> $1 AS value
+		FROM hardware
+		WHERE
+			deleted_at IS NULL
+	) AS v
+	WHERE
+		value IS NOT NULL
+	ORDER BY value;
+	`, pq.Array(path))
+	if err != nil {
+		return nil, errors.Wrap(err, "SELECT DISTINCT")
+	}
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			err = errors.Wrap(err, "SELECT DISTINCT")
+			d.logger.Error(err)
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// likeEscaper escapes the LIKE metacharacters, using the default escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchHardwareByHostnamePrefix returns the data of at most limit hardware with
+// a network interface whose hostname starts with prefix. The prefix is matched
+// literally, % and _ are not wildcards.
+func (d TinkDB) SearchHardwareByHostnamePrefix(ctx context.Context, prefix string, limit int) ([]string, error) {
+	if limit < 1 {
+		return nil, errors.Errorf("invalid limit %d, it must be positive", limit)
+	}
+	rows, err := d.instance.QueryContext(ctx, `
+	SELECT data
+	FROM hardware
+	WHERE
+		deleted_at IS NULL
+	AND EXISTS (
+		SELECT 1
+		FROM jsonb_array_elements(COALESCE(data #> '{network,interfaces}', '[]')) AS interface
+		WHERE
+			interface #>> '{dhcp,hostname}' LIKE $1
+	)
+	ORDER BY inserted_at
+	LIMIT $2;
+	`, likeEscaper.Replace(prefix)+"%", limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "SELECT")
+	}
+	defer rows.Close()
+
+	hardware := []string{}
+	for rows.Next() {
+		var data string
+		if err = rows.Scan(&data); err != nil {
+			err = errors.Wrap(err, "SELECT")
+			d.logger.Error(err)
+			return nil, err
+		}
+		hardware = append(hardware, data)
+	}
+	return hardware, rows.Err()
+}
+
+// QueryHardware calls fn with the data of every hardware containing the
+// predicate, in the sense of the jsonb @> operator, like
+// {"network": {"interfaces": [{"dhcp": {"arch": "x86_64"}}]}}. The metadata
+// is stored as a JSON string, so a "metadata" object of the predicate is
+// matched against its decoded content. An empty predicate matches every
+// hardware. A positive limit caps the number of hardware returned, 0 returns
+// all of them.
+func (d TinkDB) QueryHardware(ctx context.Context, jsonbPredicate map[string]interface{}, fn func([]byte) error, limit int) error {
+	if limit < 0 {
+		return errors.Errorf("invalid limit %d, it must not be negative", limit)
+	}
+	var maxRows sql.NullInt64
+	if limit > 0 {
+		maxRows = sql.NullInt64{Int64: int64(limit), Valid: true}
+	}
+
+	predicate := map[string]interface{}{}
+	metadata := map[string]interface{}{}
+	for k, v := range jsonbPredicate {
+		if m, ok := v.(map[string]interface{}); ok && k == "metadata" {
+			metadata = m
+			continue
+		}
+		predicate[k] = v
+	}
+	dataPredicate, err := json.Marshal(predicate)
+	if err != nil {
+		return errors.Wrap(err, "invalid hardware predicate")
+	}
+	args := []interface{}{maxRows, string(dataPredicate)}
+
+	// The metadata is only decoded when the predicate looks into it.
+	metadataCondition := ""
+	if len(metadata) > 0 {
+		metadataPredicate, err := json.Marshal(metadata)
+		if err != nil {
+			return errors.Wrap(err, "invalid hardware predicate")
+		}
+		metadataCondition = `
+	AND
+		COALESCE(NULLIF(data ->> 'metadata', '')::jsonb, '{}') @> $3::jsonb`
+		args = append(args, string(metadataPredicate))
+	}
+
+	rows, err := d.instance.QueryContext(ctx, `
+	SELECT data
+	FROM hardware
+	WHERE
+		deleted_at IS NULL
+	AND
+		data @> $2::jsonb`+metadataCondition+`
+	ORDER BY inserted_at
+	LIMIT $1;
+	`, args...)
+	if err != nil {
+		return errors.Wrap(err, "SELECT")
+	}
+	defer rows.Close()
+
+	buf := []byte{}
+	for rows.Next() {
+		if err = rows.Scan(&buf); err != nil {
+			err = errors.Wrap(err, "SELECT")
+			d.logger.Error(err)
+			return err
+		}
+		if err = fn(buf); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
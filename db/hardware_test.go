@@ -545,6 +545,256 @@ func TestGetByMAC_WithNonExistingMAC(t *testing.T) {
 	}
 }
 
+func TestDistinctHardwareValues(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	for ii, facility := range []string{"onprem", "sv15", "onprem", "da11"} {
+		hw := readHardwareData("./testdata/hardware.json")
+		hw.Id = uuid.New().String()
+		hw.Network.Interfaces[0].Dhcp.Mac = strings.Replace(hw.Network.Interfaces[0].Dhcp.Mac, "00", fmt.Sprintf("0%d", ii), 1)
+		hw.Metadata = strings.Replace(hw.Metadata, `"onprem"`, fmt.Sprintf("%q", facility), 1)
+		if err := createHardware(ctx, tinkDB, hw); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		{path: "metadata.facility.facility_code", want: []string{"da11", "onprem", "sv15"}},
+		{path: "metadata.facility.plan_slug", want: []string{"c2.medium.x86"}},
+		{path: "network.interfaces.0.dhcp.arch", want: []string{"x86_64"}},
+		{path: "metadata.not_there", want: []string{}},
+		{path: "metadata.facility') OR 1=1 --", wantErr: true},
+		{path: "", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := tinkDB.DistinctHardwareValues(ctx, test.path)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got nil", test.path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.path, err)
+		}
+		if dif := cmp.Diff(test.want, got); dif != "" {
+			t.Errorf("%s: %s", test.path, dif)
+		}
+	}
+}
+
+func TestSearchHardwareByHostnamePrefix(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	for ii, hostname := range []string{"server001", "server_01", "server%01", "serverX01", "db01"} {
+		hw := readHardwareData("./testdata/hardware.json")
+		hw.Id = uuid.New().String()
+		hw.Network.Interfaces[0].Dhcp.Mac = strings.Replace(hw.Network.Interfaces[0].Dhcp.Mac, "00", fmt.Sprintf("0%d", ii), 1)
+		hw.Network.Interfaces[0].Dhcp.Hostname = hostname
+		if err := createHardware(ctx, tinkDB, hw); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		prefix  string
+		limit   int
+		want    []string
+		wantErr bool
+	}{
+		{prefix: "server", limit: 10, want: []string{"server001", "server_01", "server%01", "serverX01"}},
+		{prefix: "server", limit: 2, want: []string{"server001", "server_01"}},
+		{prefix: "server_", limit: 10, want: []string{"server_01"}},
+		{prefix: "server%", limit: 10, want: []string{"server%01"}},
+		{prefix: "db", limit: 10, want: []string{"db01"}},
+		{prefix: "web", limit: 10, want: []string{}},
+		{prefix: "server", limit: 0, wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := tinkDB.SearchHardwareByHostnamePrefix(ctx, test.prefix, test.limit)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got nil", test.prefix)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.prefix, err)
+		}
+		hostnames := []string{}
+		for _, data := range got {
+			hw := &hardware.Hardware{}
+			if err := json.Unmarshal([]byte(data), hw); err != nil {
+				t.Fatal(err)
+			}
+			hostnames = append(hostnames, hw.Network.Interfaces[0].Dhcp.Hostname)
+		}
+		if dif := cmp.Diff(test.want, hostnames); dif != "" {
+			t.Errorf("%s: %s", test.prefix, dif)
+		}
+	}
+}
+
+func TestQueryHardware(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	hosts := []struct {
+		hostname, facility, arch string
+	}{
+		{"server01", "sjc1", "x86_64"},
+		{"server02", "sjc1", "aarch64"},
+		{"server03", "da11", "x86_64"},
+	}
+	for ii, host := range hosts {
+		hw := readHardwareData("./testdata/hardware.json")
+		hw.Id = uuid.New().String()
+		hw.Network.Interfaces[0].Dhcp.Mac = strings.Replace(hw.Network.Interfaces[0].Dhcp.Mac, "00", fmt.Sprintf("0%d", ii), 1)
+		hw.Network.Interfaces[0].Dhcp.Hostname = host.hostname
+		hw.Network.Interfaces[0].Dhcp.Arch = host.arch
+		hw.Metadata = strings.Replace(hw.Metadata, `"onprem"`, fmt.Sprintf("%q", host.facility), 1)
+		if err := createHardware(ctx, tinkDB, hw); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	query := func(predicate string, limit int) ([]string, error) {
+		t.Helper()
+		p := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(predicate), &p); err != nil {
+			t.Fatal(err)
+		}
+		hostnames := []string{}
+		err := tinkDB.QueryHardware(ctx, p, func(data []byte) error {
+			hw := &hardware.Hardware{}
+			if err := json.Unmarshal(data, hw); err != nil {
+				return err
+			}
+			hostnames = append(hostnames, hw.Network.Interfaces[0].Dhcp.Hostname)
+			return nil
+		}, limit)
+		return hostnames, err
+	}
+
+	tests := []struct {
+		name      string
+		predicate string
+		limit     int
+		want      []string
+		wantErr   bool
+	}{
+		{name: "everything", predicate: `{}`, want: []string{"server01", "server02", "server03"}},
+		{name: "metadata", predicate: `{"metadata": {"facility": {"facility_code": "sjc1"}}}`, want: []string{"server01", "server02"}},
+		{name: "nested array", predicate: `{"network": {"interfaces": [{"dhcp": {"arch": "x86_64"}}]}}`, want: []string{"server01", "server03"}},
+		{
+			name:      "data and metadata",
+			predicate: `{"network": {"interfaces": [{"dhcp": {"arch": "x86_64"}}]}, "metadata": {"facility": {"facility_code": "sjc1"}}}`,
+			want:      []string{"server01"},
+		},
+		{name: "no match", predicate: `{"metadata": {"facility": {"facility_code": "ams1"}}}`, want: []string{}},
+		{name: "empty metadata", predicate: `{"metadata": {}}`, want: []string{"server01", "server02", "server03"}},
+		{name: "limit", predicate: `{}`, limit: 2, want: []string{"server01", "server02"}},
+		{name: "invalid limit", predicate: `{}`, limit: -1, wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := query(test.predicate, test.limit)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got nil", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if dif := cmp.Diff(test.want, got); dif != "" {
+			t.Errorf("%s: %s", test.name, dif)
+		}
+	}
+}
+
+func TestUpsertHardware(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dbCon, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	hw := readHardwareData("./testdata/hardware.json")
+	for _, hostname := range []string{"server01", "server02"} {
+		hw.Network.Interfaces[0].Dhcp.Hostname = hostname
+		data, err := json.Marshal(hw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tinkDB.UpsertHardware(ctx, hw.Id, string(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var count int
+	if err := dbCon.QueryRow("SELECT count(*) FROM hardware WHERE id = $1", hw.Id).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 hardware row, got %d", count)
+	}
+	data, err := tinkDB.GetByID(ctx, hw.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &hardware.Hardware{}
+	if err := json.Unmarshal([]byte(data), got); err != nil {
+		t.Fatal(err)
+	}
+	if hostname := got.Network.Interfaces[0].Dhcp.Hostname; hostname != "server02" {
+		t.Errorf("expected the latest data with hostname server02, got %s", hostname)
+	}
+
+	if err := tinkDB.UpsertHardware(ctx, uuid.New().String(), data); err == nil {
+		t.Error("expected an error for data with another id, got nil")
+	}
+}
+
 func readHardwareData(file string) *hardware.Hardware {
 	data, err := os.ReadFile(file)
 	if err != nil {
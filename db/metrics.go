@@ -0,0 +1,179 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WithMetrics returns the Option enabling the Prometheus metrics of the
+// database queries, registered with registerer: a counter of the queries by
+// TinkDB method and result, and a histogram of their durations by method. The
+// collectors already registered by another TinkDB are shared. The metrics
+// are disabled by default.
+//
+// Every query is recorded, including the ones run in a transaction, under
+// the innermost exported TinkDB method running it.
+func WithMetrics(registerer prometheus.Registerer) (Option, error) {
+	m, err := newMetrics(registerer)
+	if err != nil {
+		return nil, err
+	}
+	return func(d *TinkDB) { d.metrics = m }, nil
+}
+
+// metrics holds the Prometheus collectors of the database operations.
+type metrics struct {
+	operations *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+}
+
+func newMetrics(registerer prometheus.Registerer) (*metrics, error) {
+	operations := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tink_db_operations_total",
+		Help: "Number of database queries by method and result.",
+	}, []string{"method", "result"})
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tink_db_operation_duration_seconds",
+		Help:    "Duration of database queries by method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+
+	c, err := register(registerer, operations)
+	if err != nil {
+		return nil, err
+	}
+	operations = c.(*prometheus.CounterVec)
+	c, err = register(registerer, duration)
+	if err != nil {
+		return nil, err
+	}
+	duration = c.(*prometheus.HistogramVec)
+	return &metrics{operations: operations, duration: duration}, nil
+}
+
+// register registers the collector, or returns the collector already
+// registered by another TinkDB.
+func register(registerer prometheus.Registerer, c prometheus.Collector) (prometheus.Collector, error) {
+	if err := registerer.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			return are.ExistingCollector, nil
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// observe records the result and the duration of the query of the method
+// started at start.
+func (m *metrics) observe(method string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	m.operations.WithLabelValues(method, result).Inc()
+	m.duration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// instrument returns q recording the metrics of its queries, or q itself when
+// the metrics are disabled.
+func (d TinkDB) instrument(q querier) querier {
+	if d.metrics == nil {
+		return q
+	}
+	return metricsQuerier{querier: q, metrics: d.metrics}
+}
+
+// metricsQuerier records the metrics of the queries of the wrapped querier.
+type metricsQuerier struct {
+	querier
+	metrics *metrics
+}
+
+func (q metricsQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, err := q.querier.ExecContext(ctx, query, args...)
+	q.metrics.observe(callerMethod(), start, err)
+	return res, err
+}
+
+func (q metricsQuerier) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := q.querier.Query(query, args...)
+	q.metrics.observe(callerMethod(), start, err)
+	return rows, err
+}
+
+func (q metricsQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := q.querier.QueryContext(ctx, query, args...)
+	q.metrics.observe(callerMethod(), start, err)
+	return rows, err
+}
+
+func (q metricsQuerier) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := q.querier.QueryRowContext(ctx, query, args...)
+	q.metrics.observe(callerMethod(), start, row.Err())
+	return row
+}
+
+func (q metricsQuerier) BeginTx(ctx context.Context, opts *sql.TxOptions) (transaction, error) {
+	tx, err := q.querier.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return metricsTransaction{transaction: tx, metrics: q.metrics}, nil
+}
+
+// metricsTransaction records the metrics of the queries of the wrapped
+// transaction.
+type metricsTransaction struct {
+	transaction
+	metrics *metrics
+}
+
+func (tx metricsTransaction) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, err := tx.transaction.ExecContext(ctx, query, args...)
+	tx.metrics.observe(callerMethod(), start, err)
+	return res, err
+}
+
+// tinkDBMethodPrefixes are the prefixes of the function names of the TinkDB
+// methods, with a value and a pointer receiver.
+var tinkDBMethodPrefixes = []string{
+	reflect.TypeOf(TinkDB{}).PkgPath() + ".TinkDB.",
+	reflect.TypeOf(TinkDB{}).PkgPath() + ".(*TinkDB).",
+}
+
+// callerMethod returns the name of the innermost exported TinkDB method in the
+// call stack, which is the operation the query is made for.
+func callerMethod() string {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		for _, prefix := range tinkDBMethodPrefixes {
+			if !strings.HasPrefix(frame.Function, prefix) {
+				continue
+			}
+			// The closures of a method are named after it.
+			name := strings.SplitN(strings.TrimPrefix(frame.Function, prefix), ".", 2)[0]
+			if name != "" && unicode.IsUpper(rune(name[0])) {
+				return name
+			}
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
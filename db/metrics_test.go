@@ -0,0 +1,128 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/packethost/pkg/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tinkerbell/tink/db"
+)
+
+func TestWithMetrics(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dbCon, _, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	reg := prometheus.NewRegistry()
+	withMetrics, err := db.WithMetrics(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tinkDB := db.Connect(dbCon, log.Test(t, "db-test"), withMetrics)
+
+	hw := readHardwareData("./testdata/hardware.json")
+	data, err := json.Marshal(hw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tinkDB.InsertIntoDB(ctx, string(data)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tinkDB.GetByID(ctx, hw.Id); err != nil {
+		t.Fatal(err)
+	}
+	// The methods of TinkDB outside of the Database interface and the
+	// queries run in a transaction are recorded as well.
+	if _, err := tinkDB.DistinctHardwareValues(ctx, "metadata.facility.facility_code"); err != nil {
+		t.Fatal(err)
+	}
+	err = tinkDB.WithTx(ctx, func(tx db.Database) error {
+		_, err := tx.GetByID(ctx, hw.Id)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := operationCount(t, reg, "InsertIntoDB", "success"); got != 1 {
+		t.Errorf("expected 1 successful InsertIntoDB, got %v", got)
+	}
+	if got := operationCount(t, reg, "GetByID", "success"); got != 2 {
+		t.Errorf("expected 2 successful GetByID, got %v", got)
+	}
+	if got := operationCount(t, reg, "DistinctHardwareValues", "success"); got != 1 {
+		t.Errorf("expected 1 successful DistinctHardwareValues, got %v", got)
+	}
+}
+
+func TestWithMetricsSharedRegisterer(t *testing.T) {
+	// Nothing listens on port 1, so every operation fails without a database.
+	dbCon, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbCon.Close()
+
+	reg := prometheus.NewRegistry()
+	for i := 0; i < 2; i++ {
+		withMetrics, err := db.WithMetrics(reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tinkDB := db.Connect(dbCon, log.Test(t, "db-test"), withMetrics)
+		if _, err := tinkDB.GetByID(context.Background(), "0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94"); err == nil {
+			t.Fatal("expected an error, got nil")
+		}
+	}
+
+	if got := operationCount(t, reg, "GetByID", "error"); got != 2 {
+		t.Errorf("expected 2 failed GetByID, got %v", got)
+	}
+}
+
+func TestWithMetricsRegisterError(t *testing.T) {
+	// A collector with the same name but another help conflicts with the
+	// database metrics.
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "tink_db_operations_total",
+		Help: "Something else.",
+	}))
+	if _, err := db.WithMetrics(reg); err == nil {
+		t.Fatal("expected a registration error, got nil")
+	}
+}
+
+func operationCount(t *testing.T, reg *prometheus.Registry, method, result string) float64 {
+	t.Helper()
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() != "tink_db_operations_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["method"] == method && labels["result"] == result {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
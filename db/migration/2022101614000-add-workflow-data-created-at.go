@@ -0,0 +1,15 @@
+package migration
+
+import migrate "github.com/rubenv/sql-migrate"
+
+// Get2022101614000 records when every version of the workflow data is
+// stored. The versions stored before this migration have no creation time.
+func Get2022101614000() *migrate.Migration {
+	return &migrate.Migration{
+		Id: "2022101614000-add-workflow-data-created-at",
+		Up: []string{`
+ALTER TABLE workflow_data ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+ALTER TABLE workflow_data ALTER COLUMN created_at SET DEFAULT NOW();
+`},
+	}
+}
@@ -0,0 +1,21 @@
+package migration
+
+import migrate "github.com/rubenv/sql-migrate"
+
+// Get2022101615000 adds the labels used to categorize the templates, like
+// os=ubuntu or role=worker.
+func Get2022101615000() *migrate.Migration {
+	return &migrate.Migration{
+		Id: "2022101615000-add-template-labels",
+		Up: []string{`
+CREATE TABLE IF NOT EXISTS template_label (
+	template_id UUID NOT NULL REFERENCES template (id) ON DELETE CASCADE
+	, key VARCHAR(200) NOT NULL
+	, value VARCHAR(200) NOT NULL
+	, PRIMARY KEY (template_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_template_label_key_value ON template_label (key, value);
+`},
+	}
+}
@@ -12,6 +12,8 @@ var migrations = []func() *migrate.Migration{
 	Get202012091055,
 	Get2020121691335,
 	Get2021032610300,
+	Get2022101614000,
+	Get2022101615000,
 }
 
 func GetMigrations() *migrate.MemoryMigrationSource {
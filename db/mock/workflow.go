@@ -54,6 +54,11 @@ func (d DB) ListWorkflows(_ func(wf db.Workflow) error) error {
 	return nil
 }
 
+// ListWorkflowsCtx returns all workflows.
+func (d DB) ListWorkflowsCtx(_ context.Context, _ func(wf db.Workflow) error) error {
+	return nil
+}
+
 // UpdateWorkflow updates a given workflow.
 func (d DB) UpdateWorkflow(_ context.Context, _ db.Workflow, _ int32) error {
 	return nil
@@ -83,3 +88,8 @@ func (d DB) InsertIntoWorkflowEventTable(ctx context.Context, wfEvent *pb.Workfl
 func (d DB) ShowWorkflowEvents(_ string, _ func(wfs *pb.WorkflowActionStatus) error) error {
 	return nil
 }
+
+// ShowWorkflowEventsAfter returns the events created at or after a cursor.
+func (d DB) ShowWorkflowEventsAfter(_ context.Context, _ string, _ time.Time, _ func(wfs *pb.WorkflowActionStatus) error) error {
+	return nil
+}
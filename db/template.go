@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang/protobuf/ptypes/timestamp"
@@ -100,6 +101,41 @@ func (d TinkDB) GetTemplate(ctx context.Context, fields map[string]string, delet
 	return &tb.WorkflowTemplate{}, err
 }
 
+// GetTemplateByName returns the template with the name, like GetTemplate with
+// the name field, or ErrNotFound when there is none.
+func (d TinkDB) GetTemplateByName(ctx context.Context, name string, deleted bool) (*tb.WorkflowTemplate, error) {
+	wtmpl, err := d.GetTemplate(ctx, map[string]string{"name": name}, deleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &tb.WorkflowTemplate{}, ErrNotFound
+	}
+	return wtmpl, err
+}
+
+// GetTemplateData returns the data of a template which is not deleted. It
+// avoids building the whole WorkflowTemplate when only the data is needed.
+func (d TinkDB) GetTemplateData(ctx context.Context, id uuid.UUID) (string, error) {
+	row := d.instance.QueryRowContext(ctx, `
+	SELECT data
+	FROM template
+	WHERE
+		id = $1
+	AND
+		deleted_at IS NULL;
+	`, id)
+
+	var data string
+	err := row.Scan(&data)
+	if err == nil {
+		return data, nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	err = errors.Wrap(err, "SELECT")
+	d.logger.Error(err)
+	return "", err
+}
+
 // DeleteTemplate deletes a workflow template by id.
 func (d TinkDB) DeleteTemplate(ctx context.Context, id string) error {
 	tx, err := d.instance.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
@@ -129,6 +165,37 @@ func (d TinkDB) DeleteTemplate(ctx context.Context, id string) error {
 	return nil
 }
 
+// TemplateInUseError is returned when deleting a template referenced by workflows.
+type TemplateInUseError struct {
+	Workflows int
+}
+
+func (e *TemplateInUseError) Error() string {
+	return fmt.Sprintf("template in use by %d workflows", e.Workflows)
+}
+
+// DeleteTemplateGuarded deletes a workflow template by id, like DeleteTemplate,
+// unless workflows reference it, in which case a *TemplateInUseError is
+// returned. Setting force deletes the template anyway.
+func (d TinkDB) DeleteTemplateGuarded(ctx context.Context, id string, force bool) error {
+	templateID, err := uuid.Parse(id)
+	if err != nil {
+		return errors.Wrapf(err, "invalid template id %s", id)
+	}
+	return d.withTx(ctx, func(txDB TinkDB) error {
+		if !force {
+			count, err := txDB.CountWorkflowsByTemplate(ctx, templateID)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return &TemplateInUseError{Workflows: count}
+			}
+		}
+		return txDB.DeleteTemplate(ctx, id)
+	}, WithIsolation(sql.LevelSerializable), WithSerializationRetries(serializationRetries))
+}
+
 // ListTemplates returns all saved templates.
 func (d TinkDB) ListTemplates(filter string, fn func(id, n string, in, del *timestamp.Timestamp) error) error {
 	rows, err := d.instance.Query(`
@@ -174,6 +241,111 @@ func (d TinkDB) ListTemplates(filter string, fn func(id, n string, in, del *time
 	return err
 }
 
+// SetTemplateLabels replaces the labels of a template which is not deleted.
+// An empty map removes every label. It returns ErrNotFound when the template
+// does not exist.
+func (d TinkDB) SetTemplateLabels(ctx context.Context, id uuid.UUID, labels map[string]string) error {
+	for key := range labels {
+		if strings.TrimSpace(key) == "" {
+			return errors.New("template label key cannot be empty")
+		}
+	}
+	return d.withTx(ctx, func(txDB TinkDB) error {
+		var exists bool
+		err := txDB.instance.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM template WHERE id = $1 AND deleted_at IS NULL
+		);
+		`, id).Scan(&exists)
+		if err != nil {
+			return errors.Wrap(err, "SELECT")
+		}
+		if !exists {
+			return errors.Wrapf(ErrNotFound, "template %s", id)
+		}
+
+		if _, err := txDB.instance.ExecContext(ctx, `DELETE FROM template_label WHERE template_id = $1;`, id); err != nil {
+			return errors.Wrap(err, "DELETE")
+		}
+		for key, value := range labels {
+			_, err := txDB.instance.ExecContext(ctx, `
+			INSERT INTO
+				template_label (template_id, key, value)
+			VALUES
+				($1, $2, $3);
+			`, id, key, value)
+			if err != nil {
+				return errors.Wrap(err, "INSERT")
+			}
+		}
+		return nil
+	}, WithIsolation(sql.LevelSerializable), WithSerializationRetries(serializationRetries))
+}
+
+// GetTemplateLabels returns the labels of a template.
+func (d TinkDB) GetTemplateLabels(ctx context.Context, id uuid.UUID) (map[string]string, error) {
+	rows, err := d.instance.QueryContext(ctx, `
+	SELECT key, value
+	FROM template_label
+	WHERE
+		template_id = $1;
+	`, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "SELECT")
+	}
+	defer rows.Close()
+
+	labels := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err = rows.Scan(&key, &value); err != nil {
+			return nil, errors.Wrap(err, "SELECT")
+		}
+		labels[key] = value
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "SELECT")
+	}
+	return labels, nil
+}
+
+// ListTemplatesByLabel returns the templates which are not deleted and have
+// the label key set to value, ordered by name.
+func (d TinkDB) ListTemplatesByLabel(ctx context.Context, key, value string, fn func(id, n string, in, del *timestamp.Timestamp) error) error {
+	rows, err := d.instance.QueryContext(ctx, `
+	SELECT t.id, t.name, t.created_at, t.updated_at
+	FROM template t
+	JOIN template_label l ON l.template_id = t.id
+	WHERE
+		l.key = $1 AND l.value = $2
+	AND
+		t.deleted_at IS NULL
+	ORDER BY t.name;
+	`, key, value)
+	if err != nil {
+		return errors.Wrap(err, "SELECT")
+	}
+	defer rows.Close()
+
+	var (
+		id        string
+		name      string
+		createdAt time.Time
+		updatedAt time.Time
+	)
+	for rows.Next() {
+		if err = rows.Scan(&id, &name, &createdAt, &updatedAt); err != nil {
+			err = errors.Wrap(err, "SELECT")
+			d.logger.Error(err)
+			return err
+		}
+		if err = fn(id, name, timestamppb.New(createdAt), timestamppb.New(updatedAt)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 // UpdateTemplate update a given template.
 func (d TinkDB) UpdateTemplate(ctx context.Context, name string, data string, id uuid.UUID) error {
 	tx, err := d.instance.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
@@ -200,3 +372,52 @@ func (d TinkDB) UpdateTemplate(ctx context.Context, name string, data string, id
 	}
 	return nil
 }
+
+// maxTemplateNameLength is the maximum length of a template name, the
+// default of the workflow validation.
+const maxTemplateNameLength = 200
+
+// RenameTemplate changes the name of a template which is not deleted, keeping
+// its ID and data. It returns ErrNotFound when the template does not exist and
+// ErrConflict when another template already has the name.
+func (d TinkDB) RenameTemplate(ctx context.Context, id uuid.UUID, newName string) error {
+	if strings.TrimSpace(newName) == "" {
+		return errors.New("template name cannot be empty")
+	}
+	if len(newName) > maxTemplateNameLength {
+		return errors.Errorf("template name cannot have more than %d characters: %s", maxTemplateNameLength, newName)
+	}
+
+	tx, err := d.instance.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return errors.Wrap(err, "BEGIN transaction")
+	}
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE template
+	SET
+		updated_at = NOW(), name = $2
+	WHERE
+		id = $1
+	AND
+		deleted_at IS NULL;
+	`, id, newName)
+	if pqErr := Error(err); pqErr != nil && pqErr.Code.Name() == "unique_violation" {
+		_ = tx.Rollback()
+		return errors.Wrapf(ErrConflict, "template name %s is already taken", newName)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Wrap(err, "UPDATE")
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		_ = tx.Rollback()
+		return errors.Wrapf(ErrNotFound, "template %s", id)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "COMMIT")
+	}
+	return nil
+}
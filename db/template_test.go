@@ -3,6 +3,7 @@ package db_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"strings"
@@ -249,6 +250,221 @@ func TestDeleteTemplate(t *testing.T) {
 	}
 }
 
+func TestDeleteTemplateGuarded(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := createWorkflow(ctx, tinkDB, in); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := tinkDB.DeleteTemplateGuarded(ctx, in.template.ID, false)
+	var inUse *db.TemplateInUseError
+	if !errors.As(err, &inUse) {
+		t.Fatalf("expected a TemplateInUseError, got %v", err)
+	}
+	if err.Error() != "template in use by 2 workflows" {
+		t.Errorf("unexpected error message: %s", err)
+	}
+	if _, err := tinkDB.GetTemplateData(ctx, uuid.MustParse(in.template.ID)); err != nil {
+		t.Errorf("expected the template to still exist, got %v", err)
+	}
+
+	if err := tinkDB.DeleteTemplateGuarded(ctx, in.template.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tinkDB.GetTemplateData(ctx, uuid.MustParse(in.template.ID)); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("expected the template to be deleted, got %v", err)
+	}
+
+	unused := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+	unused.ID = uuid.New().String()
+	unused.Name = fmt.Sprintf("id_%d", rand.Int())
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, unused); err != nil {
+		t.Fatal(err)
+	}
+	if err := tinkDB.DeleteTemplateGuarded(ctx, unused.ID, false); err != nil {
+		t.Errorf("expected an unused template to be deleted, got %v", err)
+	}
+}
+
+func TestRenameTemplate(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	first := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+	first.ID = uuid.New().String()
+	first.Name = "first"
+	second := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+	second.ID = uuid.New().String()
+	second.Name = "second"
+	for _, w := range []*workflow.Workflow{first, second} {
+		if err := createTemplateFromWorkflowType(ctx, tinkDB, w); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tinkDB.RenameTemplate(ctx, uuid.MustParse(first.ID), "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	tmp, err := tinkDB.GetTemplate(ctx, map[string]string{"id": first.ID}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmp.Name != "renamed" {
+		t.Errorf("expected the template to be renamed, got %s", tmp.Name)
+	}
+
+	err = tinkDB.RenameTemplate(ctx, uuid.MustParse(first.ID), second.Name)
+	if !errors.Is(err, db.ErrConflict) {
+		t.Errorf("expected %v, got %v", db.ErrConflict, err)
+	}
+	tmp, err = tinkDB.GetTemplate(ctx, map[string]string{"id": first.ID}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmp.Name != "renamed" {
+		t.Errorf("expected the template to keep its name after a collision, got %s", tmp.Name)
+	}
+
+	err = tinkDB.RenameTemplate(ctx, uuid.New(), "missing")
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("expected %v, got %v", db.ErrNotFound, err)
+	}
+
+	for _, name := range []string{"", " ", strings.Repeat("a", 201)} {
+		if err := tinkDB.RenameTemplate(ctx, uuid.MustParse(first.ID), name); err == nil {
+			t.Errorf("expected an error for the name %q", name)
+		}
+	}
+}
+
+func TestTemplateLabels(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	ids := map[string]uuid.UUID{}
+	for _, name := range []string{"ubuntu-worker", "ubuntu-control-plane", "flatcar-worker"} {
+		w := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+		w.ID = uuid.New().String()
+		w.Name = name
+		if err := createTemplateFromWorkflowType(ctx, tinkDB, w); err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = uuid.MustParse(w.ID)
+	}
+	labels := map[string]map[string]string{
+		"ubuntu-worker":        {"os": "ubuntu", "role": "worker"},
+		"ubuntu-control-plane": {"os": "ubuntu", "role": "control-plane"},
+		"flatcar-worker":       {"os": "flatcar", "role": "worker"},
+	}
+	for name, l := range labels {
+		if err := tinkDB.SetTemplateLabels(ctx, ids[name], l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := tinkDB.GetTemplateLabels(ctx, ids["ubuntu-worker"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(labels["ubuntu-worker"], got); diff != "" {
+		t.Errorf("unexpected labels (-want +got):\n%s", diff)
+	}
+
+	listByLabel := func(key, value string) []string {
+		t.Helper()
+		names := []string{}
+		err := tinkDB.ListTemplatesByLabel(ctx, key, value, func(id, n string, in, del *timestamp.Timestamp) error {
+			names = append(names, n)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return names
+	}
+	if diff := cmp.Diff([]string{"flatcar-worker", "ubuntu-worker"}, listByLabel("role", "worker")); diff != "" {
+		t.Errorf("unexpected templates with role=worker (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"ubuntu-control-plane", "ubuntu-worker"}, listByLabel("os", "ubuntu")); diff != "" {
+		t.Errorf("unexpected templates with os=ubuntu (-want +got):\n%s", diff)
+	}
+
+	// Setting the labels again replaces them.
+	if err := tinkDB.SetTemplateLabels(ctx, ids["ubuntu-worker"], map[string]string{"os": "ubuntu", "tier": "gold"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err = tinkDB.GetTemplateLabels(ctx, ids["ubuntu-worker"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]string{"os": "ubuntu", "tier": "gold"}, got); diff != "" {
+		t.Errorf("unexpected labels after overwriting (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"flatcar-worker"}, listByLabel("role", "worker")); diff != "" {
+		t.Errorf("unexpected templates with role=worker after overwriting (-want +got):\n%s", diff)
+	}
+
+	// Deleted templates are not listed.
+	if err := tinkDB.DeleteTemplate(ctx, ids["flatcar-worker"].String()); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{}, listByLabel("role", "worker")); diff != "" {
+		t.Errorf("unexpected templates with role=worker after deleting (-want +got):\n%s", diff)
+	}
+
+	err = tinkDB.SetTemplateLabels(ctx, uuid.New(), map[string]string{"os": "ubuntu"})
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("expected %v, got %v", db.ErrNotFound, err)
+	}
+}
+
 func TestGetTemplate(t *testing.T) {
 	ctx := context.Background()
 	expectation := func(t *testing.T, input *workflow.Workflow, tinkDB *db.TinkDB) {
@@ -366,6 +582,89 @@ func TestGetTemplateWithInvalidID(t *testing.T) {
 	}
 }
 
+func TestGetTemplateByName(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	w := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+	w.ID = uuid.New().String()
+	w.Name = fmt.Sprintf("id_%d", rand.Int())
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, w); err != nil {
+		t.Fatal(err)
+	}
+
+	wtmpl, err := tinkDB.GetTemplateByName(ctx, w.Name, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wtmpl.GetId() != w.ID {
+		t.Errorf("expected template %s, got %s", w.ID, wtmpl.GetId())
+	}
+
+	if err := tinkDB.DeleteTemplate(ctx, w.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = tinkDB.GetTemplateByName(ctx, w.Name, false)
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("expected %v for a deleted template, got %v", db.ErrNotFound, err)
+	}
+	wtmpl, err = tinkDB.GetTemplateByName(ctx, w.Name, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wtmpl.GetId() != w.ID {
+		t.Errorf("expected deleted template %s, got %s", w.ID, wtmpl.GetId())
+	}
+
+	_, err = tinkDB.GetTemplateByName(ctx, "unknown", true)
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("expected %v, got %v", db.ErrNotFound, err)
+	}
+}
+
+func TestGetTemplateData(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	w := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+	w.ID = uuid.New().String()
+	err := createTemplateFromWorkflowType(ctx, tinkDB, w)
+	if err != nil {
+		t.Error(err)
+	}
+
+	data, err := tinkDB.GetTemplateData(ctx, uuid.MustParse(w.ID))
+	if err != nil {
+		t.Error(err)
+	}
+	if dif := cmp.Diff(w, workflow.MustParse([]byte(data))); dif != "" {
+		t.Errorf(dif)
+	}
+
+	_, err = tinkDB.GetTemplateData(ctx, uuid.New())
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("expected %v, got %v", db.ErrNotFound, err)
+	}
+}
+
 func createTemplateFromWorkflowType(ctx context.Context, tinkDB *db.TinkDB, tt *workflow.Workflow) error {
 	uID := uuid.MustParse(tt.ID)
 	content, err := yaml.Marshal(tt)
@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// querier is the subset of *sql.DB used by TinkDB. It lets the same queries run
+// against the database or inside the transaction opened by WithTx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (transaction, error)
+}
+
+// transaction is the subset of *sql.Tx used by TinkDB.
+type transaction interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	Commit() error
+	Rollback() error
+}
+
+// dbQuerier runs the queries against the database.
+type dbQuerier struct {
+	*sql.DB
+}
+
+func (q dbQuerier) BeginTx(ctx context.Context, opts *sql.TxOptions) (transaction, error) {
+	return q.DB.BeginTx(ctx, opts)
+}
+
+// txQuerier runs the queries inside an existing transaction. The transactions
+// begun by the TinkDB methods are nested in it as savepoints, so they only
+// release or roll back their own changes: the outcome of the whole
+// transaction is decided by WithTx.
+type txQuerier struct {
+	*sql.Tx
+}
+
+func (q txQuerier) BeginTx(ctx context.Context, _ *sql.TxOptions) (transaction, error) {
+	return beginSavepoint(ctx, q.Tx)
+}
+
+// txOf returns the transaction q runs the queries in, if any.
+func txOf(q querier) (*sql.Tx, bool) {
+	switch q := q.(type) {
+	case txQuerier:
+		return q.Tx, true
+	case metricsQuerier:
+		return txOf(q.querier)
+	}
+	return nil, false
+}
+
+// savepointSeq numbers the savepoints so that nested ones don't shadow each
+// other.
+var savepointSeq uint64
+
+type savepoint struct {
+	*sql.Tx
+	ctx  context.Context
+	name string
+}
+
+func beginSavepoint(ctx context.Context, tx *sql.Tx) (savepoint, error) {
+	name := fmt.Sprintf("tink_savepoint_%d", atomic.AddUint64(&savepointSeq, 1))
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return savepoint{}, errors.Wrap(err, "SAVEPOINT")
+	}
+	return savepoint{Tx: tx, ctx: ctx, name: name}, nil
+}
+
+func (s savepoint) Commit() error {
+	_, err := s.Tx.ExecContext(s.ctx, "RELEASE SAVEPOINT "+s.name)
+	return errors.Wrap(err, "RELEASE SAVEPOINT")
+}
+
+func (s savepoint) Rollback() error {
+	_, err := s.Tx.ExecContext(s.ctx, "ROLLBACK TO SAVEPOINT "+s.name)
+	return errors.Wrap(err, "ROLLBACK TO SAVEPOINT")
+}
+
+// TxOption configures the transaction opened by WithTx.
+type TxOption func(*txOptions)
+
+type txOptions struct {
+	isolation sql.IsolationLevel
+	retries   int
+}
+
+// WithIsolation runs the transaction at the isolation level instead of the
+// default level of the database.
+func WithIsolation(level sql.IsolationLevel) TxOption {
+	return func(o *txOptions) { o.isolation = level }
+}
+
+// WithSerializationRetries runs the transaction again, up to retries times,
+// when it fails with a serialization failure (40001), which the serializable
+// transactions report when they conflict with concurrent ones.
+//
+// The function given to WithTx is then called once per attempt, so it has to
+// be idempotent: anything it does outside of the transaction is repeated.
+func WithSerializationRetries(retries int) TxOption {
+	return func(o *txOptions) { o.retries = retries }
+}
+
+// serializationRetries is how many times the serializable transactions of the
+// TinkDB methods, which only run queries, are retried.
+const serializationRetries = 3
+
+// WithTx runs fn in a transaction, which is committed when fn returns nil and
+// rolled back otherwise. The Database given to fn runs every operation in that
+// transaction. The transaction runs at the default isolation level of the
+// database and is not retried, unless configured otherwise with opts.
+//
+// Calling WithTx on the Database given to fn, which is a TinkDB, runs the
+// nested fn in a savepoint of the existing transaction: only its own changes
+// are rolled back when it fails, and opts are ignored.
+func (d TinkDB) WithTx(ctx context.Context, fn func(tx Database) error, opts ...TxOption) error {
+	return d.withTx(ctx, func(tx TinkDB) error { return fn(tx) }, opts...)
+}
+
+// withTx runs fn in a transaction like WithTx, giving it the transaction-scoped
+// TinkDB.
+func (d TinkDB) withTx(ctx context.Context, fn func(tx TinkDB) error, opts ...TxOption) error {
+	if tx, ok := txOf(d.instance); ok {
+		return d.withSavepoint(ctx, tx, fn)
+	}
+
+	var o txOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	for attempt := 0; ; attempt++ {
+		err := d.runTx(ctx, fn, o.isolation)
+		if attempt >= o.retries || !isSerializationFailure(err) {
+			return err
+		}
+		d.logger.With("attempt", attempt+1, "error", err).Info("retrying transaction")
+	}
+}
+
+func (d TinkDB) runTx(ctx context.Context, fn func(tx TinkDB) error, isolation sql.IsolationLevel) error {
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: isolation})
+	if err != nil {
+		return errors.Wrap(err, "BEGIN transaction")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	txDB := TinkDB{db: d.db, logger: d.logger, metrics: d.metrics}
+	txDB.instance = txDB.instrument(txQuerier{tx})
+	if err := fn(txDB); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			d.logger.Error(errors.Wrap(rbErr, "ROLLBACK"))
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "COMMIT")
+	}
+	return nil
+}
+
+func (d TinkDB) withSavepoint(ctx context.Context, tx *sql.Tx, fn func(tx TinkDB) error) error {
+	sp, err := beginSavepoint(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if err := fn(d); err != nil {
+		if rbErr := sp.Rollback(); rbErr != nil {
+			d.logger.Error(rbErr)
+		}
+		return err
+	}
+	return sp.Commit()
+}
+
+// isSerializationFailure tells whether err is a serialization_failure (40001)
+// reported by a serializable transaction.
+func isSerializationFailure(err error) bool {
+	pqErr := Error(err)
+	return pqErr != nil && pqErr.Code == "40001"
+}
@@ -0,0 +1,165 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/tinkerbell/tink/db"
+	"github.com/tinkerbell/tink/workflow"
+	"gopkg.in/yaml.v2"
+)
+
+func TestWithTx(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	hw := readHardwareData("./testdata/hardware.json")
+	if err := createHardware(ctx, tinkDB, hw); err != nil {
+		t.Fatal(err)
+	}
+	newTemplate := func() *workflow.Workflow {
+		tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+		tmp.ID = uuid.New().String()
+		tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+		return tmp
+	}
+	devices := "{\"device_1\":\"08:00:27:00:00:01\"}"
+
+	t.Run("commit", func(t *testing.T) {
+		tmp := newTemplate()
+		wfID := uuid.New()
+		err := tinkDB.WithTx(ctx, func(tx db.Database) error {
+			data, err := createTemplateAndRender(ctx, tx, tmp, devices)
+			if err != nil {
+				return err
+			}
+			return tx.CreateWorkflow(ctx, db.Workflow{ID: wfID.String(), Template: tmp.ID, Hardware: devices}, data, wfID)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tinkDB.GetTemplateData(ctx, uuid.MustParse(tmp.ID)); err != nil {
+			t.Error(err)
+		}
+		if _, err := tinkDB.GetWorkflow(ctx, wfID.String()); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		tmp := newTemplate()
+		errWorkflow := errors.New("failed to create workflow")
+		err := tinkDB.WithTx(ctx, func(tx db.Database) error {
+			if _, err := createTemplateAndRender(ctx, tx, tmp, devices); err != nil {
+				return err
+			}
+			return errWorkflow
+		})
+		assert.ErrorIs(t, err, errWorkflow)
+		_, err = tinkDB.GetTemplateData(ctx, uuid.MustParse(tmp.ID))
+		assert.ErrorIs(t, err, db.ErrNotFound)
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		tmp := newTemplate()
+		err := tinkDB.WithTx(ctx, func(tx db.Database) error {
+			return tx.(db.TinkDB).WithTx(ctx, func(tx db.Database) error {
+				_, err := createTemplateAndRender(ctx, tx, tmp, devices)
+				return err
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tinkDB.GetTemplateData(ctx, uuid.MustParse(tmp.ID)); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("nested rollback", func(t *testing.T) {
+		kept, dropped := newTemplate(), newTemplate()
+		errWorkflow := errors.New("failed to create workflow")
+		err := tinkDB.WithTx(ctx, func(tx db.Database) error {
+			if _, err := createTemplateAndRender(ctx, tx, kept, devices); err != nil {
+				return err
+			}
+			err := tx.(db.TinkDB).WithTx(ctx, func(tx db.Database) error {
+				if _, err := createTemplateAndRender(ctx, tx, dropped, devices); err != nil {
+					return err
+				}
+				return errWorkflow
+			})
+			assert.ErrorIs(t, err, errWorkflow)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tinkDB.GetTemplateData(ctx, uuid.MustParse(kept.ID)); err != nil {
+			t.Error(err)
+		}
+		_, err = tinkDB.GetTemplateData(ctx, uuid.MustParse(dropped.ID))
+		assert.ErrorIs(t, err, db.ErrNotFound)
+	})
+}
+
+func TestWithTxRetries(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	serializationFailure := &pq.Error{Code: "40001"}
+	calls := 0
+	err := tinkDB.WithTx(ctx, func(tx db.Database) error {
+		calls++
+		return serializationFailure
+	})
+	assert.ErrorIs(t, err, serializationFailure)
+	assert.Equal(t, 1, calls, "expected no retry by default")
+
+	calls = 0
+	err = tinkDB.WithTx(ctx, func(tx db.Database) error {
+		calls++
+		if calls < 3 {
+			return serializationFailure
+		}
+		return nil
+	}, db.WithIsolation(sql.LevelSerializable), db.WithSerializationRetries(2))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func createTemplateAndRender(ctx context.Context, tx db.Database, tmp *workflow.Workflow, devices string) (string, error) {
+	data, err := yaml.Marshal(tmp)
+	if err != nil {
+		return "", err
+	}
+	if err := tx.CreateTemplate(ctx, tmp.Name, string(data), uuid.MustParse(tmp.ID)); err != nil {
+		return "", err
+	}
+	return workflow.RenderTemplate(tmp.ID, string(data), []byte(devices))
+}
@@ -3,8 +3,10 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"strconv"
@@ -55,7 +57,7 @@ func (d TinkDB) CreateWorkflow(ctx context.Context, wf Workflow, data string, id
 	return nil
 }
 
-func insertInWorkflow(ctx context.Context, wf Workflow, tx *sql.Tx) error {
+func insertInWorkflow(ctx context.Context, wf Workflow, tx transaction) error {
 	_, err := tx.ExecContext(ctx, `
 	INSERT INTO
 		workflow (created_at, updated_at, template, devices, id)
@@ -72,7 +74,7 @@ func insertInWorkflow(ctx context.Context, wf Workflow, tx *sql.Tx) error {
 	return nil
 }
 
-func insertIntoWfWorkerTable(ctx context.Context, wfID uuid.UUID, workerID uuid.UUID, tx *sql.Tx) error {
+func insertIntoWfWorkerTable(ctx context.Context, wfID uuid.UUID, workerID uuid.UUID, tx transaction) error {
 	_, err := tx.ExecContext(ctx, `
 	INSERT INTO
 		workflow_worker_map (workflow_id, worker_id)
@@ -88,7 +90,7 @@ func insertIntoWfWorkerTable(ctx context.Context, wfID uuid.UUID, workerID uuid.
 }
 
 // Insert actions in the workflow_state table.
-func insertActionList(ctx context.Context, db *sql.DB, yamlData string, id uuid.UUID, tx *sql.Tx) error {
+func insertActionList(ctx context.Context, db querier, yamlData string, id uuid.UUID, tx transaction) error {
 	wf, err := wflow.Parse([]byte(yamlData))
 	if err != nil {
 		return err
@@ -151,18 +153,29 @@ func insertActionList(ctx context.Context, db *sql.DB, yamlData string, id uuid.
 				ac.Volumes = append(ac.Volumes, k+":"+v)
 			}
 
+			var files []*pb.WorkflowActionFile
+			for _, f := range ac.Files {
+				files = append(files, &pb.WorkflowActionFile{Path: f.Path, Content: f.Content})
+			}
+
 			action := pb.WorkflowAction{
-				TaskName:    task.Name,
-				WorkerId:    workerUID.String(),
-				Name:        ac.Name,
-				Image:       ac.Image,
-				Timeout:     ac.Timeout,
-				Command:     ac.Command,
-				OnTimeout:   ac.OnTimeout,
-				OnFailure:   ac.OnFailure,
-				Environment: envs,
-				Volumes:     ac.Volumes,
-				Pid:         ac.Pid,
+				TaskName:      task.Name,
+				WorkerId:      workerUID.String(),
+				Name:          ac.Name,
+				Image:         ac.Image,
+				Timeout:       ac.Timeout,
+				Command:       ac.Command,
+				OnTimeout:     ac.OnTimeout,
+				OnFailure:     ac.OnFailure,
+				Environment:   envs,
+				Volumes:       ac.Volumes,
+				Pid:           ac.Pid,
+				User:          ac.User,
+				WorkingDir:    ac.WorkingDir,
+				Network:       ac.Network,
+				Capabilities:  ac.Capabilities,
+				IgnoreFailure: ac.IgnoreFailure,
+				Files:         files,
 			}
 			actionList = append(actionList, &action)
 		}
@@ -301,6 +314,74 @@ func (d TinkDB) GetWorkflowDataVersion(ctx context.Context, workflowID string) (
 	return getLatestVersionWfData(ctx, d.instance, workflowID)
 }
 
+// GetWorkflowDataAtVersion returns the ephemeral data of a workflow at the
+// given version. It returns ErrNotFound when the version does not exist.
+func (d TinkDB) GetWorkflowDataAtVersion(ctx context.Context, wfID string, version int32) ([]byte, error) {
+	row := d.instance.QueryRowContext(ctx, `
+	SELECT data
+	FROM workflow_data
+	WHERE
+		workflow_id = $1 AND version = $2
+	`, wfID, version)
+	buf := []byte{}
+	err := row.Scan(&buf)
+	if err == nil {
+		return buf, nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrapf(ErrNotFound, "workflow %s has no data at version %d", wfID, version)
+	}
+	err = errors.Wrap(err, "SELECT from workflow_data")
+	d.logger.Error(err)
+	return nil, err
+}
+
+// WorkflowDataVersion describes a version of the ephemeral data of a workflow.
+type WorkflowDataVersion struct {
+	Version int32
+	// Size is the size in bytes of the stored data. It is 0 for the versions
+	// whose data has been cleaned up.
+	Size int
+	// CreatedAt is the zero time for the versions stored before the creation
+	// time was recorded.
+	CreatedAt time.Time
+}
+
+// ListWorkflowDataVersions returns the versions of the ephemeral data of a
+// workflow, oldest first.
+func (d TinkDB) ListWorkflowDataVersions(ctx context.Context, wfID string) ([]WorkflowDataVersion, error) {
+	rows, err := d.instance.QueryContext(ctx, `
+	SELECT version, COALESCE(octet_length(data::text), 0), created_at
+	FROM workflow_data
+	WHERE
+		workflow_id = $1
+	ORDER BY version ASC
+	`, wfID)
+	if err != nil {
+		return nil, errors.Wrap(err, "SELECT from workflow_data")
+	}
+	defer rows.Close()
+
+	versions := []WorkflowDataVersion{}
+	for rows.Next() {
+		var (
+			v         WorkflowDataVersion
+			createdAt sql.NullTime
+		)
+		if err = rows.Scan(&v.Version, &v.Size, &createdAt); err != nil {
+			return nil, errors.Wrap(err, "SELECT from workflow_data")
+		}
+		if createdAt.Valid {
+			v.CreatedAt = createdAt.Time
+		}
+		versions = append(versions, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "SELECT from workflow_data")
+	}
+	return versions, nil
+}
+
 // GetWorkflowsForWorker : returns the list of workflows for a particular worker.
 func (d TinkDB) GetWorkflowsForWorker(ctx context.Context, id string) ([]string, error) {
 	rows, err := d.instance.QueryContext(ctx, `
@@ -332,6 +413,69 @@ func (d TinkDB) GetWorkflowsForWorker(ctx context.Context, id string) ([]string,
 	return wfID, err
 }
 
+// ListWorkers returns the IDs of the workers which have a workflow assigned,
+// each once and sorted.
+func (d TinkDB) ListWorkers(ctx context.Context) ([]string, error) {
+	rows, err := d.instance.QueryContext(ctx, `
+	SELECT DISTINCT worker_id
+	FROM workflow_worker_map
+	ORDER BY worker_id;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var workers []string
+	for rows.Next() {
+		var workerID string
+		if err := rows.Scan(&workerID); err != nil {
+			err = errors.Wrap(err, "SELECT from workflow_worker_map")
+			d.logger.Error(err)
+			return nil, err
+		}
+		workers = append(workers, workerID)
+	}
+	return workers, rows.Err()
+}
+
+// GetLatestWorkflowForWorker returns the most recently created workflow which
+// is assigned to the worker and not deleted. It returns ErrNotFound when the
+// worker has no workflow.
+func (d TinkDB) GetLatestWorkflowForWorker(ctx context.Context, workerID string) (Workflow, error) {
+	row := d.instance.QueryRowContext(ctx, `
+	SELECT w.id, w.template, w.devices, w.created_at, w.updated_at
+	FROM workflow w
+	JOIN workflow_worker_map m ON m.workflow_id = w.id
+	WHERE
+		m.worker_id = $1
+	AND
+		w.deleted_at IS NULL
+	ORDER BY w.created_at DESC
+	LIMIT 1;
+	`, workerID)
+	var (
+		id, tmp, tar string
+		crAt, upAt   time.Time
+	)
+	err := row.Scan(&id, &tmp, &tar, &crAt, &upAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Workflow{}, errors.Wrapf(ErrNotFound, "workflow for worker %s", workerID)
+	}
+	if err != nil {
+		err = errors.Wrap(err, "SELECT")
+		d.logger.Error(err)
+		return Workflow{}, err
+	}
+	return Workflow{
+		ID:        id,
+		Template:  tmp,
+		Hardware:  tar,
+		CreatedAt: timestamppb.New(crAt),
+		UpdatedAt: timestamppb.New(upAt),
+	}, nil
+}
+
 // GetWorkflow returns a workflow.
 func (d TinkDB) GetWorkflow(ctx context.Context, id string) (Workflow, error) {
 	query := `
@@ -368,6 +512,50 @@ func (d TinkDB) GetWorkflow(ctx context.Context, id string) (Workflow, error) {
 	return Workflow{}, errors.New("Workflow with id " + id + " does not exist")
 }
 
+// GetWorkflowWithActions returns the workflow together with its action list.
+// Both are read by a single query, so the actions are the ones of the
+// workflow as it was returned, even when the workflow is updated concurrently.
+// It returns ErrNotFound when the workflow does not exist or is deleted.
+func (d TinkDB) GetWorkflowWithActions(ctx context.Context, id string) (Workflow, *pb.WorkflowActionList, error) {
+	row := d.instance.QueryRowContext(ctx, `
+	SELECT w.template, w.devices, w.created_at, w.updated_at, s.action_list
+	FROM workflow w
+	LEFT JOIN workflow_state s ON s.workflow_id = w.id
+	WHERE
+		w.id = $1
+	AND
+		w.deleted_at IS NULL;
+	`, id)
+	var (
+		tmp, tar   string
+		crAt, upAt time.Time
+		actionList sql.NullString
+	)
+	err := row.Scan(&tmp, &tar, &crAt, &upAt, &actionList)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Workflow{}, nil, errors.Wrapf(ErrNotFound, "workflow %s", id)
+	}
+	if err != nil {
+		err = errors.Wrap(err, "SELECT")
+		d.logger.Error(err)
+		return Workflow{}, nil, err
+	}
+
+	actions := []*pb.WorkflowAction{}
+	if actionList.Valid {
+		if err := json.Unmarshal([]byte(actionList.String), &actions); err != nil {
+			return Workflow{}, nil, errors.Wrap(err, "unmarshal action list")
+		}
+	}
+	return Workflow{
+		ID:        id,
+		Template:  tmp,
+		Hardware:  tar,
+		CreatedAt: timestamppb.New(crAt),
+		UpdatedAt: timestamppb.New(upAt),
+	}, &pb.WorkflowActionList{ActionList: actions}, nil
+}
+
 // DeleteWorkflow deletes a workflow.
 func (d TinkDB) DeleteWorkflow(ctx context.Context, id string, _ int32) error {
 	tx, err := d.instance.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
@@ -417,16 +605,132 @@ func (d TinkDB) DeleteWorkflow(ctx context.Context, id string, _ int32) error {
 
 // ListWorkflows returns all workflows.
 func (d TinkDB) ListWorkflows(fn func(wf Workflow) error) error {
-	rows, err := d.instance.Query(`
+	return d.ListWorkflowsCtx(context.Background(), fn)
+}
+
+// ListWorkflowsCtx calls fn for every workflow. The scan stops with the
+// context error as soon as ctx is done.
+func (d TinkDB) ListWorkflowsCtx(ctx context.Context, fn func(wf Workflow) error) error {
+	rows, err := d.instance.QueryContext(ctx, `
 	SELECT id, template, devices, created_at, updated_at
 	FROM workflow
 	WHERE
 		deleted_at IS NULL;
 	`)
 	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		return err
 	}
+	return d.streamWorkflows(ctx, rows, fn)
+}
+
+// ListWorkflowsByTemplate calls fn for every workflow created from the template.
+func (d TinkDB) ListWorkflowsByTemplate(ctx context.Context, templateID uuid.UUID, fn func(wf Workflow) error) error {
+	rows, err := d.instance.QueryContext(ctx, `
+	SELECT id, template, devices, created_at, updated_at
+	FROM workflow
+	WHERE
+		deleted_at IS NULL
+	AND
+		template = $1
+	ORDER BY created_at ASC;
+	`, templateID)
+	if err != nil {
+		return errors.Wrap(err, "SELECT")
+	}
+	return d.streamWorkflows(ctx, rows, fn)
+}
+
+// ListOrphanedWorkflows calls fn with every workflow, from the oldest, whose
+// template does not exist or is deleted.
+func (d TinkDB) ListOrphanedWorkflows(ctx context.Context, fn func(wf Workflow) error) error {
+	rows, err := d.instance.QueryContext(ctx, `
+	SELECT w.id, w.template, w.devices, w.created_at, w.updated_at
+	FROM workflow w
+	LEFT JOIN template t ON t.id = w.template AND t.deleted_at IS NULL
+	WHERE
+		w.deleted_at IS NULL
+	AND
+		t.id IS NULL
+	ORDER BY w.created_at ASC;
+	`)
+	if err != nil {
+		return errors.Wrap(err, "SELECT")
+	}
+	return d.streamWorkflows(ctx, rows, fn)
+}
+
+// RecentWorkflows returns the n most recently created workflows which are not
+// deleted, from the most recent. It returns no workflow when n is lower than 1.
+func (d TinkDB) RecentWorkflows(ctx context.Context, n int) ([]Workflow, error) {
+	if n < 1 {
+		return []Workflow{}, nil
+	}
+	rows, err := d.instance.QueryContext(ctx, `
+	SELECT id, template, devices, created_at, updated_at
+	FROM workflow
+	WHERE
+		deleted_at IS NULL
+	ORDER BY created_at DESC, id
+	LIMIT $1;
+	`, n)
+	if err != nil {
+		return nil, errors.Wrap(err, "SELECT")
+	}
+	wfs := []Workflow{}
+	err = d.streamWorkflows(ctx, rows, func(wf Workflow) error {
+		wfs = append(wfs, wf)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wfs, nil
+}
+
+// CountWorkflowsByTemplate returns the number of workflows created from the template.
+func (d TinkDB) CountWorkflowsByTemplate(ctx context.Context, templateID uuid.UUID) (int, error) {
+	var count int
+	err := d.instance.QueryRowContext(ctx, `
+	SELECT COUNT(*)
+	FROM workflow
+	WHERE
+		deleted_at IS NULL
+	AND
+		template = $1;
+	`, templateID).Scan(&count)
+	if err != nil {
+		return 0, errors.Wrap(err, "SELECT")
+	}
+	return count, nil
+}
 
+// ListWorkflowsCreatedBetween calls fn for every workflow created between
+// start and end, both included, from the oldest to the newest.
+func (d TinkDB) ListWorkflowsCreatedBetween(ctx context.Context, start, end time.Time, fn func(wf Workflow) error) error {
+	if start.After(end) {
+		return errors.Errorf("invalid time window, start %s is after end %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	rows, err := d.instance.QueryContext(ctx, `
+	SELECT id, template, devices, created_at, updated_at
+	FROM workflow
+	WHERE
+		deleted_at IS NULL
+	AND
+		created_at BETWEEN $1 AND $2
+	ORDER BY created_at ASC;
+	`, start, end)
+	if err != nil {
+		return errors.Wrap(err, "SELECT")
+	}
+	return d.streamWorkflows(ctx, rows, fn)
+}
+
+// streamWorkflows calls fn for every workflow of the rows, then closes them.
+// It stops with the context error as soon as ctx is done.
+func (d TinkDB) streamWorkflows(ctx context.Context, rows *sql.Rows, fn func(wf Workflow) error) error {
 	defer rows.Close()
 	var (
 		id, tmp, tar string
@@ -434,7 +738,10 @@ func (d TinkDB) ListWorkflows(fn func(wf Workflow) error) error {
 	)
 
 	for rows.Next() {
-		err = rows.Scan(&id, &tmp, &tar, &crAt, &upAt)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := rows.Scan(&id, &tmp, &tar, &crAt, &upAt)
 		if err != nil {
 			err = errors.Wrap(err, "SELECT")
 			d.logger.Error(err)
@@ -453,7 +760,10 @@ func (d TinkDB) ListWorkflows(fn func(wf Workflow) error) error {
 			return err
 		}
 	}
-	err = rows.Err()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := rows.Err()
 	if errors.Is(err, sql.ErrNoRows) {
 		err = nil
 	}
@@ -546,6 +856,111 @@ func (d TinkDB) GetWorkflowContexts(ctx context.Context, wfID string) (*pb.Workf
 	return &pb.WorkflowContext{}, errors.New("Workflow with id " + wfID + " does not exist")
 }
 
+// GetWorkerWorkflowContexts returns in a single query the contexts of the
+// active workflows of a worker. A workflow is active until it is deleted,
+// an action fails, times out or is cancelled, or its last action succeeds.
+func (d TinkDB) GetWorkerWorkflowContexts(ctx context.Context, workerID string) ([]*pb.WorkflowContext, error) {
+	rows, err := d.instance.QueryContext(ctx, `
+	SELECT s.workflow_id, s.current_worker, s.current_task_name, s.current_action_name, s.current_action_index, s.current_action_state, s.total_number_of_actions
+	FROM workflow_worker_map m
+	JOIN workflow_state s ON s.workflow_id = m.workflow_id
+	JOIN workflow w ON w.id = m.workflow_id
+	WHERE
+		m.worker_id = $1
+	AND
+		w.deleted_at IS NULL
+	AND
+		s.current_action_state NOT IN ($2, $3, $5)
+	AND NOT
+		(s.current_action_state = $4 AND s.current_action_index = s.total_number_of_actions - 1)
+	ORDER BY w.created_at;
+	`, workerID, pb.State_STATE_FAILED, pb.State_STATE_TIMEOUT, pb.State_STATE_SUCCESS, pb.State_STATE_CANCELLED)
+	if err != nil {
+		return nil, errors.Wrap(err, "SELECT from workflow_state")
+	}
+	defer rows.Close()
+
+	contexts := []*pb.WorkflowContext{}
+	for rows.Next() {
+		wfCtx := &pb.WorkflowContext{}
+		err = rows.Scan(
+			&wfCtx.WorkflowId,
+			&wfCtx.CurrentWorker,
+			&wfCtx.CurrentTask,
+			&wfCtx.CurrentAction,
+			&wfCtx.CurrentActionIndex,
+			&wfCtx.CurrentActionState,
+			&wfCtx.TotalNumberOfActions,
+		)
+		if err != nil {
+			err = errors.Wrap(err, "SELECT from workflow_state")
+			d.logger.Error(err)
+			return nil, err
+		}
+		contexts = append(contexts, wfCtx)
+	}
+	return contexts, rows.Err()
+}
+
+// ClaimNextAction claims the next action of the workflows assigned to the
+// worker and returns it. The claimed action is marked as running, so that it
+// is never returned again, even to concurrent callers. The next action of a
+// workflow is its first action when the workflow has not started, or the one
+// after its current action when the current action succeeded. Workflows are
+// considered from the oldest. ErrNoWork is returned when the next action of
+// every workflow belongs to another worker or the workflows are over.
+func (d TinkDB) ClaimNextAction(ctx context.Context, workerID string) (*pb.WorkflowAction, error) {
+	// The worker ID is given twice, compared to a UUID column and to text.
+	// The rows locked by a concurrent claim are skipped rather than waited for,
+	// the claim moves on to the next workflow.
+	row := d.instance.QueryRowContext(ctx, `
+	WITH next AS (
+		SELECT s.workflow_id, CASE WHEN s.current_action_state = $2 THEN s.current_action_index ELSE s.current_action_index + 1 END AS idx
+		FROM workflow_state s
+		JOIN workflow_worker_map m ON m.workflow_id = s.workflow_id
+		JOIN workflow w ON w.id = s.workflow_id
+		WHERE
+			m.worker_id = $1
+		AND
+			w.deleted_at IS NULL
+		AND (
+			s.current_action_state = $2
+			OR
+			(s.current_action_state = $3 AND s.current_action_index < s.total_number_of_actions - 1)
+		)
+		AND
+			s.action_list -> (CASE WHEN s.current_action_state = $2 THEN s.current_action_index ELSE s.current_action_index + 1 END) ->> 'worker_id' = $5
+		ORDER BY w.created_at
+		LIMIT 1
+		FOR UPDATE OF s SKIP LOCKED
+	)
+	UPDATE workflow_state s
+	SET
+		current_action_index = next.idx,
+		current_action_state = $4,
+		current_worker = $5,
+		current_task_name = s.action_list -> next.idx ->> 'task_name',
+		current_action_name = s.action_list -> next.idx ->> 'name'
+	FROM next
+	WHERE
+		s.workflow_id = next.workflow_id
+	RETURNING s.action_list -> next.idx;
+	`, workerID, pb.State_STATE_PENDING, pb.State_STATE_SUCCESS, pb.State_STATE_RUNNING, workerID)
+
+	var data []byte
+	if err := row.Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoWork
+		}
+		return nil, errors.Wrap(err, "UPDATE workflow_state")
+	}
+	action := &pb.WorkflowAction{}
+	if err := json.Unmarshal(data, action); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
+
 // GetWorkflowActions : gives you the action list of workflow.
 func (d TinkDB) GetWorkflowActions(ctx context.Context, wfID string) (*pb.WorkflowActionList, error) {
 	query := `
@@ -573,6 +988,335 @@ func (d TinkDB) GetWorkflowActions(ctx context.Context, wfID string) (*pb.Workfl
 	return &pb.WorkflowActionList{}, nil
 }
 
+// WorkflowProgress returns the percentage of completed actions of a workflow
+// together with the name of the action currently being executed.
+func (d TinkDB) WorkflowProgress(ctx context.Context, wfID string) (int, string, error) {
+	actions, err := d.GetWorkflowActions(ctx, wfID)
+	if err != nil {
+		return 0, "", err
+	}
+	wfContext, err := d.GetWorkflowContexts(ctx, wfID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	total := len(actions.GetActionList())
+	if total == 0 {
+		return 0, wfContext.GetCurrentAction(), nil
+	}
+	completed := int(wfContext.GetCurrentActionIndex())
+	if wfContext.GetCurrentActionState() == pb.State_STATE_SUCCESS {
+		completed++
+	}
+	if completed > total {
+		completed = total
+	}
+	return completed * 100 / total, wfContext.GetCurrentAction(), nil
+}
+
+// GetCurrentAction returns the task and action a workflow is at, and the
+// state of that action, without reading its whole action list. It returns
+// ErrNotFound when the workflow does not exist.
+func (d TinkDB) GetCurrentAction(ctx context.Context, wfID string) (taskName, actionName string, status int32, err error) {
+	err = d.instance.QueryRowContext(ctx, `
+	SELECT s.current_task_name, s.current_action_name, s.current_action_state
+	FROM workflow_state s
+	JOIN workflow w ON w.id = s.workflow_id
+	WHERE
+		s.workflow_id = $1
+	AND
+		w.deleted_at IS NULL;
+	`, wfID).Scan(&taskName, &actionName, &status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", 0, errors.Wrapf(ErrNotFound, "workflow %s", wfID)
+		}
+		return "", "", 0, errors.Wrap(err, "SELECT from workflow_state")
+	}
+	return taskName, actionName, status, nil
+}
+
+// ReassignWorkflowWorker moves the actions of a workflow which are not
+// completed yet, including the running one, from a worker to another. The
+// completed actions keep the worker that ran them. It returns ErrNotFound when
+// the workflow does not exist and an error when it is over or no remaining
+// action belongs to fromWorker. The workflow stays assigned to fromWorker as
+// well, so that the actions it ran are still listed for it.
+func (d TinkDB) ReassignWorkflowWorker(ctx context.Context, wfID, fromWorker, toWorker string) error {
+	fromUID, err := uuid.Parse(fromWorker)
+	if err != nil {
+		return errors.Wrapf(err, "invalid worker id %s", fromWorker)
+	}
+	toUID, err := uuid.Parse(toWorker)
+	if err != nil {
+		return errors.Wrapf(err, "invalid worker id %s", toWorker)
+	}
+	if fromUID == toUID {
+		return errors.New("the workflow is already assigned to worker " + toUID.String())
+	}
+
+	return d.withTx(ctx, func(txDB TinkDB) error {
+		var (
+			data   []byte
+			index  int
+			state  pb.State
+			total  int
+			worker string
+		)
+		err := txDB.instance.QueryRowContext(ctx, `
+		SELECT s.action_list, s.current_action_index, s.current_action_state, s.total_number_of_actions, s.current_worker
+		FROM workflow_state s
+		JOIN workflow w ON w.id = s.workflow_id
+		WHERE
+			s.workflow_id = $1
+		AND
+			w.deleted_at IS NULL
+		FOR UPDATE OF s;
+		`, wfID).Scan(&data, &index, &state, &total, &worker)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errors.Wrapf(ErrNotFound, "workflow %s", wfID)
+			}
+			return errors.Wrap(err, "SELECT from workflow_state")
+		}
+
+		if state == pb.State_STATE_FAILED || state == pb.State_STATE_TIMEOUT || state == pb.State_STATE_CANCELLED ||
+			(state == pb.State_STATE_SUCCESS && index >= total-1) {
+			return errors.Errorf("workflow %s is already %s", wfID, state)
+		}
+
+		actions := []*pb.WorkflowAction{}
+		if err := json.Unmarshal(data, &actions); err != nil {
+			return err
+		}
+		first := index
+		if state == pb.State_STATE_SUCCESS {
+			first++
+		}
+		moved := 0
+		for _, action := range actions[first:] {
+			if sameWorker(action.WorkerId, fromUID) {
+				action.WorkerId = toUID.String()
+				moved++
+			}
+		}
+		if moved == 0 {
+			return errors.Errorf("workflow %s has no remaining action for worker %s", wfID, fromUID)
+		}
+		if state != pb.State_STATE_SUCCESS && sameWorker(worker, fromUID) {
+			worker = toUID.String()
+		}
+		data, err = json.Marshal(actions)
+		if err != nil {
+			return err
+		}
+
+		_, err = txDB.instance.ExecContext(ctx, `
+		UPDATE workflow_state
+		SET
+			action_list = $2,
+			current_worker = $3
+		WHERE
+			workflow_id = $1;
+		`, wfID, data, worker)
+		if err != nil {
+			return errors.Wrap(err, "UPDATE workflow_state")
+		}
+
+		_, err = txDB.instance.ExecContext(ctx, `
+		INSERT INTO
+			workflow_worker_map (workflow_id, worker_id)
+		VALUES
+			($1, $2)
+		ON CONFLICT (workflow_id, worker_id)
+		DO NOTHING;
+		`, wfID, toUID)
+		if err != nil {
+			return errors.Wrap(err, "INSERT in to workflow_worker_map")
+		}
+		return nil
+	})
+}
+
+// sameWorker tells whether the worker ID, as stored with the workflow, is
+// the UUID of the worker whatever its case.
+func sameWorker(workerID string, uid uuid.UUID) bool {
+	id, err := uuid.Parse(workerID)
+	return err == nil && id == uid
+}
+
+// CancelWorkflow cancels a workflow which is not over: its current action, or
+// the next one when the current one succeeded, is set to the cancelled state,
+// so that none of the remaining actions is run, and a cancellation event is
+// recorded for it. The workers see the cancelled state the next time they
+// poll the workflow.
+func (d TinkDB) CancelWorkflow(ctx context.Context, wfID string) error {
+	return d.withTx(ctx, func(txDB TinkDB) error {
+		var (
+			data  []byte
+			index int
+			state pb.State
+			total int
+		)
+		err := txDB.instance.QueryRowContext(ctx, `
+		SELECT s.action_list, s.current_action_index, s.current_action_state, s.total_number_of_actions
+		FROM workflow_state s
+		JOIN workflow w ON w.id = s.workflow_id
+		WHERE
+			s.workflow_id = $1
+		AND
+			w.deleted_at IS NULL
+		FOR UPDATE OF s;
+		`, wfID).Scan(&data, &index, &state, &total)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errors.Wrapf(ErrNotFound, "workflow %s", wfID)
+			}
+			return errors.Wrap(err, "SELECT from workflow_state")
+		}
+
+		if state == pb.State_STATE_FAILED || state == pb.State_STATE_TIMEOUT || state == pb.State_STATE_CANCELLED ||
+			(state == pb.State_STATE_SUCCESS && index >= total-1) {
+			return errors.Errorf("workflow %s is already %s", wfID, state)
+		}
+
+		actions := []*pb.WorkflowAction{}
+		if err := json.Unmarshal(data, &actions); err != nil {
+			return err
+		}
+		if state == pb.State_STATE_SUCCESS {
+			index++
+		}
+		if index >= len(actions) {
+			return errors.Errorf("workflow %s has no action at index %d", wfID, index)
+		}
+		action := actions[index]
+
+		_, err = txDB.instance.ExecContext(ctx, `
+		UPDATE workflow_state
+		SET
+			current_worker = $2,
+			current_task_name = $3,
+			current_action_name = $4,
+			current_action_state = $5,
+			current_action_index = $6
+		WHERE
+			workflow_id = $1;
+		`, wfID, action.WorkerId, action.TaskName, action.Name, pb.State_STATE_CANCELLED, index)
+		if err != nil {
+			return errors.Wrap(err, "UPDATE workflow_state")
+		}
+
+		_, err = txDB.instance.ExecContext(ctx, `
+		UPDATE workflow
+		SET
+			updated_at = NOW()
+		WHERE
+			id = $1;
+		`, wfID)
+		if err != nil {
+			return errors.Wrap(err, "UPDATE workflow")
+		}
+
+		_, err = txDB.instance.ExecContext(ctx, `
+		INSERT INTO
+			workflow_event (workflow_id, worker_id, task_name, action_name, execution_time, message, status, created_at)
+		VALUES
+			($1, $2, $3, $4, $5, $6, $7, $8);
+		`, wfID, action.WorkerId, action.TaskName, action.Name, 0, "workflow cancelled", pb.State_STATE_CANCELLED, time.Now())
+		if err != nil {
+			return errors.Wrap(err, "INSERT in to workflow_event")
+		}
+		return nil
+	})
+}
+
+// BulkUpdateWorkflowState sets the state of the current action of the
+// workflows in a single transaction and returns the number of workflows
+// updated. The workflows which are over, or do not exist, cannot be updated:
+// when allOrNothing is set the first of them fails the whole batch and no
+// workflow is updated, otherwise they are skipped.
+func (d TinkDB) BulkUpdateWorkflowState(ctx context.Context, ids []string, state int32, allOrNothing bool) (updated int, err error) {
+	if _, ok := pb.State_name[state]; !ok {
+		return 0, errors.Errorf("invalid workflow state %d", state)
+	}
+	err = d.withTx(ctx, func(txDB TinkDB) error {
+		updated = 0
+		for _, id := range ids {
+			err := txDB.updateWorkflowState(ctx, id, pb.State(state))
+			if err == nil {
+				updated++
+				continue
+			}
+			if allOrNothing || !(errors.Is(err, errWorkflowOver) || errors.Is(err, ErrNotFound)) {
+				return err
+			}
+			d.logger.With("workflow_id", id, "error", err).Info("skipping workflow")
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+// errWorkflowOver is returned when the state of a workflow which is over is
+// updated.
+var errWorkflowOver = errors.New("workflow is over")
+
+// updateWorkflowState sets the state of the current action of the workflow,
+// unless it is over. It must be called within a transaction.
+func (d TinkDB) updateWorkflowState(ctx context.Context, wfID string, state pb.State) error {
+	var (
+		index   int
+		current pb.State
+		total   int
+	)
+	err := d.instance.QueryRowContext(ctx, `
+	SELECT s.current_action_index, s.current_action_state, s.total_number_of_actions
+	FROM workflow_state s
+	JOIN workflow w ON w.id = s.workflow_id
+	WHERE
+		s.workflow_id = $1
+	AND
+		w.deleted_at IS NULL
+	FOR UPDATE OF s;
+	`, wfID).Scan(&index, &current, &total)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.Wrapf(ErrNotFound, "workflow %s", wfID)
+		}
+		return errors.Wrap(err, "SELECT from workflow_state")
+	}
+	if current == pb.State_STATE_FAILED || current == pb.State_STATE_TIMEOUT || current == pb.State_STATE_CANCELLED ||
+		(current == pb.State_STATE_SUCCESS && index >= total-1) {
+		return errors.Wrapf(errWorkflowOver, "workflow %s is already %s", wfID, current)
+	}
+
+	_, err = d.instance.ExecContext(ctx, `
+	UPDATE workflow_state
+	SET
+		current_action_state = $2
+	WHERE
+		workflow_id = $1;
+	`, wfID, state)
+	if err != nil {
+		return errors.Wrap(err, "UPDATE workflow_state")
+	}
+	_, err = d.instance.ExecContext(ctx, `
+	UPDATE workflow
+	SET
+		updated_at = NOW()
+	WHERE
+		id = $1;
+	`, wfID)
+	if err != nil {
+		return errors.Wrap(err, "UPDATE workflow")
+	}
+	return nil
+}
+
 // InsertIntoWorkflowEventTable : insert workflow event table.
 func (d TinkDB) InsertIntoWorkflowEventTable(ctx context.Context, wfEvent *pb.WorkflowActionStatus, t time.Time) error {
 	tx, err := d.instance.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
@@ -597,6 +1341,37 @@ func (d TinkDB) InsertIntoWorkflowEventTable(ctx context.Context, wfEvent *pb.Wo
 	return nil
 }
 
+// TruncateWorkflowEvents deletes the events of a workflow but the keepLast
+// most recent ones, and returns the number of events deleted. The workflow
+// itself is kept.
+func (d TinkDB) TruncateWorkflowEvents(ctx context.Context, wfID string, keepLast int) (deleted int, err error) {
+	if keepLast < 0 {
+		return 0, errors.Errorf("invalid number of events to keep %d, it cannot be negative", keepLast)
+	}
+	// The events have no key, they are told apart by their physical location.
+	res, err := d.instance.ExecContext(ctx, `
+	DELETE FROM workflow_event
+	WHERE
+		workflow_id = $1
+	AND ctid NOT IN (
+		SELECT ctid
+		FROM workflow_event
+		WHERE
+			workflow_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	);
+	`, wfID, keepLast)
+	if err != nil {
+		return 0, errors.Wrap(err, "DELETE from workflow_event")
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "DELETE from workflow_event")
+	}
+	return int(count), nil
+}
+
 // ShowWorkflowEvents returns all workflows.
 func (d TinkDB) ShowWorkflowEvents(wfID string, fn func(wfs *pb.WorkflowActionStatus) error) error {
 	rows, err := d.instance.Query(`
@@ -610,7 +1385,34 @@ func (d TinkDB) ShowWorkflowEvents(wfID string, fn func(wfs *pb.WorkflowActionSt
 	if err != nil {
 		return err
 	}
+	return d.sendWorkflowEvents(rows, fn)
+}
 
+// ShowWorkflowEventsAfter calls fn with the events of the workflow created at
+// or after the cursor, in the order they happened. It is meant to poll the new
+// events of a workflow with the creation time of the last event seen, which
+// stays valid when the older events are truncated. The events are not unique
+// by their creation time, so the ones created at the cursor are returned
+// again and the caller skips those it has seen.
+func (d TinkDB) ShowWorkflowEventsAfter(ctx context.Context, wfID string, after time.Time, fn func(wfs *pb.WorkflowActionStatus) error) error {
+	rows, err := d.instance.QueryContext(ctx, `
+	SELECT worker_id, task_name, action_name, execution_time, message, status, created_at
+	FROM workflow_event
+	WHERE
+		workflow_id = $1
+	AND
+		created_at >= $2
+	ORDER BY
+		created_at ASC;
+	`, wfID, after)
+	if err != nil {
+		return err
+	}
+	return d.sendWorkflowEvents(rows, fn)
+}
+
+// sendWorkflowEvents calls fn with the events read from rows, and closes them.
+func (d TinkDB) sendWorkflowEvents(rows *sql.Rows, fn func(wfs *pb.WorkflowActionStatus) error) error {
 	defer rows.Close()
 	var (
 		st                    int32
@@ -620,7 +1422,7 @@ func (d TinkDB) ShowWorkflowEvents(wfID string, fn func(wfs *pb.WorkflowActionSt
 	)
 
 	for rows.Next() {
-		err = rows.Scan(&id, &tName, &aName, &secs, &msg, &st, &evTime)
+		err := rows.Scan(&id, &tName, &aName, &secs, &msg, &st, &evTime)
 		if err != nil {
 			err = errors.Wrap(err, "SELECT")
 			d.logger.Error(err)
@@ -641,14 +1443,109 @@ func (d TinkDB) ShowWorkflowEvents(wfID string, fn func(wfs *pb.WorkflowActionSt
 			return err
 		}
 	}
-	err = rows.Err()
+	err := rows.Err()
 	if errors.Is(err, sql.ErrNoRows) {
 		err = nil
 	}
 	return err
 }
 
-func getLatestVersionWfData(ctx context.Context, db *sql.DB, wfID string) (int32, error) {
+// ExportWorkflowEventsCSV writes the events of the workflow to w as CSV, a
+// header and one row per event in the order they happened. The rows are
+// written as the events are read, so the memory used does not depend on the
+// number of events.
+func (d TinkDB) ExportWorkflowEventsCSV(wfID string, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"timestamp", "task", "action", "status", "seconds"}); err != nil {
+		return err
+	}
+	err := d.ShowWorkflowEvents(wfID, func(wfs *pb.WorkflowActionStatus) error {
+		return cw.Write([]string{
+			wfs.GetCreatedAt().AsTime().UTC().Format(time.RFC3339Nano),
+			wfs.GetTaskName(),
+			wfs.GetActionName(),
+			wfs.GetActionStatus().String(),
+			strconv.FormatInt(wfs.GetSeconds(), 10),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WorkflowEventSummary returns the number of events of the workflow for each
+// action status, keyed by the status name.
+func (d TinkDB) WorkflowEventSummary(ctx context.Context, wfID string) (map[string]int, error) {
+	rows, err := d.instance.QueryContext(ctx, `
+	SELECT status, COUNT(*)
+	FROM workflow_event
+	WHERE
+		workflow_id = $1
+	GROUP BY status;
+	`, wfID)
+	if err != nil {
+		return nil, errors.Wrap(err, "SELECT")
+	}
+	defer rows.Close()
+
+	summary := map[string]int{}
+	for rows.Next() {
+		var (
+			status int32
+			count  int
+		)
+		if err = rows.Scan(&status, &count); err != nil {
+			return nil, errors.Wrap(err, "SELECT")
+		}
+		summary[pb.State(status).String()] = count
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "SELECT")
+	}
+	return summary, nil
+}
+
+// WorkflowDuration returns the time elapsed between the first and the last
+// events of the workflow, and whether the workflow is over: its last action
+// succeeded, or an action failed, timed out or was cancelled. The duration of
+// a workflow without events is 0. It returns ErrNotFound when the workflow
+// does not exist.
+func (d TinkDB) WorkflowDuration(ctx context.Context, wfID string) (time.Duration, bool, error) {
+	var (
+		index       int
+		state       pb.State
+		total       int
+		first, last sql.NullTime
+	)
+	err := d.instance.QueryRowContext(ctx, `
+	SELECT s.current_action_index, s.current_action_state, s.total_number_of_actions,
+		(SELECT MIN(created_at) FROM workflow_event WHERE workflow_id = s.workflow_id),
+		(SELECT MAX(created_at) FROM workflow_event WHERE workflow_id = s.workflow_id)
+	FROM workflow_state s
+	JOIN workflow w ON w.id = s.workflow_id
+	WHERE
+		s.workflow_id = $1
+	AND
+		w.deleted_at IS NULL;
+	`, wfID).Scan(&index, &state, &total, &first, &last)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, errors.Wrapf(ErrNotFound, "workflow %s", wfID)
+		}
+		return 0, false, errors.Wrap(err, "SELECT")
+	}
+
+	completed := state == pb.State_STATE_FAILED || state == pb.State_STATE_TIMEOUT || state == pb.State_STATE_CANCELLED ||
+		(state == pb.State_STATE_SUCCESS && index >= total-1)
+	if !first.Valid || !last.Valid {
+		return 0, completed, nil
+	}
+	return last.Time.Sub(first.Time), completed, nil
+}
+
+func getLatestVersionWfData(ctx context.Context, db querier, wfID string) (int32, error) {
 	query := `
 	SELECT COUNT(*)
 	FROM workflow_data
@@ -664,7 +1561,7 @@ func getLatestVersionWfData(ctx context.Context, db *sql.DB, wfID string) (int32
 	return version, nil
 }
 
-func getWorkerIDbyMac(ctx context.Context, db *sql.DB, mac string) (string, error) {
+func getWorkerIDbyMac(ctx context.Context, db querier, mac string) (string, error) {
 	arg := `
 	{
 		"network": {
@@ -694,7 +1591,7 @@ func getWorkerIDbyMac(ctx context.Context, db *sql.DB, mac string) (string, erro
 	return id, err
 }
 
-func getWorkerIDbyIP(ctx context.Context, db *sql.DB, ip string) (string, error) {
+func getWorkerIDbyIP(ctx context.Context, db querier, ip string) (string, error) {
 	// update for instance (under metadata)
 	instance := `
         {
@@ -742,7 +1639,7 @@ func getWorkerIDbyIP(ctx context.Context, db *sql.DB, ip string) (string, error)
 	return id, err
 }
 
-func getWorkerID(ctx context.Context, db *sql.DB, addr string) (string, error) {
+func getWorkerID(ctx context.Context, db querier, addr string) (string, error) {
 	parsedMAC, err := net.ParseMAC(addr)
 	if err != nil {
 		ip := net.ParseIP(addr)
@@ -2,11 +2,16 @@
 package db_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -407,3 +412,1619 @@ func createWorkflow(ctx context.Context, tinkDB *db.TinkDB, in *input) (string,
 	}
 	return id.String(), nil
 }
+
+func TestGetWorkflowWithActions(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+	wfID, err := createWorkflow(ctx, tinkDB, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wf, actions, err := tinkDB.GetWorkflowWithActions(ctx, wfID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantWf, err := tinkDB.GetWorkflow(ctx, wfID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantActions, err := tinkDB.GetWorkflowActions(ctx, wfID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, wantWf.Template, wf.Template)
+	assert.Equal(t, wantWf.Hardware, wf.Hardware)
+	assert.True(t, wantWf.UpdatedAt.AsTime().Equal(wf.UpdatedAt.AsTime()))
+	assert.Len(t, actions.GetActionList(), 2)
+	for i, action := range actions.GetActionList() {
+		assert.Equal(t, wantActions.GetActionList()[i].GetName(), action.GetName())
+		assert.Equal(t, wantActions.GetActionList()[i].GetTaskName(), action.GetTaskName())
+	}
+
+	_, _, err = tinkDB.GetWorkflowWithActions(ctx, uuid.New().String())
+	assert.ErrorIs(t, err, db.ErrNotFound)
+}
+
+func TestCreateWorkflowActionOptions(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			tmp.Version = workflow.TemplateVersion02
+			tmp.Tasks[0].Actions[1].IgnoreFailure = true
+			tmp.Tasks[0].Actions[1].Files = []workflow.File{{Path: "/etc/motd", Content: "hello"}}
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+	wfID, err := createWorkflow(ctx, tinkDB, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actions, err := tinkDB.GetWorkflowActions(ctx, wfID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, actions.GetActionList(), 2)
+	assert.False(t, actions.GetActionList()[0].GetIgnoreFailure())
+	assert.True(t, actions.GetActionList()[1].GetIgnoreFailure())
+	assert.Empty(t, actions.GetActionList()[0].GetFiles())
+	if assert.Len(t, actions.GetActionList()[1].GetFiles(), 1) {
+		assert.Equal(t, "/etc/motd", actions.GetActionList()[1].GetFiles()[0].GetPath())
+		assert.Equal(t, "hello", actions.GetActionList()[1].GetFiles()[0].GetContent())
+	}
+}
+
+func TestListWorkflowsCreatedBetween(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two workflows on each side of the boundary.
+	start := time.Now()
+	var before, after []string
+	for i := 0; i < 2; i++ {
+		wfID, err := createWorkflow(ctx, tinkDB, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before = append(before, wfID)
+	}
+	time.Sleep(10 * time.Millisecond)
+	boundary := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		wfID, err := createWorkflow(ctx, tinkDB, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		after = append(after, wfID)
+	}
+	end := time.Now()
+
+	list := func(start, end time.Time) []string {
+		t.Helper()
+		ids := []string{}
+		err := tinkDB.ListWorkflowsCreatedBetween(ctx, start, end, func(wf db.Workflow) error {
+			ids = append(ids, wf.ID)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+	assert.Equal(t, before, list(start, boundary))
+	assert.Equal(t, after, list(boundary, end))
+	assert.Equal(t, append(append([]string{}, before...), after...), list(start, end))
+	assert.Empty(t, list(end.Add(time.Hour), end.Add(2*time.Hour)))
+
+	err := tinkDB.ListWorkflowsCreatedBetween(ctx, end, start, func(wf db.Workflow) error { return nil })
+	assert.Error(t, err, "expected an error when start is after end")
+}
+
+func TestWorkflowProgress(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+	wfID, err := createWorkflow(ctx, tinkDB, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	percent, current, err := tinkDB.WorkflowProgress(ctx, wfID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, percent)
+	assert.Equal(t, "", current)
+
+	err = tinkDB.UpdateWorkflowState(ctx, &pb.WorkflowContext{
+		WorkflowId:         wfID,
+		CurrentTask:        "run_one_worker",
+		CurrentAction:      "server_partitioning",
+		CurrentActionState: pb.State_STATE_SUCCESS,
+		CurrentActionIndex: 0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	percent, current, err = tinkDB.WorkflowProgress(ctx, wfID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 50, percent)
+	assert.Equal(t, "server_partitioning", current)
+}
+
+func TestGetWorkflowDataAtVersion(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	wfID := uuid.New().String()
+	for _, data := range []string{`{"step":1}`, `{"step":2}`} {
+		err := tinkDB.InsertIntoWfDataTable(ctx, &pb.UpdateWorkflowDataRequest{
+			WorkflowId: wfID,
+			Data:       []byte(data),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := tinkDB.GetWorkflowDataAtVersion(ctx, wfID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `{"step":1}`, string(data))
+
+	data, err = tinkDB.GetWorkflowDataAtVersion(ctx, wfID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `{"step":2}`, string(data))
+
+	_, err = tinkDB.GetWorkflowDataAtVersion(ctx, wfID, 3)
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("expected %v, got %v", db.ErrNotFound, err)
+	}
+}
+
+func TestListWorkflowDataVersions(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	wfID := uuid.New().String()
+	steps := []string{`{"step":1}`, `{"step":2}`, `{"step":3}`, `{"step":4}`}
+	for _, data := range steps {
+		err := tinkDB.InsertIntoWfDataTable(ctx, &pb.UpdateWorkflowDataRequest{
+			WorkflowId: wfID,
+			Data:       []byte(data),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := tinkDB.ListWorkflowDataVersions(ctx, wfID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, versions, len(steps))
+	for i, v := range versions {
+		assert.Equal(t, int32(i+1), v.Version)
+		assert.False(t, v.CreatedAt.IsZero(), "version %d has no creation time", v.Version)
+		if i > 0 {
+			assert.False(t, v.CreatedAt.Before(versions[i-1].CreatedAt), "version %d created before version %d", v.Version, versions[i-1].Version)
+		}
+	}
+	// only the last three versions keep their data
+	assert.Equal(t, 0, versions[0].Size)
+	for _, v := range versions[1:] {
+		assert.Positive(t, v.Size)
+	}
+
+	versions, err = tinkDB.ListWorkflowDataVersions(ctx, uuid.New().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, versions)
+}
+
+func TestGetWorkerWorkflowContexts(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+	wfIDs := []string{}
+	for i := 0; i < 3; i++ {
+		wfID, err := createWorkflow(ctx, tinkDB, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wfIDs = append(wfIDs, wfID)
+	}
+
+	contexts, err := tinkDB.GetWorkerWorkflowContexts(ctx, in.hardware.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contexts) != 3 {
+		t.Fatalf("expected 3 workflow contexts, got %d", len(contexts))
+	}
+
+	// A failed workflow is not active anymore.
+	err = tinkDB.UpdateWorkflowState(ctx, &pb.WorkflowContext{
+		WorkflowId:         wfIDs[1],
+		CurrentTask:        "run_one_worker",
+		CurrentAction:      "server_partitioning",
+		CurrentActionState: pb.State_STATE_FAILED,
+		CurrentActionIndex: 0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contexts, err = tinkDB.GetWorkerWorkflowContexts(ctx, in.hardware.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, c := range contexts {
+		got = append(got, c.WorkflowId)
+		assert.Equal(t, int64(2), c.TotalNumberOfActions)
+	}
+	assert.ElementsMatch(t, []string{wfIDs[0], wfIDs[2]}, got)
+
+	contexts, err = tinkDB.GetWorkerWorkflowContexts(ctx, uuid.New().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, contexts)
+}
+
+func TestWorkflowEventSummary(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+	wfID, err := createWorkflow(ctx, tinkDB, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	states := []pb.State{
+		pb.State_STATE_RUNNING, pb.State_STATE_SUCCESS,
+		pb.State_STATE_RUNNING, pb.State_STATE_SUCCESS,
+		pb.State_STATE_RUNNING, pb.State_STATE_FAILED,
+	}
+	for _, st := range states {
+		err := tinkDB.InsertIntoWorkflowEventTable(ctx, &pb.WorkflowActionStatus{
+			WorkflowId:   wfID,
+			WorkerId:     in.hardware.Id,
+			TaskName:     "run_one_worker",
+			ActionName:   "server_partitioning",
+			ActionStatus: st,
+		}, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	summary, err := tinkDB.WorkflowEventSummary(ctx, wfID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]int{"STATE_RUNNING": 3, "STATE_SUCCESS": 2, "STATE_FAILED": 1}, summary)
+
+	summary, err = tinkDB.WorkflowEventSummary(ctx, uuid.New().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, summary)
+}
+
+func TestListWorkflowsByTemplate(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	hw := readHardwareData("./testdata/hardware.json")
+	if err := createHardware(ctx, tinkDB, hw); err != nil {
+		t.Fatal(err)
+	}
+	wfIDs := map[string][]string{}
+	var templateIDs []string
+	for i := 0; i < 2; i++ {
+		in := &input{
+			devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+			hardware: hw,
+			template: func() *workflow.Workflow {
+				tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+				tmp.ID = uuid.New().String()
+				tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+				return tmp
+			}(),
+		}
+		if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+			t.Fatal(err)
+		}
+		templateIDs = append(templateIDs, in.template.ID)
+		for j := 0; j <= i; j++ {
+			wfID, err := createWorkflow(ctx, tinkDB, in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wfIDs[in.template.ID] = append(wfIDs[in.template.ID], wfID)
+		}
+	}
+
+	for _, templateID := range templateIDs {
+		got := []string{}
+		err := tinkDB.ListWorkflowsByTemplate(ctx, uuid.MustParse(templateID), func(wf db.Workflow) error {
+			assert.Equal(t, templateID, wf.Template)
+			got = append(got, wf.ID)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.ElementsMatch(t, wfIDs[templateID], got)
+
+		count, err := tinkDB.CountWorkflowsByTemplate(ctx, uuid.MustParse(templateID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(wfIDs[templateID]), count)
+	}
+
+	count, err := tinkDB.CountWorkflowsByTemplate(ctx, uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, count)
+}
+
+func TestListWorkflowsCtx(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := createWorkflow(ctx, tinkDB, in); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count := 0
+	err := tinkDB.ListWorkflowsCtx(ctx, func(wf db.Workflow) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, count)
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	count = 0
+	err = tinkDB.ListWorkflowsCtx(cancelCtx, func(wf db.Workflow) error {
+		count++
+		cancel()
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, count)
+}
+
+func TestClaimNextAction(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+	const workflowCount = 5
+	wfIDs := []string{}
+	for i := 0; i < workflowCount; i++ {
+		wfID, err := createWorkflow(ctx, tinkDB, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wfIDs = append(wfIDs, wfID)
+	}
+
+	// Two workers claim concurrently until there is nothing left, every
+	// workflow must have its first action claimed exactly once.
+	var (
+		mu      sync.Mutex
+		claimed []string
+		wg      sync.WaitGroup
+	)
+	for w := 0; w < 2; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				action, err := tinkDB.ClaimNextAction(ctx, in.hardware.Id)
+				if errors.Is(err, db.ErrNoWork) {
+					return
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				claimed = append(claimed, action.Name)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, claimed, workflowCount)
+	for _, name := range claimed {
+		assert.Equal(t, "server_partitioning", name)
+	}
+
+	wfCtx, err := tinkDB.GetWorkflowContexts(ctx, wfIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, pb.State_STATE_RUNNING, wfCtx.CurrentActionState)
+	assert.Equal(t, in.hardware.Id, wfCtx.CurrentWorker)
+
+	// Once the first action succeeded, the second one can be claimed.
+	wfCtx.CurrentActionState = pb.State_STATE_SUCCESS
+	if err := tinkDB.UpdateWorkflowState(ctx, wfCtx); err != nil {
+		t.Fatal(err)
+	}
+	action, err := tinkDB.ClaimNextAction(ctx, in.hardware.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "run_one_worker", action.TaskName)
+	assert.Equal(t, "update_db", action.Name)
+
+	_, err = tinkDB.ClaimNextAction(ctx, in.hardware.Id)
+	assert.ErrorIs(t, err, db.ErrNoWork)
+	_, err = tinkDB.ClaimNextAction(ctx, uuid.New().String())
+	assert.ErrorIs(t, err, db.ErrNoWork)
+}
+
+func TestListWorkers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	tmpl := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+	tmpl.ID = uuid.New().String()
+	tmpl.Name = fmt.Sprintf("id_%d", rand.Int())
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, tmpl); err != nil {
+		t.Fatal(err)
+	}
+
+	workers, err := tinkDB.ListWorkers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, workers)
+
+	var want []string
+	for ii, workflowCount := range []int{2, 1, 3} {
+		mac := fmt.Sprintf("08:00:27:00:00:0%d", ii+1)
+		hw := readHardwareData("./testdata/hardware.json")
+		hw.Id = uuid.New().String()
+		hw.Network.Interfaces[0].Dhcp.Mac = mac
+		if err := createHardware(ctx, tinkDB, hw); err != nil {
+			t.Fatal(err)
+		}
+		in := &input{devices: fmt.Sprintf("{\"device_1\":%q}", mac), hardware: hw, template: tmpl}
+		for i := 0; i < workflowCount; i++ {
+			if _, err := createWorkflow(ctx, tinkDB, in); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want = append(want, hw.Id)
+	}
+	sort.Strings(want)
+
+	workers, err = tinkDB.ListWorkers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, want, workers)
+}
+
+func TestCancelWorkflow(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+	wfID, err := createWorkflow(ctx, tinkDB, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first action succeeded, the second one is cancelled.
+	wfCtx := &pb.WorkflowContext{
+		WorkflowId:         wfID,
+		CurrentWorker:      in.hardware.Id,
+		CurrentTask:        "run_one_worker",
+		CurrentAction:      "server_partitioning",
+		CurrentActionState: pb.State_STATE_SUCCESS,
+		CurrentActionIndex: 0,
+	}
+	if err := tinkDB.UpdateWorkflowState(ctx, wfCtx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tinkDB.CancelWorkflow(ctx, wfID); err != nil {
+		t.Fatal(err)
+	}
+
+	wfCtx, err = tinkDB.GetWorkflowContexts(ctx, wfID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, pb.State_STATE_CANCELLED, wfCtx.CurrentActionState)
+	assert.Equal(t, "update_db", wfCtx.CurrentAction)
+	assert.Equal(t, int64(1), wfCtx.CurrentActionIndex)
+
+	var events []*pb.WorkflowActionStatus
+	err = tinkDB.ShowWorkflowEvents(wfID, func(wfs *pb.WorkflowActionStatus) error {
+		events = append(events, wfs)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, pb.State_STATE_CANCELLED, events[0].ActionStatus)
+		assert.Equal(t, "update_db", events[0].ActionName)
+		assert.Equal(t, in.hardware.Id, events[0].WorkerId)
+	}
+
+	contexts, err := tinkDB.GetWorkerWorkflowContexts(ctx, in.hardware.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, contexts, "a cancelled workflow is not active")
+
+	assert.Error(t, tinkDB.CancelWorkflow(ctx, wfID), "a cancelled workflow cannot be cancelled again")
+	assert.True(t, errors.Is(tinkDB.CancelWorkflow(ctx, uuid.New().String()), db.ErrNotFound))
+}
+
+func TestGetCurrentAction(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+	wfID, err := createWorkflow(ctx, tinkDB, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	progress := []struct {
+		action string
+		index  int64
+		state  pb.State
+	}{
+		{"server_partitioning", 0, pb.State_STATE_RUNNING},
+		{"server_partitioning", 0, pb.State_STATE_SUCCESS},
+		{"update_db", 1, pb.State_STATE_FAILED},
+	}
+	for _, p := range progress {
+		wfCtx := &pb.WorkflowContext{
+			WorkflowId:         wfID,
+			CurrentWorker:      in.hardware.Id,
+			CurrentTask:        "run_one_worker",
+			CurrentAction:      p.action,
+			CurrentActionState: p.state,
+			CurrentActionIndex: p.index,
+		}
+		if err := tinkDB.UpdateWorkflowState(ctx, wfCtx); err != nil {
+			t.Fatal(err)
+		}
+		task, action, status, err := tinkDB.GetCurrentAction(ctx, wfID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "run_one_worker", task)
+		assert.Equal(t, p.action, action)
+		assert.Equal(t, int32(p.state), status)
+	}
+
+	_, _, _, err = tinkDB.GetCurrentAction(ctx, uuid.New().String())
+	assert.True(t, errors.Is(err, db.ErrNotFound))
+}
+
+func TestBulkUpdateWorkflowState(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+	var wfIDs []string
+	for i := 0; i < 4; i++ {
+		wfID, err := createWorkflow(ctx, tinkDB, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wfIDs = append(wfIDs, wfID)
+	}
+	// The last workflow is over.
+	if err := tinkDB.CancelWorkflow(ctx, wfIDs[3]); err != nil {
+		t.Fatal(err)
+	}
+	state := func(wfID string) pb.State {
+		wfCtx, err := tinkDB.GetWorkflowContexts(ctx, wfID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return wfCtx.CurrentActionState
+	}
+
+	_, err := tinkDB.BulkUpdateWorkflowState(ctx, wfIDs[:2], 42, false)
+	assert.Error(t, err, "42 is not a workflow state")
+
+	// The whole batch is rejected because of the last workflow.
+	updated, err := tinkDB.BulkUpdateWorkflowState(ctx, []string{wfIDs[0], wfIDs[3]}, int32(pb.State_STATE_FAILED), true)
+	assert.Error(t, err)
+	assert.Equal(t, 0, updated)
+	assert.Equal(t, pb.State_STATE_PENDING, state(wfIDs[0]))
+
+	// The illegal transitions are skipped.
+	updated, err = tinkDB.BulkUpdateWorkflowState(ctx, []string{wfIDs[0], wfIDs[1], wfIDs[3], uuid.New().String()}, int32(pb.State_STATE_FAILED), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, updated)
+	assert.Equal(t, pb.State_STATE_FAILED, state(wfIDs[0]))
+	assert.Equal(t, pb.State_STATE_FAILED, state(wfIDs[1]))
+	assert.Equal(t, pb.State_STATE_PENDING, state(wfIDs[2]))
+	assert.Equal(t, pb.State_STATE_CANCELLED, state(wfIDs[3]))
+
+	updated, err = tinkDB.BulkUpdateWorkflowState(ctx, wfIDs[:3], int32(pb.State_STATE_TIMEOUT), true)
+	assert.Error(t, err, "failed workflows are over")
+	assert.Equal(t, 0, updated)
+	assert.Equal(t, pb.State_STATE_PENDING, state(wfIDs[2]))
+}
+
+func TestReassignWorkflowWorker(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+	wfID, err := createWorkflow(ctx, tinkDB, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first action is completed, only the second one moves.
+	wfCtx := &pb.WorkflowContext{
+		WorkflowId:         wfID,
+		CurrentWorker:      in.hardware.Id,
+		CurrentTask:        "run_one_worker",
+		CurrentAction:      "server_partitioning",
+		CurrentActionState: pb.State_STATE_SUCCESS,
+		CurrentActionIndex: 0,
+	}
+	if err := tinkDB.UpdateWorkflowState(ctx, wfCtx); err != nil {
+		t.Fatal(err)
+	}
+	newWorker := uuid.New().String()
+	err = tinkDB.ReassignWorkflowWorker(ctx, wfID, "not-a-worker", newWorker)
+	assert.Error(t, err)
+	// The worker IDs are compared as UUIDs.
+	if err := tinkDB.ReassignWorkflowWorker(ctx, wfID, strings.ToUpper(in.hardware.Id), newWorker); err != nil {
+		t.Fatal(err)
+	}
+
+	actions, err := tinkDB.GetWorkflowActions(ctx, wfID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, actions.ActionList, 2)
+	assert.Equal(t, in.hardware.Id, actions.ActionList[0].WorkerId)
+	assert.Equal(t, newWorker, actions.ActionList[1].WorkerId)
+
+	wfs, err := tinkDB.GetWorkflowsForWorker(ctx, newWorker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{wfID}, wfs)
+	// The old worker keeps the workflow of the action it ran.
+	wfs, err = tinkDB.GetWorkflowsForWorker(ctx, in.hardware.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{wfID}, wfs)
+
+	// Nothing is left for the old worker.
+	err = tinkDB.ReassignWorkflowWorker(ctx, wfID, in.hardware.Id, uuid.New().String())
+	assert.Error(t, err)
+
+	err = tinkDB.ReassignWorkflowWorker(ctx, uuid.New().String(), in.hardware.Id, newWorker)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+
+	// A terminal workflow cannot be reassigned.
+	wfCtx.CurrentWorker = newWorker
+	wfCtx.CurrentAction = "update_db"
+	wfCtx.CurrentActionIndex = 1
+	if err := tinkDB.UpdateWorkflowState(ctx, wfCtx); err != nil {
+		t.Fatal(err)
+	}
+	err = tinkDB.ReassignWorkflowWorker(ctx, wfID, newWorker, in.hardware.Id)
+	assert.Error(t, err)
+	actions, err = tinkDB.GetWorkflowActions(ctx, wfID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, newWorker, actions.ActionList[1].WorkerId)
+}
+
+func TestRecentWorkflows(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+
+	wfs, err := tinkDB.RecentWorkflows(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, wfs)
+
+	var wfIDs []string
+	for i := 0; i < 5; i++ {
+		wfID, err := createWorkflow(ctx, tinkDB, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wfIDs = append(wfIDs, wfID)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	wfs, err = tinkDB.RecentWorkflows(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, wf := range wfs {
+		got = append(got, wf.ID)
+	}
+	assert.Equal(t, []string{wfIDs[4], wfIDs[3], wfIDs[2]}, got)
+
+	// The deleted workflows are ignored.
+	if err := tinkDB.DeleteWorkflow(ctx, wfIDs[4], 0); err != nil {
+		t.Fatal(err)
+	}
+	wfs, err = tinkDB.RecentWorkflows(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, wfs, 4) {
+		assert.Equal(t, wfIDs[3], wfs[0].ID)
+		assert.Equal(t, wfIDs[0], wfs[3].ID)
+	}
+
+	wfs, err = tinkDB.RecentWorkflows(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, wfs)
+}
+
+func TestGetLatestWorkflowForWorker(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := tinkDB.GetLatestWorkflowForWorker(ctx, in.hardware.Id)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+
+	var wfIDs []string
+	for i := 0; i < 3; i++ {
+		wfID, err := createWorkflow(ctx, tinkDB, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wfIDs = append(wfIDs, wfID)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	wf, err := tinkDB.GetLatestWorkflowForWorker(ctx, in.hardware.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, wfIDs[2], wf.ID)
+	assert.Equal(t, in.template.ID, wf.Template)
+
+	// The deleted workflows are ignored.
+	if err := tinkDB.DeleteWorkflow(ctx, wfIDs[2], 0); err != nil {
+		t.Fatal(err)
+	}
+	wf, err = tinkDB.GetLatestWorkflowForWorker(ctx, in.hardware.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, wfIDs[1], wf.ID)
+
+	_, err = tinkDB.GetLatestWorkflowForWorker(ctx, uuid.New().String())
+	assert.ErrorIs(t, err, db.ErrNotFound)
+}
+
+func TestExportWorkflowEventsCSV(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+	wfID, err := createWorkflow(ctx, tinkDB, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2022, 10, 16, 15, 0, 0, 0, time.UTC)
+	events := []*pb.WorkflowActionStatus{
+		{ActionName: "server_partitioning", ActionStatus: pb.State_STATE_RUNNING},
+		{ActionName: "server_partitioning", ActionStatus: pb.State_STATE_SUCCESS, Seconds: 12},
+		{ActionName: "update_db", ActionStatus: pb.State_STATE_FAILED, Seconds: 3},
+	}
+	for i, ev := range events {
+		ev.WorkflowId = wfID
+		ev.WorkerId = in.hardware.Id
+		ev.TaskName = "run_one_worker"
+		if err := tinkDB.InsertIntoWorkflowEventTable(ctx, ev, start.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	if err := tinkDB.ExportWorkflowEventsCSV(wfID, out); err != nil {
+		t.Fatal(err)
+	}
+	want := `timestamp,task,action,status,seconds
+2022-10-16T15:00:00Z,run_one_worker,server_partitioning,STATE_RUNNING,0
+2022-10-16T15:00:01Z,run_one_worker,server_partitioning,STATE_SUCCESS,12
+2022-10-16T15:00:02Z,run_one_worker,update_db,STATE_FAILED,3
+`
+	assert.Equal(t, want, out.String())
+}
+
+func TestWorkflowDuration(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+	wfID, err := createWorkflow(ctx, tinkDB, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	duration, completed, err := tinkDB.WorkflowDuration(ctx, wfID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, time.Duration(0), duration)
+	assert.False(t, completed)
+
+	start := time.Date(2022, 10, 16, 15, 0, 0, 0, time.UTC)
+	events := []struct {
+		action string
+		state  pb.State
+		at     time.Duration
+	}{
+		{"server_partitioning", pb.State_STATE_RUNNING, 0},
+		{"server_partitioning", pb.State_STATE_SUCCESS, 12 * time.Second},
+		{"update_db", pb.State_STATE_RUNNING, 13 * time.Second},
+		{"update_db", pb.State_STATE_SUCCESS, 90 * time.Second},
+	}
+	for i, ev := range events {
+		err := tinkDB.InsertIntoWorkflowEventTable(ctx, &pb.WorkflowActionStatus{
+			WorkflowId:   wfID,
+			WorkerId:     in.hardware.Id,
+			TaskName:     "run_one_worker",
+			ActionName:   ev.action,
+			ActionStatus: ev.state,
+		}, start.Add(ev.at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tinkDB.UpdateWorkflowState(ctx, &pb.WorkflowContext{
+			WorkflowId:         wfID,
+			CurrentWorker:      in.hardware.Id,
+			CurrentTask:        "run_one_worker",
+			CurrentAction:      ev.action,
+			CurrentActionState: ev.state,
+			CurrentActionIndex: int64(i / 2),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		duration, completed, err := tinkDB.WorkflowDuration(ctx, wfID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, ev.at, duration)
+		assert.Equal(t, i == len(events)-1, completed)
+	}
+
+	_, _, err = tinkDB.WorkflowDuration(ctx, uuid.New().String())
+	assert.True(t, errors.Is(err, db.ErrNotFound))
+}
+
+func TestTruncateWorkflowEvents(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+	wfID, err := createWorkflow(ctx, tinkDB, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherID, err := createWorkflow(ctx, tinkDB, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const eventCount = 50
+	start := time.Now()
+	for i := 0; i < eventCount; i++ {
+		for _, id := range []string{wfID, otherID} {
+			err := tinkDB.InsertIntoWorkflowEventTable(ctx, &pb.WorkflowActionStatus{
+				WorkflowId:   id,
+				WorkerId:     in.hardware.Id,
+				TaskName:     "run_one_worker",
+				ActionName:   "server_partitioning",
+				Message:      fmt.Sprintf("event %d", i),
+				ActionStatus: pb.State_STATE_RUNNING,
+			}, start.Add(time.Duration(i)*time.Second))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	messages := func(id string) []string {
+		t.Helper()
+		var msgs []string
+		err := tinkDB.ShowWorkflowEvents(id, func(wfs *pb.WorkflowActionStatus) error {
+			msgs = append(msgs, wfs.Message)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msgs
+	}
+
+	deleted, err := tinkDB.TruncateWorkflowEvents(ctx, wfID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, eventCount-10, deleted)
+	msgs := messages(wfID)
+	assert.Len(t, msgs, 10)
+	assert.Equal(t, "event 40", msgs[0])
+	assert.Equal(t, "event 49", msgs[9])
+	assert.Len(t, messages(otherID), eventCount, "the events of the other workflows are kept")
+
+	_, err = tinkDB.GetWorkflow(ctx, wfID)
+	assert.NoError(t, err, "the workflow is kept")
+
+	deleted, err = tinkDB.TruncateWorkflowEvents(ctx, wfID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Zero(t, deleted)
+
+	deleted, err = tinkDB.TruncateWorkflowEvents(ctx, wfID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 10, deleted)
+	assert.Empty(t, messages(wfID))
+
+	_, err = tinkDB.TruncateWorkflowEvents(ctx, wfID, -1)
+	assert.Error(t, err)
+}
+
+func TestShowWorkflowEventsAfter(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in := &input{
+		devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+		hardware: readHardwareData("./testdata/hardware.json"),
+		template: func() *workflow.Workflow {
+			tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+			tmp.ID = uuid.New().String()
+			tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+			return tmp
+		}(),
+	}
+	if err := createHardware(ctx, tinkDB, in.hardware); err != nil {
+		t.Fatal(err)
+	}
+	if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+		t.Fatal(err)
+	}
+	wfID, err := createWorkflow(ctx, tinkDB, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const eventCount = 5
+	start := time.Now().Truncate(time.Second)
+	for i := 0; i < eventCount; i++ {
+		err := tinkDB.InsertIntoWorkflowEventTable(ctx, &pb.WorkflowActionStatus{
+			WorkflowId:   wfID,
+			WorkerId:     in.hardware.Id,
+			TaskName:     "run_one_worker",
+			ActionName:   "server_partitioning",
+			Message:      fmt.Sprintf("event %d", i),
+			ActionStatus: pb.State_STATE_RUNNING,
+		}, start.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	messages := func(after time.Time) []string {
+		t.Helper()
+		var msgs []string
+		err := tinkDB.ShowWorkflowEventsAfter(ctx, wfID, after, func(wfs *pb.WorkflowActionStatus) error {
+			msgs = append(msgs, wfs.Message)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msgs
+	}
+
+	assert.Equal(t, []string{"event 0", "event 1", "event 2", "event 3", "event 4"}, messages(time.Time{}))
+	assert.Equal(t, []string{"event 2", "event 3", "event 4"}, messages(start.Add(2*time.Second)))
+	assert.Empty(t, messages(start.Add(5*time.Second)))
+
+	// An event created at the same time as the last one seen is returned.
+	err = tinkDB.InsertIntoWorkflowEventTable(ctx, &pb.WorkflowActionStatus{
+		WorkflowId:   wfID,
+		WorkerId:     in.hardware.Id,
+		TaskName:     "run_one_worker",
+		ActionName:   "server_partitioning",
+		Message:      "event 5",
+		ActionStatus: pb.State_STATE_RUNNING,
+	}, start.Add(4*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.ElementsMatch(t, []string{"event 4", "event 5"}, messages(start.Add(4*time.Second)))
+
+	// The cursor stays valid once the older events are truncated.
+	if _, err := tinkDB.TruncateWorkflowEvents(ctx, wfID, 3); err != nil {
+		t.Fatal(err)
+	}
+	assert.ElementsMatch(t, []string{"event 3", "event 4", "event 5"}, messages(start.Add(3*time.Second)))
+}
+
+func TestListOrphanedWorkflows(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, tinkDB, cl := NewPostgresDatabaseClient(ctx, t, NewPostgresDatabaseRequest{
+		ApplyMigration: true,
+	})
+	defer func() {
+		err := cl()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	hw := readHardwareData("./testdata/hardware.json")
+	if err := createHardware(ctx, tinkDB, hw); err != nil {
+		t.Fatal(err)
+	}
+	wfIDs := map[string][]string{}
+	var templateIDs []string
+	for i := 0; i < 2; i++ {
+		in := &input{
+			devices:  "{\"device_1\":\"08:00:27:00:00:01\"}",
+			hardware: hw,
+			template: func() *workflow.Workflow {
+				tmp := workflow.MustParseFromFile("./testdata/template_happy_path_1.yaml")
+				tmp.ID = uuid.New().String()
+				tmp.Name = fmt.Sprintf("id_%d", rand.Int())
+				return tmp
+			}(),
+		}
+		if err := createTemplateFromWorkflowType(ctx, tinkDB, in.template); err != nil {
+			t.Fatal(err)
+		}
+		templateIDs = append(templateIDs, in.template.ID)
+		for j := 0; j < 2; j++ {
+			wfID, err := createWorkflow(ctx, tinkDB, in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wfIDs[in.template.ID] = append(wfIDs[in.template.ID], wfID)
+		}
+	}
+
+	orphans := func() []string {
+		t.Helper()
+		got := []string{}
+		err := tinkDB.ListOrphanedWorkflows(ctx, func(wf db.Workflow) error {
+			got = append(got, wf.ID)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	assert.Empty(t, orphans())
+
+	if err := tinkDB.DeleteTemplate(ctx, templateIDs[0]); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, wfIDs[templateIDs[0]], orphans())
+
+	// The deleted workflows are not reported.
+	if err := tinkDB.DeleteWorkflow(ctx, wfIDs[templateIDs[0]][0], 0); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, wfIDs[templateIDs[0]][1:], orphans())
+}
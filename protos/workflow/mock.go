@@ -60,6 +60,9 @@ var _ WorkflowServiceClient = &WorkflowServiceClientMock{}
 // 			ShowWorkflowEventsFunc: func(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (WorkflowService_ShowWorkflowEventsClient, error) {
 // 				panic("mock out the ShowWorkflowEvents method")
 // 			},
+// 			ShowWorkflowEventsAfterFunc: func(ctx context.Context, in *WorkflowEventsRequest, opts ...grpc.CallOption) (WorkflowService_ShowWorkflowEventsAfterClient, error) {
+// 				panic("mock out the ShowWorkflowEventsAfter method")
+// 			},
 // 			UpdateWorkflowDataFunc: func(ctx context.Context, in *UpdateWorkflowDataRequest, opts ...grpc.CallOption) (*Empty, error) {
 // 				panic("mock out the UpdateWorkflowData method")
 // 			},
@@ -109,6 +112,9 @@ type WorkflowServiceClientMock struct {
 	// ShowWorkflowEventsFunc mocks the ShowWorkflowEvents method.
 	ShowWorkflowEventsFunc func(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (WorkflowService_ShowWorkflowEventsClient, error)
 
+	// ShowWorkflowEventsAfterFunc mocks the ShowWorkflowEventsAfter method.
+	ShowWorkflowEventsAfterFunc func(ctx context.Context, in *WorkflowEventsRequest, opts ...grpc.CallOption) (WorkflowService_ShowWorkflowEventsAfterClient, error)
+
 	// UpdateWorkflowDataFunc mocks the UpdateWorkflowData method.
 	UpdateWorkflowDataFunc func(ctx context.Context, in *UpdateWorkflowDataRequest, opts ...grpc.CallOption) (*Empty, error)
 
@@ -231,6 +237,15 @@ type WorkflowServiceClientMock struct {
 			// Opts is the opts argument value.
 			Opts []grpc.CallOption
 		}
+		// ShowWorkflowEventsAfter holds details about calls to the ShowWorkflowEventsAfter method.
+		ShowWorkflowEventsAfter []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *WorkflowEventsRequest
+			// Opts is the opts argument value.
+			Opts []grpc.CallOption
+		}
 		// UpdateWorkflowData holds details about calls to the UpdateWorkflowData method.
 		UpdateWorkflowData []struct {
 			// Ctx is the ctx argument value.
@@ -241,20 +256,21 @@ type WorkflowServiceClientMock struct {
 			Opts []grpc.CallOption
 		}
 	}
-	lockCreateWorkflow         sync.RWMutex
-	lockDeleteWorkflow         sync.RWMutex
-	lockGetWorkflow            sync.RWMutex
-	lockGetWorkflowActions     sync.RWMutex
-	lockGetWorkflowContext     sync.RWMutex
-	lockGetWorkflowContextList sync.RWMutex
-	lockGetWorkflowContexts    sync.RWMutex
-	lockGetWorkflowData        sync.RWMutex
-	lockGetWorkflowDataVersion sync.RWMutex
-	lockGetWorkflowMetadata    sync.RWMutex
-	lockListWorkflows          sync.RWMutex
-	lockReportActionStatus     sync.RWMutex
-	lockShowWorkflowEvents     sync.RWMutex
-	lockUpdateWorkflowData     sync.RWMutex
+	lockCreateWorkflow          sync.RWMutex
+	lockDeleteWorkflow          sync.RWMutex
+	lockGetWorkflow             sync.RWMutex
+	lockGetWorkflowActions      sync.RWMutex
+	lockGetWorkflowContext      sync.RWMutex
+	lockGetWorkflowContextList  sync.RWMutex
+	lockGetWorkflowContexts     sync.RWMutex
+	lockGetWorkflowData         sync.RWMutex
+	lockGetWorkflowDataVersion  sync.RWMutex
+	lockGetWorkflowMetadata     sync.RWMutex
+	lockListWorkflows           sync.RWMutex
+	lockReportActionStatus      sync.RWMutex
+	lockShowWorkflowEvents      sync.RWMutex
+	lockShowWorkflowEventsAfter sync.RWMutex
+	lockUpdateWorkflowData      sync.RWMutex
 }
 
 // CreateWorkflow calls CreateWorkflowFunc.
@@ -764,6 +780,45 @@ func (mock *WorkflowServiceClientMock) ShowWorkflowEventsCalls() []struct {
 	return calls
 }
 
+// ShowWorkflowEventsAfter calls ShowWorkflowEventsAfterFunc.
+func (mock *WorkflowServiceClientMock) ShowWorkflowEventsAfter(ctx context.Context, in *WorkflowEventsRequest, opts ...grpc.CallOption) (WorkflowService_ShowWorkflowEventsAfterClient, error) {
+	if mock.ShowWorkflowEventsAfterFunc == nil {
+		panic("WorkflowServiceClientMock.ShowWorkflowEventsAfterFunc: method is nil but WorkflowServiceClient.ShowWorkflowEventsAfter was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		In   *WorkflowEventsRequest
+		Opts []grpc.CallOption
+	}{
+		Ctx:  ctx,
+		In:   in,
+		Opts: opts,
+	}
+	mock.lockShowWorkflowEventsAfter.Lock()
+	mock.calls.ShowWorkflowEventsAfter = append(mock.calls.ShowWorkflowEventsAfter, callInfo)
+	mock.lockShowWorkflowEventsAfter.Unlock()
+	return mock.ShowWorkflowEventsAfterFunc(ctx, in, opts...)
+}
+
+// ShowWorkflowEventsAfterCalls gets all the calls that were made to ShowWorkflowEventsAfter.
+// Check the length with:
+//     len(mockedWorkflowServiceClient.ShowWorkflowEventsAfterCalls())
+func (mock *WorkflowServiceClientMock) ShowWorkflowEventsAfterCalls() []struct {
+	Ctx  context.Context
+	In   *WorkflowEventsRequest
+	Opts []grpc.CallOption
+} {
+	var calls []struct {
+		Ctx  context.Context
+		In   *WorkflowEventsRequest
+		Opts []grpc.CallOption
+	}
+	mock.lockShowWorkflowEventsAfter.RLock()
+	calls = mock.calls.ShowWorkflowEventsAfter
+	mock.lockShowWorkflowEventsAfter.RUnlock()
+	return calls
+}
+
 // UpdateWorkflowData calls UpdateWorkflowDataFunc.
 func (mock *WorkflowServiceClientMock) UpdateWorkflowData(ctx context.Context, in *UpdateWorkflowDataRequest, opts ...grpc.CallOption) (*Empty, error) {
 	if mock.UpdateWorkflowDataFunc == nil {
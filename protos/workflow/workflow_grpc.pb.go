@@ -50,6 +50,10 @@ type WorkflowServiceClient interface {
 	GetWorkflowMetadata(ctx context.Context, in *GetWorkflowDataRequest, opts ...grpc.CallOption) (*GetWorkflowDataResponse, error)
 	GetWorkflowDataVersion(ctx context.Context, in *GetWorkflowDataRequest, opts ...grpc.CallOption) (*GetWorkflowDataResponse, error)
 	UpdateWorkflowData(ctx context.Context, in *UpdateWorkflowDataRequest, opts ...grpc.CallOption) (*Empty, error)
+	//
+	// ShowWorkflowEventsAfter returns the events of a workflow created at or after
+	// a cursor
+	ShowWorkflowEventsAfter(ctx context.Context, in *WorkflowEventsRequest, opts ...grpc.CallOption) (WorkflowService_ShowWorkflowEventsAfterClient, error)
 }
 
 type workflowServiceClient struct {
@@ -255,6 +259,38 @@ func (c *workflowServiceClient) UpdateWorkflowData(ctx context.Context, in *Upda
 	return out, nil
 }
 
+func (c *workflowServiceClient) ShowWorkflowEventsAfter(ctx context.Context, in *WorkflowEventsRequest, opts ...grpc.CallOption) (WorkflowService_ShowWorkflowEventsAfterClient, error) {
+	stream, err := c.cc.NewStream(ctx, &WorkflowService_ServiceDesc.Streams[3], "/github.com.tinkerbell.tink.protos.workflow.WorkflowService/ShowWorkflowEventsAfter", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &workflowServiceShowWorkflowEventsAfterClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type WorkflowService_ShowWorkflowEventsAfterClient interface {
+	Recv() (*WorkflowActionStatus, error)
+	grpc.ClientStream
+}
+
+type workflowServiceShowWorkflowEventsAfterClient struct {
+	grpc.ClientStream
+}
+
+func (x *workflowServiceShowWorkflowEventsAfterClient) Recv() (*WorkflowActionStatus, error) {
+	m := new(WorkflowActionStatus)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // WorkflowServiceServer is the server API for WorkflowService service.
 // All implementations should embed UnimplementedWorkflowServiceServer
 // for forward compatibility
@@ -290,6 +326,10 @@ type WorkflowServiceServer interface {
 	GetWorkflowMetadata(context.Context, *GetWorkflowDataRequest) (*GetWorkflowDataResponse, error)
 	GetWorkflowDataVersion(context.Context, *GetWorkflowDataRequest) (*GetWorkflowDataResponse, error)
 	UpdateWorkflowData(context.Context, *UpdateWorkflowDataRequest) (*Empty, error)
+	//
+	// ShowWorkflowEventsAfter returns the events of a workflow created at or after
+	// a cursor
+	ShowWorkflowEventsAfter(*WorkflowEventsRequest, WorkflowService_ShowWorkflowEventsAfterServer) error
 }
 
 // UnimplementedWorkflowServiceServer should be embedded to have forward compatible implementations.
@@ -351,6 +391,10 @@ func (UnimplementedWorkflowServiceServer) UpdateWorkflowData(context.Context, *U
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateWorkflowData not implemented")
 }
 
+func (UnimplementedWorkflowServiceServer) ShowWorkflowEventsAfter(*WorkflowEventsRequest, WorkflowService_ShowWorkflowEventsAfterServer) error {
+	return status.Errorf(codes.Unimplemented, "method ShowWorkflowEventsAfter not implemented")
+}
+
 // UnsafeWorkflowServiceServer may be embedded to opt out of forward compatibility for this service.
 // Use of this interface is not recommended, as added methods to WorkflowServiceServer will
 // result in compilation errors.
@@ -623,6 +667,27 @@ func _WorkflowService_UpdateWorkflowData_Handler(srv interface{}, ctx context.Co
 	return interceptor(ctx, in, info, handler)
 }
 
+func _WorkflowService_ShowWorkflowEventsAfter_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WorkflowEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WorkflowServiceServer).ShowWorkflowEventsAfter(m, &workflowServiceShowWorkflowEventsAfterServer{stream})
+}
+
+type WorkflowService_ShowWorkflowEventsAfterServer interface {
+	Send(*WorkflowActionStatus) error
+	grpc.ServerStream
+}
+
+type workflowServiceShowWorkflowEventsAfterServer struct {
+	grpc.ServerStream
+}
+
+func (x *workflowServiceShowWorkflowEventsAfterServer) Send(m *WorkflowActionStatus) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // WorkflowService_ServiceDesc is the grpc.ServiceDesc for WorkflowService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -691,6 +756,11 @@ var WorkflowService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _WorkflowService_GetWorkflowContexts_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "ShowWorkflowEventsAfter",
+			Handler:       _WorkflowService_ShowWorkflowEventsAfter_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "protos/workflow/workflow.proto",
 }
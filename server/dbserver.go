@@ -19,6 +19,7 @@ const (
 	errInvalidTaskReported   = "reported task name does not match the current action details"
 	errInvalidActionReported = "reported action name does not match the current action details"
 	errInvalidActionIndex    = "invalid action index for workflow"
+	errWorkflowCancelled     = "workflow is cancelled"
 
 	msgReceivedStatus   = "received action status: %s"
 	msgCurrentWfContext = "current workflow context"
@@ -11,6 +11,7 @@ import (
 	"github.com/tinkerbell/tink/db"
 	"github.com/tinkerbell/tink/metrics"
 	"github.com/tinkerbell/tink/protos/hardware"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -41,7 +42,7 @@ func (s *DBServer) Push(ctx context.Context, in *hardware.PushRequest) (*hardwar
 	if hw.GetId() == "" {
 		metrics.CacheTotals.With(labels).Inc()
 		metrics.CacheErrors.With(labels).Inc()
-		err := errors.New("id must be set to a UUID, got id: " + hw.Id)
+		err := invalidHardwareData("id", "must be set to a UUID, got id: "+hw.Id)
 		s.logger.Error(err)
 		return &hardware.Empty{}, err
 	}
@@ -293,7 +294,7 @@ func (s *DBServer) Delete(ctx context.Context, in *hardware.DeleteRequest) (*har
 }
 
 func (s *DBServer) validateHardwareData(ctx context.Context, hw *hardware.Hardware) error {
-	for _, iface := range hw.GetNetwork().GetInterfaces() {
+	for i, iface := range hw.GetNetwork().GetInterfaces() {
 		mac := iface.GetDhcp().GetMac()
 
 		if data, _ := s.db.GetByMAC(ctx, mac); data != "" {
@@ -309,13 +310,28 @@ func (s *DBServer) validateHardwareData(ctx context.Context, hw *hardware.Hardwa
 				return nil
 			}
 
-			return fmt.Errorf(conflictMACAddr, mac)
+			field := fmt.Sprintf("network.interfaces[%d].dhcp.mac", i)
+			return invalidHardwareData(field, fmt.Sprintf(conflictMACAddr, mac))
 		}
 	}
 
 	return nil
 }
 
+// invalidHardwareData returns the InvalidArgument status of the hardware data
+// rejected because of the field, with a BadRequest detail telling the clients
+// which field and why.
+func invalidHardwareData(field, reason string) error {
+	st := status.New(codes.InvalidArgument, "invalid hardware data: "+field+": "+reason)
+	detailed, err := st.WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: field, Description: reason}},
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
 func normalizeHardwareData(hw *hardware.Hardware) {
 	// Ensure MAC is stored as lowercase
 	for _, iface := range hw.GetNetwork().GetInterfaces() {
@@ -85,6 +85,9 @@ func (s *DBServer) ReportActionStatus(ctx context.Context, req *workflow.Workflo
 	if err != nil {
 		return nil, status.Errorf(codes.Aborted, err.Error())
 	}
+	if wfContext.GetCurrentActionState() == workflow.State_STATE_CANCELLED {
+		return nil, status.Errorf(codes.FailedPrecondition, errWorkflowCancelled)
+	}
 	wfActions, err := s.db.GetWorkflowActions(ctx, wfID)
 	if err != nil {
 		return nil, status.Errorf(codes.Aborted, err.Error())
@@ -206,7 +209,8 @@ func getWorkflowActions(ctx context.Context, d db.Database, wfID string) (*workf
 // be sent to a worker based on the state of the current action and the targeted workerID.
 func isApplicableToSend(ctx context.Context, logger log.Logger, wfContext *workflow.WorkflowContext, workerID string, d db.Database) bool {
 	if wfContext.GetCurrentActionState() == workflow.State_STATE_FAILED ||
-		wfContext.GetCurrentActionState() == workflow.State_STATE_TIMEOUT {
+		wfContext.GetCurrentActionState() == workflow.State_STATE_TIMEOUT ||
+		wfContext.GetCurrentActionState() == workflow.State_STATE_CANCELLED {
 		return false
 	}
 	actions, err := getWorkflowActions(ctx, d, wfContext.GetWorkflowId())
@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/packethost/pkg/log"
@@ -169,7 +170,7 @@ func (s *DBServer) ListWorkflows(_ *workflow.Empty, stream workflow.WorkflowServ
 
 	timer := prometheus.NewTimer(metrics.CacheDuration.With(labels))
 	defer timer.ObserveDuration()
-	err := s.db.ListWorkflows(func(w db.Workflow) error {
+	err := s.db.ListWorkflowsCtx(stream.Context(), func(w db.Workflow) error {
 		wf := &workflow.Workflow{
 			Id:        w.ID,
 			Template:  w.Template,
@@ -274,6 +275,36 @@ func (s *DBServer) ShowWorkflowEvents(req *workflow.GetRequest, stream workflow.
 	return nil
 }
 
+// ShowWorkflowEventsAfter implements workflow.ShowWorkflowEventsAfter.
+func (s *DBServer) ShowWorkflowEventsAfter(req *workflow.WorkflowEventsRequest, stream workflow.WorkflowService_ShowWorkflowEventsAfterServer) error {
+	labels := prometheus.Labels{"method": "ShowWorkflowEventsAfter", "op": "list"}
+	metrics.CacheTotals.With(labels).Inc()
+	metrics.CacheInFlight.With(labels).Inc()
+	defer metrics.CacheInFlight.With(labels).Dec()
+
+	s.dbLock.RLock()
+	ready := s.dbReady //nolint:ifshort // needed for locking
+	s.dbLock.RUnlock()
+	if !ready {
+		metrics.CacheStalls.With(labels).Inc()
+		return errors.New("DB is not ready")
+	}
+
+	var after time.Time
+	if req.GetAfter() != nil {
+		after = req.GetAfter().AsTime()
+	}
+	timer := prometheus.NewTimer(metrics.CacheDuration.With(labels))
+	defer timer.ObserveDuration()
+	err := s.db.ShowWorkflowEventsAfter(stream.Context(), req.GetId(), after, stream.Send)
+	if err != nil {
+		metrics.CacheErrors.With(labels).Inc()
+		return err
+	}
+	metrics.CacheHits.With(labels).Inc()
+	return nil
+}
+
 // This function will provide the workflow state on the basis of the state of the actions
 // For e.g. : If an action has Failed or Timeout then the workflow state will also be
 // considered as Failed/Timeout. And If an action is successful then the workflow state
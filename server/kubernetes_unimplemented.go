@@ -34,6 +34,11 @@ func (s *KubernetesBackedServer) ShowWorkflowEvents(*pb.GetRequest, pb.WorkflowS
 	return errNotImplemented
 }
 
+// ShowWorkflowEventsAfter will return a not implemented error.
+func (s *KubernetesBackedServer) ShowWorkflowEventsAfter(*pb.WorkflowEventsRequest, pb.WorkflowService_ShowWorkflowEventsAfterServer) error {
+	return errNotImplemented
+}
+
 // GetWorkflowContext will return a not implemented error.
 func (s *KubernetesBackedServer) GetWorkflowContext(context.Context, *pb.GetRequest) (*pb.WorkflowContext, error) {
 	return nil, errNotImplemented
@@ -0,0 +1,104 @@
+package workflow
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"sync"
+)
+
+// RenderCache renders workflow templates, keeping the most recently used
+// parsed templates so that rendering the same template again skips the
+// parsing. Templates are identified by the hash of their data, so a template
+// whose data changes is parsed again and its previous version ages out of the
+// cache. A RenderCache is safe for concurrent use.
+type RenderCache struct {
+	size int
+
+	mu        sync.Mutex
+	lru       *list.List
+	entries   map[[sha256.Size]byte]*list.Element
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type renderCacheEntry struct {
+	key    [sha256.Size]byte
+	parsed *parsedTemplate
+}
+
+// NewRenderCache returns a RenderCache holding at most size parsed templates.
+// A size lower than 1 disables the cache.
+func NewRenderCache(size int) *RenderCache {
+	return &RenderCache{
+		size:    size,
+		lru:     list.New(),
+		entries: map[[sha256.Size]byte]*list.Element{},
+	}
+}
+
+// RenderTemplateHardware renders the workflow template like the package
+// level RenderTemplateHardware.
+func (c *RenderCache) RenderTemplateHardware(templateID, templateData string, hardware map[string]interface{}, opts ...RenderOption) (*Workflow, *bytes.Buffer, error) {
+	p, err := c.parse(templateID, templateData)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.render(templateID, hardware, opts...)
+}
+
+// Len returns the number of parsed templates in the cache.
+func (c *RenderCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Stats returns the number of renders which found their template in the
+// cache, the number of renders which had to parse it and the number of
+// templates evicted to make room for others.
+func (c *RenderCache) Stats() (hits, misses, evictions uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses, c.evictions
+}
+
+func (c *RenderCache) parse(templateID, templateData string) (*parsedTemplate, error) {
+	key := sha256.Sum256([]byte(templateData))
+
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.hits++
+		c.lru.MoveToFront(el)
+		c.mu.Unlock()
+		return el.Value.(*renderCacheEntry).parsed, nil
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	// Templates that fail to parse are not cached, they are not expected to
+	// be rendered again.
+	p, err := parseTemplate(templateID, templateData)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size < 1 {
+		return p, nil
+	}
+	if el, ok := c.entries[key]; ok {
+		// Parsed concurrently by another render.
+		return el.Value.(*renderCacheEntry).parsed, nil
+	}
+	c.entries[key] = c.lru.PushFront(&renderCacheEntry{key: key, parsed: p})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*renderCacheEntry).key)
+		c.evictions++
+	}
+	return p, nil
+}
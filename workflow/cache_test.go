@@ -0,0 +1,67 @@
+package workflow
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderCache(t *testing.T) {
+	hardware := map[string]interface{}{"device_1": "08:00:27:00:00:01"}
+	templates := make([]string, 3)
+	for i := range templates {
+		templates[i] = fmt.Sprintf(`
+version: "0.1"
+name: cached_%d
+tasks:
+  - name: "hello world"
+    worker: "{{ .device_1 }}"
+    actions:
+    - name: "hello_world"
+      image: hello-world
+      timeout: 60
+`, i)
+	}
+	cache := NewRenderCache(2)
+
+	render := func(templateData string) {
+		t.Helper()
+		wf, _, err := cache.RenderTemplateHardware("cached", templateData, hardware)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "08:00:27:00:00:01", wf.Tasks[0].WorkerAddr)
+	}
+
+	render(templates[0])
+	render(templates[0])
+	render(templates[1])
+	assert.Equal(t, 2, cache.Len())
+	hits, misses, evictions := cache.Stats()
+	assert.Equal(t, []uint64{1, 2, 0}, []uint64{hits, misses, evictions})
+
+	// At capacity, the least recently used template is evicted.
+	render(templates[0])
+	render(templates[2])
+	assert.Equal(t, 2, cache.Len())
+	hits, misses, evictions = cache.Stats()
+	assert.Equal(t, []uint64{2, 3, 1}, []uint64{hits, misses, evictions})
+
+	render(templates[0])
+	render(templates[1])
+	hits, misses, evictions = cache.Stats()
+	assert.Equal(t, []uint64{3, 4, 2}, []uint64{hits, misses, evictions})
+
+	// Invalid templates are not cached.
+	_, _, err := cache.RenderTemplateHardware("invalid", `{{ .device_1 }`, hardware)
+	assert.Error(t, err)
+	assert.Equal(t, 2, cache.Len())
+}
+
+func TestRenderCacheDisabled(t *testing.T) {
+	cache := NewRenderCache(0)
+	_, _, err := cache.RenderTemplateHardware("cached", validTemplate, map[string]interface{}{"device_1": "08:00:27:00:00:01"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, cache.Len())
+}
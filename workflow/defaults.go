@@ -0,0 +1,140 @@
+package workflow
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// reservedKeys are the keys of the template data set by tink itself, which
+// template defaults cannot provide.
+var reservedKeys = map[string]struct{}{
+	"Hardware": {},
+	varsKey:    {},
+}
+
+// templateDefaults returns the defaults block of the template data. The block
+// is read before rendering, from the template data with its actions masked,
+// so it has to be a top-level block without template actions. The defaults of
+// a template which is not valid YAML before rendering are not read; its
+// rendered output is parsed and reported on anyway.
+func templateDefaults(templateData string) (map[string]interface{}, error) {
+	doc, actionLines := parseMasked(templateData)
+	if doc == nil {
+		return nil, nil
+	}
+	key, value := topLevelKey(doc, "defaults")
+	if key == nil {
+		return nil, nil
+	}
+	last := lastLine(value)
+	for _, line := range actionLines {
+		if line >= key.Line && line <= last {
+			return nil, errors.Errorf("parsing template defaults: template action at line %d", line)
+		}
+	}
+
+	var defaults map[string]interface{}
+	if err := value.Decode(&defaults); err != nil {
+		return nil, errors.Wrap(err, "parsing template defaults")
+	}
+	if err := validateDefaults(defaults); err != nil {
+		return nil, err
+	}
+	return defaults, nil
+}
+
+// parseMasked returns the top-level mapping of the template data parsed with
+// its actions masked, nil when it is not a YAML mapping before rendering, and
+// the lines the actions start at.
+func parseMasked(templateData string) (*yamlv3.Node, []int) {
+	masked, actionLines := maskActions(templateData)
+	var root yamlv3.Node
+	if err := yamlv3.Unmarshal([]byte(masked), &root); err != nil || len(root.Content) == 0 {
+		return nil, actionLines
+	}
+	if doc := root.Content[0]; doc.Kind == yamlv3.MappingNode {
+		return doc, actionLines
+	}
+	return nil, actionLines
+}
+
+// topLevelKey returns the key and the value nodes of the key of the mapping,
+// nil when it is not defined.
+func topLevelKey(doc *yamlv3.Node, key string) (*yamlv3.Node, *yamlv3.Node) {
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value == key {
+			return doc.Content[i], doc.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// maskActions replaces the template actions of the template data by spaces,
+// keeping their line breaks, so that the text around them parses as YAML at
+// the same lines. It also returns the lines the actions start at.
+func maskActions(templateData string) (string, []int) {
+	masked := []byte(templateData)
+	var lines []int
+	for start := 0; ; {
+		open := strings.Index(templateData[start:], "{{")
+		if open < 0 {
+			break
+		}
+		open += start
+		end := strings.Index(templateData[open:], "}}")
+		if end < 0 {
+			end = len(templateData)
+		} else {
+			end += open + len("}}")
+		}
+		lines = append(lines, strings.Count(templateData[:open], "\n")+1)
+		for i := open; i < end; i++ {
+			if masked[i] != '\n' {
+				masked[i] = ' '
+			}
+		}
+		start = end
+	}
+	return string(masked), lines
+}
+
+// lastLine returns the last line of the node content.
+func lastLine(node *yamlv3.Node) int {
+	last := node.Line
+	if node.Style&(yamlv3.LiteralStyle|yamlv3.FoldedStyle) != 0 {
+		last += strings.Count(strings.TrimRight(node.Value, "\n"), "\n") + 1
+	}
+	for _, child := range node.Content {
+		if l := lastLine(child); l > last {
+			last = l
+		}
+	}
+	return last
+}
+
+// withDefaults returns the hardware data completed by the defaults of the keys
+// it does not have. The hardware data is not modified.
+func withDefaults(hardware, defaults map[string]interface{}) map[string]interface{} {
+	if len(defaults) == 0 {
+		return hardware
+	}
+	data := make(map[string]interface{}, len(hardware)+len(defaults))
+	for k, v := range defaults {
+		data[k] = v
+	}
+	for k, v := range hardware {
+		data[k] = v
+	}
+	return data
+}
+
+func validateDefaults(defaults map[string]interface{}) error {
+	for k := range defaults {
+		if _, ok := reservedKeys[k]; ok {
+			return errors.Errorf(errReservedDefault, k)
+		}
+	}
+	return nil
+}
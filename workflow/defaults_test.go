@@ -0,0 +1,131 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const defaultsTemplate = `
+version: "0.1"
+name: defaults
+defaults:
+  # fallback values when the hardware data does not have them
+  disk: /dev/sda
+  mirror: 192.168.1.2
+tasks:
+  - name: "provision"
+    worker: "{{ .device_1 }}"
+    actions:
+    - name: "stream"
+      image: image2disk
+      timeout: 60
+      environment:
+        DEST_DISK: {{ .disk }}
+        MIRROR_HOST: {{ .mirror }}
+`
+
+func TestRenderTemplateHardwareDefaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		hardware map[string]interface{}
+		want     map[string]string
+	}{
+		{
+			name:     "defaults are used",
+			hardware: map[string]interface{}{"device_1": "08:00:27:00:00:01"},
+			want:     map[string]string{"DEST_DISK": "/dev/sda", "MIRROR_HOST": "192.168.1.2"},
+		},
+		{
+			name:     "hardware overrides defaults",
+			hardware: map[string]interface{}{"device_1": "08:00:27:00:00:01", "disk": "/dev/nvme0n1"},
+			want:     map[string]string{"DEST_DISK": "/dev/nvme0n1", "MIRROR_HOST": "192.168.1.2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf, _, err := RenderTemplateHardware("defaults", defaultsTemplate, tt.hardware)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, wf.Tasks[0].Actions[0].Environment); diff != "" {
+				t.Errorf("unexpected environment (-want +got):\n%s", diff)
+			}
+			if _, ok := tt.hardware["mirror"]; ok {
+				t.Error("expected the hardware data not to be modified")
+			}
+		})
+	}
+}
+
+func TestRenderTemplateHardwareReservedDefault(t *testing.T) {
+	tmpl := strings.Replace(defaultsTemplate, "  disk: /dev/sda", "  Hardware: {}", 1)
+	_, _, err := RenderTemplateHardware("defaults", tmpl, map[string]interface{}{"device_1": "08:00:27:00:00:01"})
+	if err == nil || !strings.Contains(err.Error(), "default Hardware collides with a reserved key") {
+		t.Errorf("expected a reserved key error, got %v", err)
+	}
+}
+
+func TestLintDefaults(t *testing.T) {
+	got := Lint(defaultsTemplate, map[string]interface{}{"device_1": "08:00:27:00:00:01"})
+	if len(got) != 0 {
+		t.Errorf("expected no warning, got %v", got)
+	}
+}
+
+func TestTemplateDefaults(t *testing.T) {
+	tests := []struct {
+		name         string
+		templateData string
+		want         map[string]interface{}
+		wantErr      string
+	}{
+		{
+			name:         "block",
+			templateData: defaultsTemplate,
+			want:         map[string]interface{}{"disk": "/dev/sda", "mirror": "192.168.1.2"},
+		},
+		{
+			name:         "flow",
+			templateData: "name: defaults\ndefaults: {disk: /dev/sda}\ntasks: []\n",
+			want:         map[string]interface{}{"disk": "/dev/sda"},
+		},
+		{
+			name:         "action after the block",
+			templateData: "defaults:\n  disk: /dev/sda\n{{- if .device_1 }}\nname: defaults\n{{- end }}\n",
+			want:         map[string]interface{}{"disk": "/dev/sda"},
+		},
+		{
+			name:         "nested defaults",
+			templateData: "name: defaults\ntasks:\n  - name: {{ .name }}\n    defaults:\n      disk: /dev/sda\n",
+		},
+		{
+			name:         "action in the block",
+			templateData: "defaults:\n  disk: {{ .disk }}\nname: defaults\n",
+			wantErr:      "template action at line 2",
+		},
+		{
+			name:         "reserved key",
+			templateData: "defaults:\n  Hardware: {}\n",
+			wantErr:      "default Hardware collides with a reserved key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := templateDefaults(tt.templateData)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("unexpected defaults (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,16 +1,55 @@
 package workflow
 
 import (
+	"crypto/sha1" //nolint:gosec // sha1sum is not used for security
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
 )
 
 // templateFuncs defines the custom functions available to workflow templates.
 var templateFuncs = map[string]interface{}{
-	"contains":        strings.Contains,
-	"hasPrefix":       strings.HasPrefix,
-	"hasSuffix":       strings.HasSuffix,
-	"formatPartition": formatPartition,
+	"contains":          strings.Contains,
+	"hasPrefix":         strings.HasPrefix,
+	"hasSuffix":         strings.HasSuffix,
+	"formatPartition":   formatPartition,
+	"uuidv5":            uuidv5,
+	"cidrhost":          cidrhost,
+	"cidrnetmask":       cidrnetmask,
+	"cidrsubnet":        cidrsubnet,
+	"join":              join,
+	"splitList":         splitList,
+	"required":          required,
+	"dig":               dig,
+	"toBytes":           toBytes,
+	"humanizeBytes":     humanizeBytes,
+	"normalizeMAC":      normalizeMAC,
+	"readFile":          readFileIn(""),
+	"matches":           matches,
+	"hasLabel":          hasLabel,
+	"systemdEscape":     systemdEscape,
+	"systemdEscapePath": systemdEscapePath,
+	"sha256sum":         sha256sum,
+	"sha1sum":           sha1sum,
+	"now":               time.Now,
+	"dateFormat":        dateFormat,
+	"enumerate":         enumerate,
+	"coalesce":          coalesce,
 }
 
 // formatPartition formats a device path with partition for the device type. If it receives an
@@ -29,3 +68,550 @@ func formatPartition(dev string, partition int) string {
 	}
 	return dev
 }
+
+// uuidv5 returns the RFC 4122 version 5 UUID for name within namespace. The same
+// namespace and name always produce the same UUID. The namespace is either a UUID
+// or an arbitrary string, which is itself turned into a UUID within the nil namespace.
+//
+// Examples
+//
+//	uuidv5 "6ba7b810-9dad-11d1-80b4-00c04fd430c8" "tinkerbell.org" -> d3e7f6ad-5dad-5fb1-842a-1c1cd5da4ea6
+//	uuidv5 "tink" .ID -> the same UUID for the same .ID
+func uuidv5(namespace, name string) string {
+	ns, err := uuid.Parse(namespace)
+	if err != nil {
+		ns = uuid.NewSHA1(uuid.Nil, []byte(namespace))
+	}
+	return uuid.NewSHA1(ns, []byte(name)).String()
+}
+
+// join concatenates the elements of list, separated by sep. The separator comes
+// first so that the list can be piped in. Elements which are not strings are
+// formatted with their default format and a nil list gives an empty string.
+//
+// Examples
+//
+//	join "," .dns -> 1.1.1.1,8.8.8.8
+//	.dns | join "," -> 1.1.1.1,8.8.8.8
+func join(sep string, list interface{}) string {
+	switch l := list.(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(l, sep)
+	case []interface{}:
+		s := make([]string, 0, len(l))
+		for _, v := range l {
+			if v == nil {
+				continue
+			}
+			s = append(s, fmt.Sprint(v))
+		}
+		return strings.Join(s, sep)
+	}
+	return fmt.Sprint(list)
+}
+
+// splitList splits s around each instance of sep. The separator comes first so
+// that the string can be piped in. An empty string gives an empty list.
+//
+// Examples
+//
+//	splitList "," "1.1.1.1,8.8.8.8" -> [1.1.1.1 8.8.8.8]
+//	.dns | splitList "," -> [1.1.1.1 8.8.8.8]
+func splitList(sep, s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, sep)
+}
+
+// enumerate pairs every element of the list with its index, from 0, for
+// range to give both to the template, as .index and .value. A nil list gives
+// an empty list.
+//
+// Examples
+//
+//	range enumerate .disks -> {"index": 0, "value": "/dev/sda"} {"index": 1, "value": "/dev/sdb"}
+//	range enumerate .disks -> partition-disk-{{ .index }} uses {{ .value }}
+func enumerate(list interface{}) ([]map[string]interface{}, error) {
+	if list == nil {
+		return []map[string]interface{}{}, nil
+	}
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, errors.Errorf("enumerate needs a list, got %T", list)
+	}
+	pairs := make([]map[string]interface{}, v.Len())
+	for i := range pairs {
+		pairs[i] = map[string]interface{}{"index": i, "value": v.Index(i).Interface()}
+	}
+	return pairs, nil
+}
+
+// coalesce returns the first of the values which is not empty, that is nil or
+// an empty string, and an empty string when they all are. The fields of the
+// data given to coalesce may be missing, they are empty.
+//
+// Examples
+//
+//	coalesce .PreferredIP .DHCPIP "0.0.0.0" -> the first of .PreferredIP and .DHCPIP set, or 0.0.0.0
+//	.DHCPIP | coalesce .PreferredIP -> .PreferredIP, or .DHCPIP when it is not set
+func coalesce(values ...interface{}) interface{} {
+	for _, value := range values {
+		if !isEmptyValue(value) {
+			return value
+		}
+	}
+	return ""
+}
+
+// dig traverses the nested maps of data following the keys and returns the
+// value found, or the default when any of the keys is missing. The default
+// comes right before the data, which comes last.
+//
+// Examples
+//
+//	dig "metadata" "instance" "id" "default" . -> the value of .metadata.instance.id
+//	dig "metadata" "missing" "id" "default" . -> default
+func dig(args ...interface{}) (interface{}, error) {
+	if len(args) < 3 {
+		return nil, errors.Errorf("dig needs at least a key, a default and the data, got %d arguments", len(args))
+	}
+	keys, def, value := args[:len(args)-2], args[len(args)-2], args[len(args)-1]
+	for _, k := range keys {
+		key, ok := k.(string)
+		if !ok {
+			return nil, errors.Errorf("dig keys must be strings, got %T", k)
+		}
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return def, nil
+		}
+		if value, ok = m[key]; !ok || value == nil {
+			return def, nil
+		}
+	}
+	return value, nil
+}
+
+// matches tells whether the value is equal to the pattern. A pattern enclosed
+// in slashes is a regular expression the value must match instead. The value
+// is formatted like fmt.Sprint does, a nil value never matches.
+//
+// Examples
+//
+//	matches .arch "arm64" -> true for arm64
+//	matches .arch "/^(arm64|aarch64)$/" -> true for arm64 and aarch64
+//	matches .plan "/^c3\\./" -> true for c3.small.x86
+func matches(value interface{}, pattern string) (bool, error) {
+	if value == nil {
+		return false, nil
+	}
+	s := fmt.Sprint(value)
+	if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid matches pattern %s", pattern)
+		}
+		return re.MatchString(s), nil
+	}
+	return s == pattern, nil
+}
+
+// hasLabel tells whether the labels, like the hardware metadata, have the key.
+// When a value is given, the label must also match it, see matches.
+//
+// Examples
+//
+//	hasLabel .labels "gpu" -> true when .labels has a gpu key
+//	hasLabel .labels "role" "worker" -> true when the role label is worker
+//	hasLabel .labels "role" "/^(worker|storage)$/" -> true for both roles
+func hasLabel(labels interface{}, key string, value ...string) (bool, error) {
+	if len(value) > 1 {
+		return false, errors.Errorf("hasLabel takes at most one value, got %d", len(value))
+	}
+	m, ok := labels.(map[string]interface{})
+	if !ok {
+		return false, nil
+	}
+	v, ok := m[key]
+	if !ok || v == nil {
+		return false, nil
+	}
+	if len(value) == 0 {
+		return true, nil
+	}
+	return matches(v, value[0])
+}
+
+// systemdEscape escapes the string like systemd-escape does, for use in
+// systemd unit names: "/" becomes "-", ASCII letters, digits, ":", "_" and "."
+// are kept, except a leading ".", and every other byte is written as \xNN.
+//
+// Examples
+//
+//	systemdEscape "/dev/sda1" -> -dev-sda1
+//	systemdEscape "Hallöchen, Meister" -> Hall\xc3\xb6chen\x2c\x20Meister
+func systemdEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '/':
+			b.WriteByte('-')
+		case c == '.' && i == 0, !isSystemdUnitChar(c):
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// systemdEscapePath escapes the path like systemd-escape --path does, for the
+// names of the mount, automount and device units. The path is simplified and
+// its leading and trailing slashes are removed, the root path is "-".
+//
+// Examples
+//
+//	systemdEscapePath "/dev/sda1" -> dev-sda1
+//	systemdEscapePath "/mnt//data/" -> mnt-data
+//	systemdEscapePath "/" -> -
+func systemdEscapePath(p string) (string, error) {
+	var elems []string
+	for _, elem := range strings.Split(p, "/") {
+		switch elem {
+		case "", ".":
+		case "..":
+			return "", errors.Errorf("invalid path %q for systemdEscapePath", p)
+		default:
+			elems = append(elems, elem)
+		}
+	}
+	if len(elems) == 0 {
+		return "-", nil
+	}
+	return systemdEscape(strings.Join(elems, "/")), nil
+}
+
+func isSystemdUnitChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+		c == ':' || c == '_' || c == '.'
+}
+
+// sha256sum returns the hex encoded SHA-256 digest of the value. Strings are
+// hashed as they are, the other values, like the whole hardware data, are
+// hashed in their JSON encoding, whose map keys are sorted so that the digest
+// is stable.
+//
+// Examples
+//
+//	sha256sum "abc" -> ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad
+//	sha256sum . -> the digest of the hardware data
+func sha256sum(value interface{}) (string, error) {
+	data, err := hashInput(value)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// sha1sum returns the hex encoded SHA-1 digest of the value, see sha256sum.
+//
+// Examples
+//
+//	sha1sum "abc" -> a9993e364706816aba3e25717850c26c9cd0d89d
+func sha1sum(value interface{}) (string, error) {
+	data, err := hashInput(value)
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(data) //nolint:gosec // not used for security
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func hashInput(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot hash value")
+	}
+	return data, nil
+}
+
+// dateFormat formats the time with the Go reference time layout, in UTC so
+// that the rendered template does not depend on the time zone of the host.
+// The current time, now, is fixed by the WithRenderTime render option.
+//
+// Examples
+//
+//	now | dateFormat "2006-01-02" -> 2022-10-16
+//	now | dateFormat "20060102T150405Z" -> 20221016T150405Z
+func dateFormat(layout string, t time.Time) string {
+	return t.UTC().Format(layout)
+}
+
+// maxReadFileSize is the size of the largest file readFile returns.
+const maxReadFileSize = 1 << 20
+
+// readFileIn returns the readFile function of the templates, reading the files
+// of baseDir. The function is disabled when baseDir is empty.
+//
+// Examples
+//
+//	readFile "ignition/base.ign" -> the content of <baseDir>/ignition/base.ign
+//	readFile "../etc/passwd" -> error
+func readFileIn(baseDir string) func(name string) (string, error) {
+	return func(name string) (string, error) {
+		if baseDir == "" {
+			return "", errors.New("readFile is disabled, no base directory is configured")
+		}
+		if name == "" || filepath.IsAbs(name) {
+			return "", errors.Errorf("readFile needs a path relative to the base directory, got %q", name)
+		}
+		for _, elem := range strings.Split(filepath.ToSlash(name), "/") {
+			if elem == ".." {
+				return "", errors.Errorf("readFile path cannot leave the base directory: %s", name)
+			}
+		}
+
+		// Symbolic links are resolved so that they cannot lead out of the
+		// base directory either.
+		base, err := filepath.EvalSymlinks(baseDir)
+		if err != nil {
+			return "", errors.Wrap(err, "readFile base directory")
+		}
+		file, err := filepath.EvalSymlinks(filepath.Join(base, name))
+		if err != nil {
+			return "", errors.Wrap(err, "readFile")
+		}
+		if rel, err := filepath.Rel(base, file); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", errors.Errorf("readFile path cannot leave the base directory: %s", name)
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return "", errors.Wrap(err, "readFile")
+		}
+		defer f.Close()
+		data, err := io.ReadAll(io.LimitReader(f, maxReadFileSize+1))
+		if err != nil {
+			return "", errors.Wrap(err, "readFile")
+		}
+		if len(data) > maxReadFileSize {
+			return "", errors.Errorf("readFile %s is larger than %d bytes", name, maxReadFileSize)
+		}
+		return string(data), nil
+	}
+}
+
+// normalizeMAC returns the MAC address in its canonical lowercase, colon
+// separated form. The MAC address can be colon, dash or dot separated, or
+// made of 12 hexadecimal digits without separator.
+//
+// Examples
+//
+//	normalizeMAC "08-00-27-AB-CD-EF" -> 08:00:27:ab:cd:ef
+//	normalizeMAC "0800.27ab.cdef" -> 08:00:27:ab:cd:ef
+//	normalizeMAC "080027ABCDEF" -> 08:00:27:ab:cd:ef
+func normalizeMAC(mac string) (string, error) {
+	s := strings.TrimSpace(mac)
+	if len(s) == 12 && !strings.ContainsAny(s, ":-.") {
+		s = s[0:4] + "." + s[4:8] + "." + s[8:12]
+	}
+	hw, err := net.ParseMAC(s)
+	if err != nil {
+		return "", errors.Errorf("invalid MAC address %q", mac)
+	}
+	return hw.String(), nil
+}
+
+// byteUnits are the multiples of the byte accepted by toBytes, by lower case
+// symbol. Both the SI (powers of 1000) and the IEC (powers of 1024) units are
+// supported.
+var byteUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"pb":  1000 * 1000 * 1000 * 1000 * 1000,
+	"eb":  1000 * 1000 * 1000 * 1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+	"pib": 1 << 50,
+	"eib": 1 << 60,
+}
+
+// iecUnits are the units used by humanizeBytes, from the largest.
+var iecUnits = []string{"EiB", "PiB", "TiB", "GiB", "MiB", "KiB"}
+
+// toBytes returns the number of bytes of a size made of a number, which may
+// have decimals, and an optional SI or IEC unit. The unit is case insensitive
+// and a fraction of byte is truncated.
+//
+// Examples
+//
+//	toBytes "10GiB" -> 10737418240
+//	toBytes "1.5 KiB" -> 1536
+//	toBytes "10GB" -> 10000000000
+//	toBytes "512" -> 512
+func toBytes(size string) (int64, error) {
+	s := strings.TrimSpace(size)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i == -1 {
+		i = len(s)
+	}
+	number, unit := s[:i], strings.ToLower(strings.TrimSpace(s[i:]))
+
+	multiple, ok := byteUnits[unit]
+	if !ok {
+		return 0, errors.Errorf("invalid size %q: unknown unit %q", size, s[i:])
+	}
+	value, ok := new(big.Rat).SetString(number)
+	if number == "" || !ok {
+		return 0, errors.Errorf("invalid size %q", size)
+	}
+	bytes := new(big.Int).Mul(value.Num(), big.NewInt(multiple))
+	bytes.Quo(bytes, value.Denom())
+	if !bytes.IsInt64() {
+		return 0, errors.Errorf("invalid size %q: too large", size)
+	}
+	return bytes.Int64(), nil
+}
+
+// humanizeBytes formats a number of bytes with the largest IEC unit keeping at
+// least one unit, with up to two decimals. The result is accepted by toBytes.
+// Sizes reported by the hardware data as numbers or strings are accepted.
+//
+// Examples
+//
+//	humanizeBytes 10737418240 -> 10GiB
+//	humanizeBytes 1536 -> 1.5KiB
+//	humanizeBytes 512 -> 512B
+func humanizeBytes(size interface{}) (string, error) {
+	var bytes float64
+	switch v := size.(type) {
+	case int:
+		bytes = float64(v)
+	case int64:
+		bytes = float64(v)
+	case uint64:
+		bytes = float64(v)
+	case float64:
+		bytes = v
+	case string:
+		b, err := toBytes(v)
+		if err != nil {
+			return "", err
+		}
+		bytes = float64(b)
+	default:
+		return "", errors.Errorf("invalid size %v: expected a number, got %T", size, size)
+	}
+	if bytes < 0 {
+		return "", errors.Errorf("invalid size %v: negative", size)
+	}
+
+	for i, unit := range iecUnits {
+		multiple := float64(int64(1) << (10 * (len(iecUnits) - i)))
+		if bytes >= multiple {
+			return strconv.FormatFloat(math.Round(bytes/multiple*100)/100, 'f', -1, 64) + unit, nil
+		}
+	}
+	return strconv.FormatFloat(math.Floor(bytes), 'f', -1, 64) + "B", nil
+}
+
+// cidrhost returns the address of the given host number within the prefix. A
+// negative host number counts backwards from the end of the prefix.
+//
+// Examples
+//
+//	cidrhost "10.12.112.0/20" 16 -> 10.12.112.16
+//	cidrhost "10.12.112.0/20" -1 -> 10.12.127.255
+//	cidrhost "fd00:fd12:3456:7890:00a2::/72" 34 -> fd00:fd12:3456:7890::22
+func cidrhost(prefix string, hostnum int) (string, error) {
+	network, err := parseCIDR(prefix)
+	if err != nil {
+		return "", err
+	}
+	ones, bits := network.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+
+	host := big.NewInt(int64(hostnum))
+	if hostnum < 0 {
+		host.Add(host, size)
+	}
+	if host.Sign() < 0 || host.Cmp(size) >= 0 {
+		return "", errors.Errorf("prefix %s has no host number %d", prefix, hostnum)
+	}
+	return addToIP(network.IP, host).String(), nil
+}
+
+// cidrnetmask returns the netmask of an IPv4 prefix in dotted-decimal notation.
+//
+// Examples
+//
+//	cidrnetmask "172.16.0.0/12" -> 255.240.0.0
+func cidrnetmask(prefix string) (string, error) {
+	network, err := parseCIDR(prefix)
+	if err != nil {
+		return "", err
+	}
+	if network.IP.To4() == nil {
+		return "", errors.Errorf("only IPv4 prefixes have a netmask: %s", prefix)
+	}
+	return net.IP(network.Mask).String(), nil
+}
+
+// cidrsubnet returns the subnet number netnum of the prefix extended by newbits
+// additional bits.
+//
+// Examples
+//
+//	cidrsubnet "172.16.0.0/12" 4 2 -> 172.18.0.0/16
+//	cidrsubnet "fd00:fd12:3456:7890::/56" 16 162 -> fd00:fd12:3456:7800:a200::/72
+func cidrsubnet(prefix string, newbits, netnum int) (string, error) {
+	network, err := parseCIDR(prefix)
+	if err != nil {
+		return "", err
+	}
+	ones, bits := network.Mask.Size()
+	if newbits < 0 || ones+newbits > bits {
+		return "", errors.Errorf("cannot extend prefix %s by %d bits", prefix, newbits)
+	}
+	if netnum < 0 || big.NewInt(int64(netnum)).BitLen() > newbits {
+		return "", errors.Errorf("prefix %s extended by %d bits has no subnet number %d", prefix, newbits, netnum)
+	}
+
+	offset := new(big.Int).Lsh(big.NewInt(int64(netnum)), uint(bits-ones-newbits))
+	return fmt.Sprintf("%s/%d", addToIP(network.IP, offset), ones+newbits), nil
+}
+
+func parseCIDR(prefix string) (*net.IPNet, error) {
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid CIDR %q", prefix)
+	}
+	return network, nil
+}
+
+// addToIP returns ip incremented by n, keeping the length of ip.
+func addToIP(ip net.IP, n *big.Int) net.IP {
+	sum := new(big.Int).Add(new(big.Int).SetBytes(ip), n).Bytes()
+	out := make(net.IP, len(ip))
+	copy(out[len(out)-len(sum):], sum)
+	return out
+}
@@ -0,0 +1,1177 @@
+package workflow
+
+import (
+	"crypto/sha1" //nolint:gosec // test helper
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUUIDv5(t *testing.T) {
+	format := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	tests := []struct {
+		name      string
+		namespace string
+		input     string
+		want      string
+	}{
+		{
+			name:      "uuid namespace",
+			namespace: "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+			input:     "tinkerbell.org",
+			want:      "d3e7f6ad-5dad-5fb1-842a-1c1cd5da4ea6",
+		},
+		{
+			name:      "string namespace",
+			namespace: "tink",
+			input:     "0eba0bf8",
+			want:      "b2a65e93-7080-5ce2-812f-3e20a993a3ac",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := uuidv5(tt.namespace, tt.input)
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+			if again := uuidv5(tt.namespace, tt.input); again != got {
+				t.Errorf("expected the same uuid on every call, got %s and %s", got, again)
+			}
+			if !format.MatchString(got) {
+				t.Errorf("%s is not a version 5 uuid", got)
+			}
+		})
+	}
+
+	if uuidv5("tink", "a") == uuidv5("tink", "b") {
+		t.Error("expected different names to produce different uuids")
+	}
+}
+
+func TestJoin(t *testing.T) {
+	tests := []struct {
+		name string
+		list interface{}
+		want string
+	}{
+		{name: "nil", list: nil, want: ""},
+		{name: "empty", list: []interface{}{}, want: ""},
+		{name: "single", list: []interface{}{"1.1.1.1"}, want: "1.1.1.1"},
+		{name: "strings", list: []string{"1.1.1.1", "8.8.8.8"}, want: "1.1.1.1,8.8.8.8"},
+		{name: "mixed", list: []interface{}{"sda", 1, nil, true}, want: "sda,1,true"},
+		{name: "not a list", list: "sda", want: "sda"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := join(",", tt.list); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []string
+	}{
+		{name: "empty", s: "", want: []string{}},
+		{name: "single", s: "1.1.1.1", want: []string{"1.1.1.1"}},
+		{name: "multiple", s: "1.1.1.1,8.8.8.8", want: []string{"1.1.1.1", "8.8.8.8"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, splitList(",", tt.s)); diff != "" {
+				t.Errorf("unexpected list (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEnumerate(t *testing.T) {
+	pairs := func(values ...interface{}) []map[string]interface{} {
+		out := []map[string]interface{}{}
+		for i, v := range values {
+			out = append(out, map[string]interface{}{"index": i, "value": v})
+		}
+		return out
+	}
+	tests := []struct {
+		name    string
+		list    interface{}
+		want    []map[string]interface{}
+		wantErr bool
+	}{
+		{name: "nil", list: nil, want: pairs()},
+		{name: "empty", list: []interface{}{}, want: pairs()},
+		{name: "strings", list: []string{"/dev/sda", "/dev/sdb"}, want: pairs("/dev/sda", "/dev/sdb")},
+		{name: "mixed", list: []interface{}{"/dev/sda", map[string]interface{}{"device": "/dev/nvme0n1"}}, want: pairs("/dev/sda", map[string]interface{}{"device": "/dev/nvme0n1"})},
+		{name: "not a list", list: "/dev/sda", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := enumerate(tt.list)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("unexpected pairs (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateEnumerate(t *testing.T) {
+	tmpl := `
+version: "0.1"
+name: partitions
+tasks:
+  - name: "partitioning"
+    worker: "{{.device_1}}"
+    actions:
+{{- range enumerate .disks }}
+    - name: "partition-disk-{{ .index }}"
+      image: disk-partition
+      timeout: 90
+      environment:
+        DEST_DISK: {{ .value.device }}
+{{- end }}
+`
+	hardware := map[string]interface{}{
+		"device_1": "08:00:27:00:00:01",
+		"disks": []interface{}{
+			map[string]interface{}{"device": "/dev/sda"},
+			map[string]interface{}{"device": "/dev/nvme0n1"},
+		},
+	}
+	wf, buf, err := RenderTemplateHardware("partitions", tmpl, hardware)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := wf.Tasks[0].Actions
+	if len(actions) != 2 {
+		t.Fatalf("expected 2 actions, got %d", len(actions))
+	}
+	for i, want := range []string{"/dev/sda", "/dev/nvme0n1"} {
+		if name := fmt.Sprintf("partition-disk-%d", i); actions[i].Name != name {
+			t.Errorf("expected action %s, got %s", name, actions[i].Name)
+		}
+		if got := actions[i].Environment["DEST_DISK"]; got != want {
+			t.Errorf("expected disk %s, got %s", want, got)
+		}
+	}
+	if _, err := Parse(buf.Bytes()); err != nil {
+		t.Errorf("expected the rendered template to parse, got %v", err)
+	}
+}
+
+func TestRenderTemplateJoin(t *testing.T) {
+	tmpl := `
+version: "0.1"
+name: dns
+tasks:
+  - name: "network"
+    worker: "08:00:27:00:00:01"
+    actions:
+    - name: "configure"
+      image: network
+      environment:
+        DNS: {{ join "," .dns }}
+        FIRST_DNS: {{ index (.csv | splitList ",") 0 }}
+`
+	wf, _, err := RenderTemplateHardware("dns", tmpl, map[string]interface{}{
+		"dns": []interface{}{"1.1.1.1", "8.8.8.8"},
+		"csv": "9.9.9.9,8.8.4.4",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"DNS": "1.1.1.1,8.8.8.8", "FIRST_DNS": "9.9.9.9"}
+	if diff := cmp.Diff(want, wf.Tasks[0].Actions[0].Environment); diff != "" {
+		t.Errorf("unexpected environment (-want +got):\n%s", diff)
+	}
+}
+
+func TestCIDRHost(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		hostnum int
+		want    string
+		wantErr bool
+	}{
+		{prefix: "10.12.112.0/20", hostnum: 16, want: "10.12.112.16"},
+		{prefix: "10.12.112.0/20", hostnum: 268, want: "10.12.113.12"},
+		{prefix: "10.12.112.0/20", hostnum: -1, want: "10.12.127.255"},
+		{prefix: "192.168.1.0/24", hostnum: 256, wantErr: true},
+		{prefix: "192.168.1.0/24", hostnum: -257, wantErr: true},
+		{prefix: "fd00:fd12:3456:7890:00a2::/72", hostnum: 34, want: "fd00:fd12:3456:7890::22"},
+		{prefix: "fd00:fd12:3456:7890::/64", hostnum: -1, want: "fd00:fd12:3456:7890:ffff:ffff:ffff:ffff"},
+		{prefix: "10.12.112.0", hostnum: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.prefix, tt.hostnum), func(t *testing.T) {
+			got, err := cidrhost(tt.prefix, tt.hostnum)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCIDRNetmask(t *testing.T) {
+	got, err := cidrnetmask("172.16.0.0/12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "255.240.0.0" {
+		t.Errorf("expected 255.240.0.0, got %s", got)
+	}
+
+	if _, err := cidrnetmask("fd00::/64"); err == nil {
+		t.Error("expected an error for an IPv6 prefix")
+	}
+	if _, err := cidrnetmask("not-a-cidr"); err == nil {
+		t.Error("expected an error for a malformed prefix")
+	}
+}
+
+func TestCIDRSubnet(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		newbits int
+		netnum  int
+		want    string
+		wantErr bool
+	}{
+		{prefix: "172.16.0.0/12", newbits: 4, netnum: 2, want: "172.18.0.0/16"},
+		{prefix: "10.1.2.0/24", newbits: 4, netnum: 15, want: "10.1.2.240/28"},
+		{prefix: "10.1.2.0/24", newbits: 4, netnum: 16, wantErr: true},
+		{prefix: "10.1.2.0/24", newbits: 9, netnum: 0, wantErr: true},
+		{prefix: "fd00:fd12:3456:7890::/56", newbits: 16, netnum: 162, want: "fd00:fd12:3456:7800:a200::/72"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s+%d/%d", tt.prefix, tt.newbits, tt.netnum), func(t *testing.T) {
+			got, err := cidrsubnet(tt.prefix, tt.newbits, tt.netnum)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateCIDRError(t *testing.T) {
+	tmpl := `
+version: "0.1"
+name: cidr
+tasks:
+  - name: "network"
+    worker: "08:00:27:00:00:01"
+    actions:
+    - name: "configure"
+      image: network
+      environment:
+        GATEWAY: {{ cidrhost .cidr 1 }}
+`
+	_, _, err := RenderTemplateHardware("cidr", tmpl, map[string]interface{}{"cidr": "10.0.0.0/33"})
+	if err == nil || !strings.Contains(err.Error(), `invalid CIDR "10.0.0.0/33"`) {
+		t.Errorf("expected an invalid CIDR error, got %v", err)
+	}
+}
+
+func TestRenderTemplateRequired(t *testing.T) {
+	tmpl := `
+version: "0.1"
+name: required
+tasks:
+  - name: "disk"
+    worker: "08:00:27:00:00:01"
+    actions:
+    - name: "wipe"
+      image: wipe
+      environment:
+        DISK: {{ required "disk is mandatory" .Disk }}
+        {{- range .partitions }}
+        PARTITION_{{ .number }}: {{ .label | required "partitions need a label" }}
+        {{- end }}
+        FACILITY: {{ required "facility is mandatory" $.metadata.facility }}
+`
+	tests := []struct {
+		name     string
+		hardware map[string]interface{}
+		want     string
+	}{
+		{
+			name: "present",
+			hardware: map[string]interface{}{
+				"Disk":       "/dev/sda",
+				"partitions": []interface{}{map[string]interface{}{"number": 1, "label": "root"}},
+				"metadata":   map[string]interface{}{"facility": "onprem"},
+			},
+		},
+		{
+			name: "missing",
+			hardware: map[string]interface{}{
+				"metadata": map[string]interface{}{"facility": "onprem"},
+			},
+			want: "disk is mandatory",
+		},
+		{
+			name: "empty",
+			hardware: map[string]interface{}{
+				"Disk":     "",
+				"metadata": map[string]interface{}{"facility": "onprem"},
+			},
+			want: "disk is mandatory",
+		},
+		{
+			name: "missing within range",
+			hardware: map[string]interface{}{
+				"Disk":       "/dev/sda",
+				"partitions": []interface{}{map[string]interface{}{"number": 1}},
+				"metadata":   map[string]interface{}{"facility": "onprem"},
+			},
+			want: "partitions need a label",
+		},
+		{
+			name: "missing nested key",
+			hardware: map[string]interface{}{
+				"Disk":       "/dev/sda",
+				"partitions": []interface{}{},
+				"metadata":   map[string]interface{}{},
+			},
+			want: "facility is mandatory",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf, _, err := RenderTemplateHardware("required", tmpl, tt.hardware)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := wf.Tasks[0].Actions[0].Environment["DISK"]; got != "/dev/sda" {
+					t.Errorf("expected /dev/sda, got %s", got)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []interface{}
+		want   interface{}
+	}{
+		{name: "no value", values: nil, want: ""},
+		{name: "all empty", values: []interface{}{nil, "", nil}, want: ""},
+		{name: "first", values: []interface{}{"10.0.0.2", "10.0.0.3"}, want: "10.0.0.2"},
+		{name: "mixed", values: []interface{}{nil, "", "10.0.0.3", "0.0.0.0"}, want: "10.0.0.3"},
+		{name: "not a string", values: []interface{}{"", 0, "0.0.0.0"}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := coalesce(tt.values...); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateCoalesce(t *testing.T) {
+	tmpl := `
+version: "0.1"
+name: coalesce
+tasks:
+  - name: "network"
+    worker: "08:00:27:00:00:01"
+    actions:
+    - name: "configure"
+      image: netplan
+      environment:
+        IP: {{ coalesce .PreferredIP .DHCPIP "0.0.0.0" }}
+        GATEWAY: {{ coalesce $.metadata.gateway .metadata.dhcp_gateway }}
+        DNS: {{ .DHCPDNS | coalesce .DNS }}
+`
+	tests := []struct {
+		name     string
+		hardware map[string]interface{}
+		want     map[string]string
+	}{
+		{
+			name:     "all missing",
+			hardware: map[string]interface{}{},
+			want:     map[string]string{"IP": "0.0.0.0", "GATEWAY": "", "DNS": ""},
+		},
+		{
+			name: "all empty",
+			hardware: map[string]interface{}{
+				"PreferredIP": "",
+				"DHCPIP":      "",
+				"metadata":    map[string]interface{}{"gateway": ""},
+				"DNS":         "",
+			},
+			want: map[string]string{"IP": "0.0.0.0", "GATEWAY": "", "DNS": ""},
+		},
+		{
+			name: "mixed",
+			hardware: map[string]interface{}{
+				"PreferredIP": "",
+				"DHCPIP":      "10.0.0.3",
+				"metadata":    map[string]interface{}{"dhcp_gateway": "10.0.0.1"},
+				"DHCPDNS":     "1.1.1.1",
+			},
+			want: map[string]string{"IP": "10.0.0.3", "GATEWAY": "10.0.0.1", "DNS": "1.1.1.1"},
+		},
+		{
+			name: "first set",
+			hardware: map[string]interface{}{
+				"PreferredIP": "10.0.0.2",
+				"DHCPIP":      "10.0.0.3",
+				"metadata":    map[string]interface{}{"gateway": "10.0.0.254", "dhcp_gateway": "10.0.0.1"},
+				"DNS":         "8.8.8.8",
+				"DHCPDNS":     "1.1.1.1",
+			},
+			want: map[string]string{"IP": "10.0.0.2", "GATEWAY": "10.0.0.254", "DNS": "8.8.8.8"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf, _, err := RenderTemplateHardware("coalesce", tmpl, tt.hardware)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, wf.Tasks[0].Actions[0].Environment); diff != "" {
+				t.Errorf("unexpected environment (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// Only the fields given to coalesce may be missing.
+	_, _, err := RenderTemplateHardware("coalesce", tmpl+"        DISK: {{ .Disk }}\n", map[string]interface{}{})
+	if err == nil || !strings.Contains(err.Error(), `map has no entry for key "Disk"`) {
+		t.Errorf("expected a missing key error, got %v", err)
+	}
+}
+
+func TestDig(t *testing.T) {
+	data := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"instance": map[string]interface{}{
+				"id":      "f4a7b39c",
+				"storage": nil,
+			},
+			"facility": "onprem",
+		},
+	}
+	tests := []struct {
+		name    string
+		args    []interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "present", args: []interface{}{"metadata", "instance", "id", "default", data}, want: "f4a7b39c"},
+		{name: "present map", args: []interface{}{"metadata", "instance", "default", data}, want: data["metadata"].(map[string]interface{})["instance"]},
+		{name: "partially present", args: []interface{}{"metadata", "instance", "hostname", "default", data}, want: "default"},
+		{name: "traverse a string", args: []interface{}{"metadata", "facility", "code", "default", data}, want: "default"},
+		{name: "null value", args: []interface{}{"metadata", "instance", "storage", "default", data}, want: "default"},
+		{name: "absent", args: []interface{}{"network", "interfaces", "default", data}, want: "default"},
+		{name: "nil data", args: []interface{}{"metadata", "default", nil}, want: "default"},
+		{name: "no key", args: []interface{}{"default", data}, wantErr: true},
+		{name: "key is not a string", args: []interface{}{"metadata", 1, "default", data}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dig(tt.args...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("unexpected value (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateDig(t *testing.T) {
+	tmpl := `
+version: "0.1"
+name: dig
+tasks:
+  - name: "metadata"
+    worker: "08:00:27:00:00:01"
+    actions:
+    - name: "configure"
+      image: configure
+      environment:
+        INSTANCE_ID: {{ dig "metadata" "instance" "id" "none" . }}
+        PLAN: {{ dig "metadata" "instance" "plan" "slug" "c3.small" . }}
+`
+	wf, _, err := RenderTemplateHardware("dig", tmpl, map[string]interface{}{
+		"metadata": map[string]interface{}{"instance": map[string]interface{}{"id": "f4a7b39c"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"INSTANCE_ID": "f4a7b39c", "PLAN": "c3.small"}
+	if diff := cmp.Diff(want, wf.Tasks[0].Actions[0].Environment); diff != "" {
+		t.Errorf("unexpected environment (-want +got):\n%s", diff)
+	}
+}
+
+func TestToBytes(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		{size: "512", want: 512},
+		{size: "512B", want: 512},
+		{size: "1KiB", want: 1024},
+		{size: "1.5KiB", want: 1536},
+		{size: "10MiB", want: 10 * 1024 * 1024},
+		{size: "10GiB", want: 10737418240},
+		{size: "1.5 GiB", want: 1610612736},
+		{size: "2TiB", want: 2 << 40},
+		{size: "1kB", want: 1000},
+		{size: "1KB", want: 1000},
+		{size: "2.5MB", want: 2500000},
+		{size: "10GB", want: 10000000000},
+		{size: "0.5gib", want: 512 * 1024 * 1024},
+		{size: "1.0001KiB", want: 1024},
+		{size: "8EiB", wantErr: true},
+		{size: "10GiBs", wantErr: true},
+		{size: "GiB", wantErr: true},
+		{size: "1.2.3MB", wantErr: true},
+		{size: "-1GiB", wantErr: true},
+		{size: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.size, func(t *testing.T) {
+			got, err := toBytes(tt.size)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHumanizeBytes(t *testing.T) {
+	tests := []struct {
+		size    interface{}
+		want    string
+		wantErr bool
+	}{
+		{size: 0, want: "0B"},
+		{size: 512, want: "512B"},
+		{size: int64(1024), want: "1KiB"},
+		{size: float64(1536), want: "1.5KiB"},
+		{size: 10 * 1024 * 1024, want: "10MiB"},
+		{size: float64(10737418240), want: "10GiB"},
+		{size: float64(500107862016), want: "465.76GiB"},
+		{size: "10GB", want: "9.31GiB"},
+		{size: -1, wantErr: true},
+		{size: "ten", wantErr: true},
+		{size: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.size), func(t *testing.T) {
+			got, err := humanizeBytes(tt.size)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateBytes(t *testing.T) {
+	tmpl := `
+version: "0.1"
+name: bytes
+tasks:
+  - name: "disk"
+    worker: "08:00:27:00:00:01"
+    actions:
+    - name: "partition"
+      image: partition
+      environment:
+        ROOT_SIZE: "{{ toBytes .root_size }}"
+        DISK_SIZE: {{ humanizeBytes .disk_size }}
+`
+	wf, _, err := RenderTemplateHardware("bytes", tmpl, map[string]interface{}{
+		"root_size": "20GiB",
+		"disk_size": float64(256060514304),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"ROOT_SIZE": "21474836480", "DISK_SIZE": "238.47GiB"}
+	if diff := cmp.Diff(want, wf.Tasks[0].Actions[0].Environment); diff != "" {
+		t.Errorf("unexpected environment (-want +got):\n%s", diff)
+	}
+
+	_, _, err = RenderTemplateHardware("bytes", tmpl, map[string]interface{}{
+		"root_size": "20 gigs",
+		"disk_size": float64(256060514304),
+	})
+	if err == nil || !strings.Contains(err.Error(), `invalid size "20 gigs"`) {
+		t.Errorf("expected an invalid size error, got %v", err)
+	}
+}
+
+func TestNormalizeMAC(t *testing.T) {
+	tests := []struct {
+		mac     string
+		want    string
+		wantErr bool
+	}{
+		{mac: "08:00:27:ab:cd:ef", want: "08:00:27:ab:cd:ef"},
+		{mac: "08:00:27:AB:CD:EF", want: "08:00:27:ab:cd:ef"},
+		{mac: "08-00-27-ab-cd-ef", want: "08:00:27:ab:cd:ef"},
+		{mac: "08-00-27-AB-CD-EF", want: "08:00:27:ab:cd:ef"},
+		{mac: "0800.27AB.CDEF", want: "08:00:27:ab:cd:ef"},
+		{mac: " 08:00:27:ab:cd:ef\n", want: "08:00:27:ab:cd:ef"},
+		{mac: "08:00:27:ab:cd", wantErr: true},
+		{mac: "08:00:27:ab:cd:eg", wantErr: true},
+		{mac: "080027ABCDEF", want: "08:00:27:ab:cd:ef"},
+		{mac: "080027abcdeg", wantErr: true},
+		{mac: "08:00:27:ab:cd:ef:01:02:03", wantErr: true},
+		{mac: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mac, func(t *testing.T) {
+			got, err := normalizeMAC(tt.mac)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateNormalizeMAC(t *testing.T) {
+	tmpl := `
+version: "0.1"
+name: mac
+tasks:
+  - name: "pxe"
+    worker: "{{ normalizeMAC .mac }}"
+    actions:
+    - name: "write-config"
+      image: write-config
+      environment:
+        CONFIG: /tftp/pxelinux.cfg/01-{{ normalizeMAC .mac | splitList ":" | join "-" }}
+`
+	wf, _, err := RenderTemplateHardware("mac", tmpl, map[string]interface{}{"mac": "08-00-27-AB-CD-EF"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := wf.Tasks[0].WorkerAddr; got != "08:00:27:ab:cd:ef" {
+		t.Errorf("expected worker 08:00:27:ab:cd:ef, got %s", got)
+	}
+	if got := wf.Tasks[0].Actions[0].Environment["CONFIG"]; got != "/tftp/pxelinux.cfg/01-08-00-27-ab-cd-ef" {
+		t.Errorf("unexpected config path %s", got)
+	}
+
+	_, _, err = RenderTemplateHardware("mac", tmpl, map[string]interface{}{"mac": "not-a-mac"})
+	if err == nil || !strings.Contains(err.Error(), `invalid MAC address "not-a-mac"`) {
+		t.Errorf("expected an invalid MAC address error, got %v", err)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		pattern string
+		want    bool
+		wantErr bool
+	}{
+		{name: "equal", value: "arm64", pattern: "arm64", want: true},
+		{name: "not equal", value: "x86_64", pattern: "arm64"},
+		{name: "no substring match", value: "arm64", pattern: "arm"},
+		{name: "number", value: 2, pattern: "2", want: true},
+		{name: "nil", value: nil, pattern: ""},
+		{name: "regexp", value: "aarch64", pattern: "/^(arm64|aarch64)$/", want: true},
+		{name: "regexp no match", value: "x86_64", pattern: "/^(arm64|aarch64)$/"},
+		{name: "single slash", value: "/", pattern: "/", want: true},
+		{name: "invalid regexp", value: "arm64", pattern: "/(/", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := matches(tt.value, tt.pattern)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %t", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %t, got %t", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHasLabel(t *testing.T) {
+	labels := map[string]interface{}{"role": "worker", "gpu": true, "empty": nil}
+	tests := []struct {
+		name    string
+		labels  interface{}
+		key     string
+		value   []string
+		want    bool
+		wantErr bool
+	}{
+		{name: "key", labels: labels, key: "gpu", want: true},
+		{name: "missing key", labels: labels, key: "zone"},
+		{name: "nil label", labels: labels, key: "empty"},
+		{name: "value", labels: labels, key: "role", value: []string{"worker"}, want: true},
+		{name: "other value", labels: labels, key: "role", value: []string{"storage"}},
+		{name: "regexp value", labels: labels, key: "role", value: []string{"/^(worker|storage)$/"}, want: true},
+		{name: "not a map", labels: "role", key: "role"},
+		{name: "too many values", labels: labels, key: "role", value: []string{"a", "b"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := hasLabel(tt.labels, tt.key, tt.value...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %t", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %t, got %t", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateMatches(t *testing.T) {
+	tmpl := `
+version: "0.1"
+name: arch
+tasks:
+  - name: "install"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "install"
+      image: {{ if matches .arch "/^(arm64|aarch64)$/" }}install-arm{{ else }}install{{ end }}
+      {{- if hasLabel .labels "role" "storage" }}
+      environment:
+        ROLE: storage
+      {{- end }}
+`
+	tests := []struct {
+		name      string
+		hardware  map[string]interface{}
+		wantImage string
+		wantRole  string
+	}{
+		{
+			name:      "match",
+			hardware:  map[string]interface{}{"device_1": "a", "arch": "aarch64", "labels": map[string]interface{}{"role": "storage"}},
+			wantImage: "install-arm",
+			wantRole:  "storage",
+		},
+		{
+			name:      "no match",
+			hardware:  map[string]interface{}{"device_1": "a", "arch": "x86_64", "labels": map[string]interface{}{}},
+			wantImage: "install",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf, _, err := RenderTemplateHardware("arch", tmpl, tt.hardware)
+			if err != nil {
+				t.Fatal(err)
+			}
+			action := wf.Tasks[0].Actions[0]
+			if action.Image != tt.wantImage {
+				t.Errorf("expected image %s, got %s", tt.wantImage, action.Image)
+			}
+			if got := action.Environment["ROLE"]; got != tt.wantRole {
+				t.Errorf("expected role %q, got %q", tt.wantRole, got)
+			}
+		})
+	}
+}
+
+func TestSystemdEscape(t *testing.T) {
+	// The expected values are the output of systemd-escape.
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "/dev/sda1", want: "-dev-sda1"},
+		{in: "Hallöchen, Meister", want: `Hall\xc3\xb6chen\x2c\x20Meister`},
+		{in: "foo-bar", want: `foo\x2dbar`},
+		{in: ".hidden.conf", want: `\x2ehidden.conf`},
+		{in: "host:port_1", want: "host:port_1"},
+		{in: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := systemdEscape(tt.in); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSystemdEscapePath(t *testing.T) {
+	// The expected values are the output of systemd-escape --path.
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "/dev/sda1", want: "dev-sda1"},
+		{in: "/mnt//data/", want: "mnt-data"},
+		{in: "/var/lib/./docker", want: "var-lib-docker"},
+		{in: "/dev/disk/by-label/root fs", want: `dev-disk-by\x2dlabel-root\x20fs`},
+		{in: "/", want: "-"},
+		{in: "/mnt/../etc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := systemdEscapePath(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateSystemdEscape(t *testing.T) {
+	tmpl := `
+version: "0.1"
+name: mount
+tasks:
+  - name: "mount"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "write-unit"
+      image: write-file
+      environment:
+        UNIT: '{{ systemdEscapePath .mount }}.mount'
+        INSTANCE: 'fsck@{{ systemdEscape .disk }}.service'
+`
+	wf, _, err := RenderTemplateHardware("mount", tmpl, map[string]interface{}{
+		"device_1": "08:00:27:00:00:01",
+		"mount":    "/var/lib/my-data",
+		"disk":     "/dev/sda1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := wf.Tasks[0].Actions[0].Environment
+	if got := env["UNIT"]; got != `var-lib-my\x2ddata.mount` {
+		t.Errorf("unexpected unit %s", got)
+	}
+	if got := env["INSTANCE"]; got != "fsck@-dev-sda1.service" {
+		t.Errorf("unexpected instance %s", got)
+	}
+}
+
+func TestHashFuncs(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  interface{}
+		sha256 string
+		sha1   string
+	}{
+		{
+			name:   "string",
+			value:  "abc",
+			sha256: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+			sha1:   "a9993e364706816aba3e25717850c26c9cd0d89d",
+		},
+		{
+			name:   "empty string",
+			value:  "",
+			sha256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			sha1:   "da39a3ee5e6b4b0d3255bfef95601890afd80709",
+		},
+		{
+			// The digests of {"a":1,"b":"x"}, whatever the order of the keys.
+			name:   "map",
+			value:  map[string]interface{}{"b": "x", "a": 1},
+			sha256: sha256Hex(`{"a":1,"b":"x"}`),
+			sha1:   sha1Hex(`{"a":1,"b":"x"}`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sha256sum(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.sha256 {
+				t.Errorf("expected sha256 %s, got %s", tt.sha256, got)
+			}
+			got, err = sha1sum(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.sha1 {
+				t.Errorf("expected sha1 %s, got %s", tt.sha1, got)
+			}
+		})
+	}
+
+	if _, err := sha256sum(func() {}); err == nil {
+		t.Error("expected an error for a value that cannot be encoded")
+	}
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s)) //nolint:gosec // test helper
+	return hex.EncodeToString(sum[:])
+}
+
+func TestRenderTemplateHash(t *testing.T) {
+	tmpl := `
+version: "0.1"
+name: hash
+tasks:
+  - name: "hash"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "hash"
+      image: hash
+      environment:
+        KEY: {{ sha256sum .device_1 }}
+        HARDWARE: {{ sha1sum . }}
+`
+	hardware := map[string]interface{}{"device_1": "abc"}
+	wf, _, err := RenderTemplateHardware("hash", tmpl, hardware)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := wf.Tasks[0].Actions[0].Environment
+	if got := env["KEY"]; got != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("unexpected key %s", got)
+	}
+	if got, want := env["HARDWARE"], sha1Hex(`{"device_1":"abc"}`); got != want {
+		t.Errorf("expected hardware hash %s, got %s", want, got)
+	}
+}
+
+func TestRenderTemplateTime(t *testing.T) {
+	tmpl := `
+version: "0.1"
+name: time
+tasks:
+  - name: "time"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "time"
+      image: time
+      environment:
+        BUILD_DATE: {{ now | dateFormat "2006-01-02" }}
+        BUILD_TIME: {{ now | dateFormat "20060102T150405Z" }}
+`
+	hardware := map[string]interface{}{"device_1": "08:00:27:00:00:01"}
+	now := time.Date(2022, 10, 16, 17, 4, 5, 0, time.FixedZone("CEST", 2*60*60))
+	wf, _, err := RenderTemplateHardware("time", tmpl, hardware, WithRenderTime(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := wf.Tasks[0].Actions[0].Environment
+	if got := env["BUILD_DATE"]; got != "2022-10-16" {
+		t.Errorf("unexpected build date %s", got)
+	}
+	if got := env["BUILD_TIME"]; got != "20221016T150405Z" {
+		t.Errorf("unexpected build time %s", got)
+	}
+
+	wf, _, err = RenderTemplateHardware("time", tmpl, hardware)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := wf.Tasks[0].Actions[0].Environment["BUILD_DATE"]; got == "2022-10-16" {
+		t.Errorf("expected the current date without WithRenderTime, got %s", got)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	root := t.TempDir()
+	base := filepath.Join(root, "base")
+	for file, data := range map[string]string{
+		filepath.Join(base, "ignition", "base.ign"): `{"ignition":{"version":"3.3.0"}}`,
+		filepath.Join(root, "secret"):               "secret",
+	} {
+		if err := os.MkdirAll(filepath.Dir(file), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "secret"), filepath.Join(base, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr string
+	}{
+		{name: "ignition/base.ign", want: `{"ignition":{"version":"3.3.0"}}`},
+		{name: "./ignition/base.ign", want: `{"ignition":{"version":"3.3.0"}}`},
+		{name: "../secret", wantErr: "cannot leave the base directory"},
+		{name: "ignition/../../secret", wantErr: "cannot leave the base directory"},
+		{name: "ignition/../ignition/base.ign", wantErr: "cannot leave the base directory"},
+		{name: "link", wantErr: "cannot leave the base directory"},
+		{name: filepath.Join(root, "secret"), wantErr: "needs a path relative to the base directory"},
+		{name: "", wantErr: "needs a path relative to the base directory"},
+		{name: "missing", wantErr: "no such file or directory"},
+	}
+	readFile := readFileIn(base)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := readFile(test.name)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("expected an error containing %q, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+
+	if _, err := readFileIn("")("ignition/base.ign"); err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Errorf("expected readFile to be disabled without a base directory, got %v", err)
+	}
+}
+
+func TestRenderTemplateReadFile(t *testing.T) {
+	base := t.TempDir()
+	if err := os.WriteFile(filepath.Join(base, "motd"), []byte("provisioned by tink"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tmpl := `
+version: "0.1"
+name: read-file
+tasks:
+  - name: "os-installation"
+    worker: "{{ .device_1 }}"
+    actions:
+    - name: "write-motd"
+      image: write-file
+      environment:
+        CONTENTS: {{ readFile "motd" | printf "%q" }}
+`
+	hardware := map[string]interface{}{"device_1": "08:00:27:00:00:01"}
+
+	wf, _, err := RenderTemplateHardware("read-file", tmpl, hardware, WithReadFileDir(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := wf.Tasks[0].Actions[0].Environment["CONTENTS"]; got != "provisioned by tink" {
+		t.Errorf("unexpected contents %q", got)
+	}
+
+	_, _, err = RenderTemplateHardware("read-file", tmpl, hardware)
+	if err == nil || !strings.Contains(err.Error(), "readFile is disabled") {
+		t.Errorf("expected readFile to be disabled by default, got %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/client/auth/challenge"
+	"github.com/pkg/errors"
+)
+
+// defaultImageCheckTimeout is the time spent checking the images of a
+// template when the Validator does not set one.
+const defaultImageCheckTimeout = 30 * time.Second
+
+// manifestMediaTypes are the manifests accepted when checking an image.
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+func (v Validator) imageCheckTimeout() time.Duration {
+	if v.ImageCheckTimeout > 0 {
+		return v.ImageCheckTimeout
+	}
+	return defaultImageCheckTimeout
+}
+
+// checkImages checks that the image of every action exists. Every image is
+// checked once.
+func (v Validator) checkImages(wf *Workflow) error {
+	ctx, cancel := context.WithTimeout(context.Background(), v.imageCheckTimeout())
+	defer cancel()
+	client := &http.Client{Transport: v.ImageCheckTransport}
+
+	checked := map[string]error{}
+	for _, task := range wf.Tasks {
+		for _, action := range task.Actions {
+			err, ok := checked[action.Image]
+			if !ok {
+				err = checkImageExists(ctx, client, v.ImageCheckCredentials, action.Image)
+				checked[action.Image] = err
+			}
+			if err != nil {
+				return errors.Wrapf(err, errActionImageUnavailable, action.Name, action.Image)
+			}
+		}
+	}
+	return nil
+}
+
+// checkImageExists asks the registry of the image for its manifest. The
+// images without a tag or a digest are looked up with the latest tag.
+func checkImageExists(ctx context.Context, client *http.Client, credentials func(string) (string, string), image string) error {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return err
+	}
+	named = reference.TagNameOnly(named)
+	ref := ""
+	if canonical, ok := named.(reference.Canonical); ok {
+		ref = canonical.Digest().String()
+	} else if tagged, ok := named.(reference.Tagged); ok {
+		ref = tagged.Tag()
+	}
+
+	domain, repository := reference.Domain(named), reference.Path(named)
+	host, scheme := domain, "https"
+	if domain == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+	if isLoopbackRegistry(domain) {
+		scheme = "http"
+	}
+	manifestURL := scheme + "://" + host + "/v2/" + repository + "/manifests/" + ref
+
+	resp, err := headManifest(ctx, client, manifestURL, "")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		var username, password string
+		if credentials != nil {
+			username, password = credentials(domain)
+		}
+		authorization, err := registryAuthorization(ctx, client, challenge.ResponseChallenges(resp), repository, username, password)
+		if err != nil {
+			return err
+		}
+		if resp, err = headManifest(ctx, client, manifestURL, authorization); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound:
+		return errors.New("image not found")
+	default:
+		return errors.Errorf("registry returned %s", resp.Status)
+	}
+}
+
+func headManifest(ctx context.Context, client *http.Client, manifestURL, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, mediaType := range manifestMediaTypes {
+		req.Header.Add("Accept", mediaType)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// registryAuthorization returns the Authorization header answering one of the
+// challenges of the registry: a token to pull the repository for a Bearer
+// challenge, the credentials for a Basic one.
+func registryAuthorization(ctx context.Context, client *http.Client, challenges []challenge.Challenge, repository, username, password string) (string, error) {
+	schemes := []string{}
+	for _, c := range challenges {
+		if strings.EqualFold(c.Scheme, "bearer") {
+			token, err := registryToken(ctx, client, c.Parameters, repository, username, password)
+			if err != nil {
+				return "", err
+			}
+			return "Bearer " + token, nil
+		}
+		schemes = append(schemes, c.Scheme)
+	}
+	for _, c := range challenges {
+		if strings.EqualFold(c.Scheme, "basic") {
+			if username == "" {
+				return "", errors.New("registry requires credentials for basic authentication")
+			}
+			req := &http.Request{Header: http.Header{}}
+			req.SetBasicAuth(username, password)
+			return req.Header.Get("Authorization"), nil
+		}
+	}
+	return "", errors.Errorf("registry requires unsupported authentication: %s", strings.Join(schemes, ", "))
+}
+
+// registryToken returns a token to pull the repository from the authorization
+// server of the Bearer challenge parameters. The token is anonymous unless
+// username is set.
+func registryToken(ctx context.Context, client *http.Client, params map[string]string, repository, username, password string) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", errors.Errorf("invalid authentication realm: %q", params["realm"])
+	}
+	query := realm.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	query.Set("scope", "repository:"+repository+":pull")
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("authorization server returned %s", resp.Status)
+	}
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", errors.Wrap(err, "decoding the authorization token")
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+// isLoopbackRegistry tells whether the registry runs on the local host, in
+// which case it is queried over plain HTTP.
+func isLoopbackRegistry(domain string) bool {
+	host := domain
+	if h, _, err := net.SplitHostPort(domain); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
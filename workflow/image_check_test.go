@@ -0,0 +1,145 @@
+package workflow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testRegistry serves the manifest of tinkerbell/disk-wipe:v1 to the clients
+// authenticated with an anonymous token, like Docker Hub does.
+func testRegistry(t *testing.T) (*httptest.Server, *int32) {
+	t.Helper()
+	var requests int32
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch {
+		case r.URL.Path == "/token":
+			if r.URL.Query().Get("scope") != "repository:tinkerbell/disk-wipe:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			_, _ = w.Write([]byte(`{"token":"secret"}`))
+		case r.Header.Get("Authorization") != "Bearer secret":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+srv.URL+`/token",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/v2/tinkerbell/slow/manifests/v1":
+			time.Sleep(200 * time.Millisecond)
+		case r.URL.Path == "/v2/tinkerbell/disk-wipe/manifests/v1":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+// withImage sets the image of every action of the workflow.
+func withImage(image string) workflowModifier {
+	return func(wf *Workflow) {
+		for i := range wf.Tasks {
+			for j := range wf.Tasks[i].Actions {
+				wf.Tasks[i].Actions[j].Image = image
+			}
+		}
+	}
+}
+
+func TestValidatorCheckImageExists(t *testing.T) {
+	srv, requests := testRegistry(t)
+	registry := strings.TrimPrefix(srv.URL, "http://")
+
+	t.Run("disabled by default", func(t *testing.T) {
+		atomic.StoreInt32(requests, 0)
+		assert.NoError(t, Validator{}.Validate(workflow(withImage(registry+"/tinkerbell/missing:v1"))))
+		assert.Zero(t, atomic.LoadInt32(requests))
+	})
+
+	t.Run("existing image", func(t *testing.T) {
+		v := Validator{CheckImageExists: true}
+		assert.NoError(t, v.Validate(workflow(withImage(registry+"/tinkerbell/disk-wipe:v1"))))
+	})
+
+	t.Run("missing tag", func(t *testing.T) {
+		v := Validator{CheckImageExists: true}
+		err := v.Validate(workflow(withImage(registry + "/tinkerbell/disk-wipe:v2")))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "image not found")
+		}
+	})
+
+	t.Run("unreachable registry", func(t *testing.T) {
+		v := Validator{CheckImageExists: true}
+		err := v.Validate(workflow(withImage("127.0.0.1:1/tinkerbell/disk-wipe:v1")))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "is not available")
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		v := Validator{CheckImageExists: true, ImageCheckTimeout: 50 * time.Millisecond}
+		start := time.Now()
+		assert.Error(t, v.Validate(workflow(withImage(registry+"/tinkerbell/slow:v1"))))
+		assert.Less(t, time.Since(start), 200*time.Millisecond)
+	})
+}
+
+// testBasicRegistry serves the manifest of tinkerbell/disk-wipe:v1 to the
+// clients authenticated as user:secret with a Basic challenge, like the
+// private registries commonly do.
+func testBasicRegistry(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		switch {
+		case !ok || username != "user" || password != "secret":
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/v2/tinkerbell/disk-wipe/manifests/v1":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestValidatorCheckImageExistsBasicAuth(t *testing.T) {
+	registry := strings.TrimPrefix(testBasicRegistry(t).URL, "http://")
+	credentials := func(host string) (string, string) {
+		if host != registry {
+			return "", ""
+		}
+		return "user", "secret"
+	}
+
+	t.Run("existing image", func(t *testing.T) {
+		v := Validator{CheckImageExists: true, ImageCheckCredentials: credentials}
+		assert.NoError(t, v.Validate(workflow(withImage(registry+"/tinkerbell/disk-wipe:v1"))))
+	})
+
+	t.Run("missing tag", func(t *testing.T) {
+		v := Validator{CheckImageExists: true, ImageCheckCredentials: credentials}
+		err := v.Validate(workflow(withImage(registry + "/tinkerbell/disk-wipe:v2")))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "image not found")
+		}
+	})
+
+	t.Run("without credentials", func(t *testing.T) {
+		v := Validator{CheckImageExists: true}
+		err := v.Validate(workflow(withImage(registry + "/tinkerbell/disk-wipe:v1")))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "registry requires credentials for basic authentication")
+			assert.NotContains(t, err.Error(), "image not found")
+		}
+	})
+}
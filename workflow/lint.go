@@ -0,0 +1,150 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"text/template"
+	"text/template/parse"
+)
+
+// LintKind identifies the category of a LintWarning.
+type LintKind string
+
+const (
+	// LintMissingKey is reported when the template references a hardware key
+	// that is not present in the hardware data. Rendering would fail.
+	LintMissingKey LintKind = "missing-key"
+	// LintUnusedKey is reported when the hardware data has a top-level key that
+	// the template never references.
+	LintUnusedKey LintKind = "unused-key"
+	// LintInvalidTemplate is reported when the template cannot be parsed.
+	LintInvalidTemplate LintKind = "invalid-template"
+)
+
+// LintWarning describes a problem found while linting a template against hardware data.
+type LintWarning struct {
+	Kind    LintKind
+	Key     string
+	Message string
+}
+
+func (w LintWarning) String() string {
+	return fmt.Sprintf("%s: %s", w.Kind, w.Message)
+}
+
+// Lint checks that the template and the hardware data are in sync. It reports
+// the keys referenced by the template but missing from the hardware data and the
+// hardware keys never referenced by the template. Only the top-level keys of the
+// hardware data are taken into account. A key with a template default is never
+// missing.
+func Lint(templateData string, hardware map[string]interface{}) []LintWarning {
+	referenced, err := referencedKeys(templateData)
+	if err != nil {
+		return []LintWarning{{Kind: LintInvalidTemplate, Message: err.Error()}}
+	}
+	defaults, err := templateDefaults(templateData)
+	if err != nil {
+		return []LintWarning{{Kind: LintInvalidTemplate, Message: err.Error()}}
+	}
+
+	available := withDefaults(hardware, defaults)
+	missing := []string{}
+	for key := range referenced {
+		if _, ok := available[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	unused := []string{}
+	for key := range hardware {
+		if _, ok := referenced[key]; !ok {
+			unused = append(unused, key)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(unused)
+
+	var warnings []LintWarning
+	for _, key := range missing {
+		warnings = append(warnings, LintWarning{
+			Kind:    LintMissingKey,
+			Key:     key,
+			Message: fmt.Sprintf("template references key %q which is not present in the hardware data", key),
+		})
+	}
+	for _, key := range unused {
+		warnings = append(warnings, LintWarning{
+			Kind:    LintUnusedKey,
+			Key:     key,
+			Message: fmt.Sprintf("hardware key %q is not used by the template", key),
+		})
+	}
+	return warnings
+}
+
+// referencedKeys returns the top-level keys of the template data (the root dot)
+// referenced by the template, like .device_1 or $.device_1.
+func referencedKeys(templateData string) (map[string]struct{}, error) {
+	t, err := template.New("workflow-template").Funcs(templateFuncs).Parse(templateData)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]struct{}{}
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			walkKeys(tmpl.Tree.Root, true, keys)
+		}
+	}
+	return keys, nil
+}
+
+// walkKeys collects the keys referenced from the root dot. When root is false
+// the dot has been rebound by range or with, so only $ references are collected.
+func walkKeys(node parse.Node, root bool, keys map[string]struct{}) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			walkKeys(c, root, keys)
+		}
+	case *parse.ActionNode:
+		walkKeys(n.Pipe, root, keys)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			walkKeys(c, root, keys)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkKeys(arg, root, keys)
+		}
+	case *parse.ChainNode:
+		walkKeys(n.Node, root, keys)
+	case *parse.FieldNode:
+		if root && len(n.Ident) > 0 {
+			keys[n.Ident[0]] = struct{}{}
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			keys[n.Ident[1]] = struct{}{}
+		}
+	case *parse.IfNode:
+		walkKeys(n.Pipe, root, keys)
+		walkKeys(n.List, root, keys)
+		walkKeys(n.ElseList, root, keys)
+	case *parse.RangeNode:
+		walkKeys(n.Pipe, root, keys)
+		walkKeys(n.List, false, keys)
+		walkKeys(n.ElseList, root, keys)
+	case *parse.WithNode:
+		walkKeys(n.Pipe, root, keys)
+		walkKeys(n.List, false, keys)
+		walkKeys(n.ElseList, root, keys)
+	case *parse.TemplateNode:
+		walkKeys(n.Pipe, root, keys)
+	}
+}
@@ -0,0 +1,89 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name         string
+		templateData string
+		hardware     map[string]interface{}
+		want         []LintWarning
+	}{
+		{
+			name:         "in sync",
+			templateData: validTemplate,
+			hardware:     map[string]interface{}{"device_1": "08:00:27:00:00:01"},
+		},
+		{
+			name:         "missing key",
+			templateData: validTemplate,
+			hardware:     map[string]interface{}{},
+			want: []LintWarning{
+				{Kind: LintMissingKey, Key: "device_1", Message: `template references key "device_1" which is not present in the hardware data`},
+			},
+		},
+		{
+			name:         "unused key",
+			templateData: validTemplate,
+			hardware: map[string]interface{}{
+				"device_1": "08:00:27:00:00:01",
+				"device_2": "08:00:27:00:00:02",
+			},
+			want: []LintWarning{
+				{Kind: LintUnusedKey, Key: "device_2", Message: `hardware key "device_2" is not used by the template`},
+			},
+		},
+		{
+			name: "range rebinds dot",
+			templateData: `
+version: "0.1"
+name: hello_world_workflow
+tasks:
+  - name: "hello world"
+    worker: "{{ $.device_1 }}"
+    actions:
+    {{- range .disks }}
+    - name: "wipe-{{ .name }}"
+      image: hello-world
+    {{- end }}
+`,
+			hardware: map[string]interface{}{
+				"device_1": "08:00:27:00:00:01",
+				"disks":    []interface{}{map[string]interface{}{"name": "sda"}},
+				"facility": "onprem",
+			},
+			want: []LintWarning{
+				{Kind: LintUnusedKey, Key: "facility", Message: `hardware key "facility" is not used by the template`},
+			},
+		},
+		{
+			name:         "missing and unused keys",
+			templateData: validTemplate,
+			hardware:     map[string]interface{}{"device_2": "08:00:27:00:00:02"},
+			want: []LintWarning{
+				{Kind: LintMissingKey, Key: "device_1", Message: `template references key "device_1" which is not present in the hardware data`},
+				{Kind: LintUnusedKey, Key: "device_2", Message: `hardware key "device_2" is not used by the template`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lint(tt.templateData, tt.hardware)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("unexpected warnings (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLintInvalidTemplate(t *testing.T) {
+	got := Lint(`{{ .device_1 }`, map[string]interface{}{})
+	if len(got) != 1 || got[0].Kind != LintInvalidTemplate {
+		t.Errorf("expected a single %q warning, got %v", LintInvalidTemplate, got)
+	}
+}
@@ -0,0 +1,101 @@
+package workflow
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// RenderActionByName renders the block of a single action of the workflow
+// template, with the same functions and defaults as RenderTemplateHardware,
+// and returns its YAML. The task and action are looked up by their name as
+// written in the template, before rendering, so the template has to be valid
+// YAML: the templates generating their actions with template actions, like
+// range, cannot be split into actions.
+func RenderActionByName(templateData string, taskName, actionName string, hardware map[string]interface{}) (string, error) {
+	var root yamlv3.Node
+	if err := yamlv3.Unmarshal([]byte(templateData), &root); err != nil {
+		return "", errors.Wrap(err, "the template cannot be split into actions")
+	}
+	action, err := findAction(&root, taskName, actionName)
+	if err != nil {
+		return "", err
+	}
+	actionData, err := yamlv3.Marshal(action)
+	if err != nil {
+		return "", errors.Wrapf(err, "action %s of task %s", actionName, taskName)
+	}
+
+	id := taskName + "/" + actionName
+	p, err := parseTemplate(id, string(actionData))
+	if err != nil {
+		return "", err
+	}
+	// The defaults are declared at the top of the whole template.
+	if p.defaults, err = templateDefaults(templateData); err != nil {
+		return "", errors.Wrapf(err, errTemplateParsing, id)
+	}
+	buf, err := p.execute(withDefaults(hardware, p.defaults), renderOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, errTemplateParsing, id)
+	}
+
+	var a Action
+	dec := yamlv3.NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.KnownFields(true)
+	if err := dec.Decode(&a); err != nil {
+		return "", errors.Wrap(err, "parsing yaml data")
+	}
+	return buf.String(), nil
+}
+
+// findAction returns the mapping node of the action of the task.
+func findAction(root *yamlv3.Node, taskName, actionName string) (*yamlv3.Node, error) {
+	doc := root
+	if doc.Kind == yamlv3.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	for _, task := range sequenceValue(doc, "tasks") {
+		if scalarValue(task, "name") != taskName {
+			continue
+		}
+		for _, action := range sequenceValue(task, "actions") {
+			if scalarValue(action, "name") == actionName {
+				return action, nil
+			}
+		}
+		return nil, errors.Errorf("action %s not found in task %s", actionName, taskName)
+	}
+	return nil, errors.Errorf("task %s not found", taskName)
+}
+
+// mappingValue returns the value of the key of the mapping node, nil when
+// the node is not a mapping or does not have the key.
+func mappingValue(node *yamlv3.Node, key string) *yamlv3.Node {
+	if node.Kind != yamlv3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func sequenceValue(node *yamlv3.Node, key string) []*yamlv3.Node {
+	value := mappingValue(node, key)
+	if value == nil || value.Kind != yamlv3.SequenceNode {
+		return nil
+	}
+	return value.Content
+}
+
+func scalarValue(node *yamlv3.Node, key string) string {
+	value := mappingValue(node, key)
+	if value == nil || value.Kind != yamlv3.ScalarNode {
+		return ""
+	}
+	return value.Value
+}
@@ -0,0 +1,54 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderActionByName(t *testing.T) {
+	templateData := `
+version: "0.1"
+name: provisioning
+global_timeout: 600
+defaults:
+  disk: /dev/sda
+tasks:
+  - name: "os-installation"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "disk-wipe"
+      image: disk-wipe
+      timeout: 90
+      environment:
+        DISK: "{{ .disk }}"
+    - name: "stream-image"
+      image: image2disk
+      timeout: 90
+      environment:
+        MAC: "{{ .device_1 | normalizeMAC }}"
+        IMG_URL: "{{ .img_url }}"
+`
+	hardware := map[string]interface{}{"device_1": "08-00-27-00-00-01"}
+
+	out, err := RenderActionByName(templateData, "os-installation", "disk-wipe", hardware)
+	assert.NoError(t, err)
+	assert.Contains(t, out, "name: \"disk-wipe\"")
+	assert.Contains(t, out, "DISK: \"/dev/sda\"")
+	assert.NotContains(t, out, "stream-image")
+
+	// The other actions are not rendered: the missing img_url key only
+	// breaks stream-image.
+	_, err = RenderActionByName(templateData, "os-installation", "stream-image", hardware)
+	assert.ErrorContains(t, err, `map has no entry for key "img_url"`)
+
+	hardware["img_url"] = "http://example.com/focal.raw.gz"
+	out, err = RenderActionByName(templateData, "os-installation", "stream-image", hardware)
+	assert.NoError(t, err)
+	assert.Contains(t, out, "MAC: \"08:00:27:00:00:01\"")
+
+	_, err = RenderActionByName(templateData, "os-installation", "kexec", hardware)
+	assert.EqualError(t, err, "action kexec not found in task os-installation")
+	_, err = RenderActionByName(templateData, "os-upgrade", "disk-wipe", hardware)
+	assert.EqualError(t, err, "task os-upgrade not found")
+}
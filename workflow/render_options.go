@@ -0,0 +1,156 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RenderOption configures the rendering of a workflow template.
+type RenderOption func(*renderOptions)
+
+type renderOptions struct {
+	timeout     time.Duration
+	maxSize     int
+	readFileDir string
+	now         time.Time
+	validator   Validator
+}
+
+// WithRenderTimeout aborts the execution of the template when it runs for
+// longer than timeout, to protect the caller from templates looping or
+// recursing heavily. A timeout lower than or equal to 0, the default, disables
+// the guard.
+//
+// The execution of a template cannot be interrupted: the render returns as
+// soon as the timeout expires, and the execution goes on in the background
+// until its next write to the output, which fails. An execution looping
+// without writing anything is not preempted, WithMaxRenderSize is the
+// deterministic bound of the templates.
+func WithRenderTimeout(timeout time.Duration) RenderOption {
+	return func(o *renderOptions) { o.timeout = timeout }
+}
+
+// WithMaxRenderSize aborts the execution of the template when its output
+// grows beyond size bytes. A size lower than or equal to 0, the default,
+// disables the guard.
+func WithMaxRenderSize(size int) RenderOption {
+	return func(o *renderOptions) { o.maxSize = size }
+}
+
+// WithReadFileDir enables the readFile function of the templates, which reads
+// the files of dir. The paths leading out of dir are rejected.
+func WithReadFileDir(dir string) RenderOption {
+	return func(o *renderOptions) { o.readFileDir = dir }
+}
+
+// WithRenderTime sets the time returned by the now function of the templates,
+// the current time by default, for the renders to be reproducible.
+func WithRenderTime(now time.Time) RenderOption {
+	return func(o *renderOptions) { o.now = now }
+}
+
+// WithValidator validates the rendered workflow against the requirements of
+// v instead of the default ones.
+func WithValidator(v Validator) RenderOption {
+	return func(o *renderOptions) { o.validator = v }
+}
+
+func newRenderOptions(opts []RenderOption) renderOptions {
+	o := renderOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// execute executes the template with data, within the timeout and the size
+// limit of the options. The output is only returned when the execution
+// succeeds.
+func (p *parsedTemplate) execute(data interface{}, o renderOptions) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	if err := p.executeTo(buf, data, o); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// executeTo executes the template with data into w, within the timeout and
+// the size limit of the options. Nothing is written to w once executeTo has
+// returned, even when the execution goes on in the background after a
+// timeout.
+func (p *parsedTemplate) executeTo(w io.Writer, data interface{}, o renderOptions) error {
+	t := p.t
+	funcs := map[string]interface{}{}
+	if o.readFileDir != "" {
+		funcs["readFile"] = readFileIn(o.readFileDir)
+	}
+	if !o.now.IsZero() {
+		now := o.now
+		funcs["now"] = func() time.Time { return now }
+	}
+	if o.validator.FailOnEmptyValue {
+		funcs["nonEmpty"] = nonEmpty
+	}
+	if len(funcs) > 0 {
+		// The parsed template is shared, the functions bound to a render
+		// are set on a copy.
+		clone, err := t.Clone()
+		if err != nil {
+			return err
+		}
+		if o.validator.FailOnEmptyValue {
+			rewriteNonEmpty(clone)
+		}
+		t = clone.Funcs(funcs)
+	}
+
+	if o.timeout <= 0 {
+		return t.Execute(&renderWriter{w: w, ctx: context.Background(), max: o.maxSize}, data)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+	rw := &renderWriter{w: w, ctx: ctx, max: o.maxSize}
+	done := make(chan error, 1)
+	go func() {
+		done <- t.Execute(rw, data)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// Wait for the write in progress, the next ones fail.
+		rw.mu.Lock()
+		defer rw.mu.Unlock()
+		return errors.New(errTemplateTimeout)
+	}
+}
+
+// renderWriter fails the writes once its context is done, and the ones
+// writing more than max bytes in total when max is greater than 0.
+type renderWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	ctx context.Context
+	max int
+	n   int
+}
+
+func (rw *renderWriter) Write(p []byte) (int, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if err := rw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if rw.max > 0 && rw.n+len(p) > rw.max {
+		return 0, errors.Errorf(errTemplateTooLarge, rw.max)
+	}
+	n, err := rw.w.Write(p)
+	rw.n += n
+	return n, err
+}
@@ -0,0 +1,271 @@
+package workflow
+
+import (
+	"reflect"
+	"strconv"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/pkg/errors"
+)
+
+// required returns value, or fails the rendering with msg when value is
+// missing or empty.
+//
+// Examples
+//
+//	required "disk is mandatory" .Disk -> the value of .Disk
+//	.Disk | required "disk is mandatory" -> the value of .Disk
+func required(msg string, value interface{}) (interface{}, error) {
+	if isEmptyValue(value) {
+		return nil, errors.New(msg)
+	}
+	return value, nil
+}
+
+// requiredKey is what the required calls are rewritten to, so that a missing
+// key fails with the message of the template author instead of the generic
+// missing key error.
+func requiredKey(msg string, data interface{}, keys ...string) (interface{}, error) {
+	value, ok := lookupKey(data, keys...)
+	if !ok {
+		return nil, errors.New(msg)
+	}
+	return required(msg, value)
+}
+
+// optionalKey is what the fields given to coalesce are rewritten to, so that
+// a missing key is an empty value instead of an error.
+func optionalKey(data interface{}, keys ...string) interface{} {
+	value, _ := lookupKey(data, keys...)
+	return value
+}
+
+// lookupKey returns the value of the nested keys of the data, and whether it
+// is found.
+func lookupKey(data interface{}, keys ...string) (interface{}, bool) {
+	value := data
+	for _, key := range keys {
+		v := reflect.ValueOf(value)
+		if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		item := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+		if !item.IsValid() {
+			return nil, false
+		}
+		value = item.Interface()
+	}
+	return value, true
+}
+
+func isEmptyValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	s, ok := value.(string)
+	return ok && s == ""
+}
+
+// rewriteRequired replaces the calls to required whose value is a field of the
+// data, like {{ required "msg" .Disk }} or {{ .Disk | required "msg" }}, with
+// calls to requiredKey. Missing keys are an error when executing the workflow
+// templates, which would happen before required gets a chance to run.
+//
+// The fields given to coalesce, like {{ coalesce .PreferredIP .DHCPIP }} or
+// {{ .PreferredIP | coalesce .DHCPIP }}, are replaced with calls to
+// optionalKey for the same reason.
+func rewriteRequired(t *template.Template) {
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			rewriteRequiredNode(tmpl.Tree.Root)
+		}
+	}
+}
+
+func rewriteRequiredNode(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			rewriteRequiredNode(c)
+		}
+	case *parse.ActionNode:
+		rewriteRequiredNode(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			rewriteRequiredNode(c)
+		}
+		// {{ .Disk | required "msg" }}
+		if len(n.Cmds) > 1 && len(n.Cmds[0].Args) == 1 && isRequiredCall(n.Cmds[1], 2) {
+			if cmd := requiredKeyCall(n.Cmds[1].Args[1], n.Cmds[0].Args[0]); cmd != nil {
+				n.Cmds = append([]*parse.CommandNode{cmd}, n.Cmds[2:]...)
+			}
+		}
+		// {{ .PreferredIP | coalesce .DHCPIP }}
+		if len(n.Cmds) > 1 && len(n.Cmds[0].Args) == 1 && isCoalesceCall(n.Cmds[1]) {
+			if cmd := optionalKeyCall(n.Cmds[0].Args[0]); cmd != nil {
+				n.Cmds[0] = cmd
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			rewriteRequiredNode(arg)
+		}
+		// {{ required "msg" .Disk }}
+		if isRequiredCall(n, 3) {
+			if cmd := requiredKeyCall(n.Args[1], n.Args[2]); cmd != nil {
+				n.Args = cmd.Args
+			}
+		}
+		// {{ coalesce .PreferredIP .DHCPIP "0.0.0.0" }}
+		if isCoalesceCall(n) {
+			for i, arg := range n.Args[1:] {
+				if cmd := optionalKeyCall(arg); cmd != nil {
+					n.Args[i+1] = &parse.PipeNode{NodeType: parse.NodePipe, Pos: arg.Position(), Cmds: []*parse.CommandNode{cmd}}
+				}
+			}
+		}
+	case *parse.IfNode:
+		rewriteRequiredNode(n.Pipe)
+		rewriteRequiredNode(n.List)
+		rewriteRequiredNode(n.ElseList)
+	case *parse.RangeNode:
+		rewriteRequiredNode(n.Pipe)
+		rewriteRequiredNode(n.List)
+		rewriteRequiredNode(n.ElseList)
+	case *parse.WithNode:
+		rewriteRequiredNode(n.Pipe)
+		rewriteRequiredNode(n.List)
+		rewriteRequiredNode(n.ElseList)
+	case *parse.TemplateNode:
+		rewriteRequiredNode(n.Pipe)
+	}
+}
+
+func isRequiredCall(cmd *parse.CommandNode, args int) bool {
+	if len(cmd.Args) != args {
+		return false
+	}
+	ident, ok := cmd.Args[0].(*parse.IdentifierNode)
+	return ok && ident.Ident == "required"
+}
+
+func isCoalesceCall(cmd *parse.CommandNode) bool {
+	if len(cmd.Args) == 0 {
+		return false
+	}
+	ident, ok := cmd.Args[0].(*parse.IdentifierNode)
+	return ok && ident.Ident == "coalesce"
+}
+
+// requiredKeyCall returns the requiredKey call looking up the field, or nil
+// when the value is not a field of the dot or of a variable.
+func requiredKeyCall(msg, value parse.Node) *parse.CommandNode {
+	return keyCall("requiredKey", value, msg)
+}
+
+// optionalKeyCall returns the optionalKey call looking up the field, or nil
+// when the value is not a field of the dot or of a variable.
+func optionalKeyCall(value parse.Node) *parse.CommandNode {
+	return keyCall("optionalKey", value)
+}
+
+// keyCall returns the call to fn with the args, followed by the data and the
+// keys of the field, or nil when the value is not a field of the dot or of a
+// variable.
+func keyCall(fn string, value parse.Node, args ...parse.Node) *parse.CommandNode {
+	var data parse.Node
+	var keys []string
+	switch v := value.(type) {
+	case *parse.FieldNode:
+		data = &parse.DotNode{NodeType: parse.NodeDot, Pos: v.Pos}
+		keys = v.Ident
+	case *parse.VariableNode:
+		if len(v.Ident) < 2 {
+			return nil
+		}
+		data = &parse.VariableNode{NodeType: parse.NodeVariable, Pos: v.Pos, Ident: v.Ident[:1]}
+		keys = v.Ident[1:]
+	default:
+		return nil
+	}
+
+	args = append([]parse.Node{parse.NewIdentifier(fn).SetPos(value.Position())}, args...)
+	args = append(args, data)
+	for _, key := range keys {
+		args = append(args, &parse.StringNode{NodeType: parse.NodeString, Pos: value.Position(), Quoted: strconv.Quote(key), Text: key})
+	}
+	return &parse.CommandNode{NodeType: parse.NodeCommand, Pos: value.Position(), Args: args}
+}
+
+// nonEmpty is appended to the pipelines printing a field of the data when the
+// Validator sets FailOnEmptyValue. It returns value, or fails the rendering
+// when value is missing or empty.
+func nonEmpty(field string, value interface{}) (interface{}, error) {
+	if isEmptyValue(value) {
+		return nil, errors.Errorf(errEmptyValue, field)
+	}
+	return value, nil
+}
+
+// rewriteNonEmpty appends a nonEmpty call to the pipelines of t printing a
+// field of the data, like {{ .Disk }} or {{ $hw.Disk | lower }}. The parse
+// trees are shared by the clones of a template, so t gets copies of them.
+func rewriteNonEmpty(t *template.Template) {
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			tmpl.Tree = tmpl.Tree.Copy()
+			rewriteNonEmptyNode(tmpl.Tree.Root)
+		}
+	}
+}
+
+func rewriteNonEmptyNode(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			rewriteNonEmptyNode(c)
+		}
+	case *parse.ActionNode:
+		// The pipelines declaring a variable print nothing.
+		if len(n.Pipe.Decl) > 0 || len(n.Pipe.Cmds) == 0 || len(n.Pipe.Cmds[0].Args) != 1 {
+			return
+		}
+		field := n.Pipe.Cmds[0].Args[0]
+		switch v := field.(type) {
+		case *parse.FieldNode:
+		case *parse.VariableNode:
+			if len(v.Ident) < 2 {
+				return
+			}
+		default:
+			return
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args: []parse.Node{
+				parse.NewIdentifier("nonEmpty").SetPos(n.Pos),
+				&parse.StringNode{NodeType: parse.NodeString, Pos: n.Pos, Quoted: strconv.Quote(field.String()), Text: field.String()},
+			},
+		})
+	case *parse.IfNode:
+		rewriteNonEmptyNode(n.List)
+		rewriteNonEmptyNode(n.ElseList)
+	case *parse.RangeNode:
+		rewriteNonEmptyNode(n.List)
+		rewriteNonEmptyNode(n.ElseList)
+	case *parse.WithNode:
+		rewriteNonEmptyNode(n.List)
+		rewriteNonEmptyNode(n.ElseList)
+	}
+}
@@ -4,42 +4,249 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"text/template"
+	"text/template/parse"
+	"time"
 
 	"github.com/docker/distribution/reference"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+var (
+	// envKeyRegexp matches the environment variable names accepted in templates.
+	envKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// userRegexp matches the user and group names accepted by an action user.
+	userRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+	// idRegexp matches the numeric user and group IDs accepted by an action user.
+	idRegexp = regexp.MustCompile(`^[0-9]+$`)
+	// networkNameRegexp matches the custom network names accepted by an action network mode.
+	networkNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+	// dnsLabelRegexp matches the DNS-1123 labels required by DNSSafeActionNames.
+	dnsLabelRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
 )
 
 const (
 	errEmptyName              = "name cannot be empty"
-	errInvalidLength          = "name cannot have more than 200 characters: %s"
+	errInvalidLength          = "name cannot have more than %d characters: %s"
+	errTemplateEmptyVersion   = "template version is required"
 	errTemplateInvalidVersion = "invalid template version: %s"
 	errTaskDuplicateName      = "two tasks in a template cannot have same name: %s"
+	errTaskEmptyWorker        = "task %s has no worker defined"
+	errTaskNoActions          = "task %s has no actions"
 	errActionDuplicateName    = "two actions in a task cannot have same name: %s"
+	errActionDuplicateGlobal  = "duplicate action name across tasks: %s"
+	errActionNameNotDNSSafe   = "action name not DNS-safe: %s"
 	errActionInvalidImage     = "invalid action image: %s"
+	errActionDisallowedVolume = "action %s mounts disallowed host path: %s"
+	errActionDisallowedReg    = "action %s uses disallowed registry: %s"
+	errActionImageUnavailable = "action %s image %s is not available"
+	errActionLatestTag        = "action %s uses forbidden :latest tag"
+	errInvalidEnvironmentKey  = "invalid environment variable name: %s"
+	errActionInvalidUser      = "action %s has an invalid user: %s"
+	errActionInvalidWorkDir   = "action %s working directory must be an absolute path: %s"
+	errActionInvalidNetwork   = "action %s has invalid network mode: %s"
+	errTaskDuplicateWorker    = "tasks %s and %s target the same worker: %s"
+	errEmptyValue             = "%s renders to an empty value"
+	errUndefinedPartial       = "template references undefined partial: %s"
+	errActionUnknownCap       = "action %s requests unknown capability: %s"
+	errActionIgnoreFailure    = "action %s sets ignoreFailure, which requires template version " + TemplateVersion02 + ", got %s"
+	errActionFiles            = "action %s sets files, which requires template version " + TemplateVersion02 + ", got %s"
+	errActionFileInvalidPath  = "action %s file path must be an absolute path: %q"
+	errActionFileDuplicate    = "action %s writes the file %s twice"
+	errReservedDefault        = "default %s collides with a reserved key"
 	errTemplateParsing        = "failed to parse template with ID %s"
+	errTemplateTimeout        = "template execution timed out"
+	errTemplateTooLarge       = "template output exceeds %d bytes"
+	errStreamedEnvironment    = "template environment set by template actions cannot be merged into the streamed actions"
 	errInvalidHardwareAddress = "failed to render template, invalid hardware address: %v"
+
+	warnActionNoTimeout   = "action %s has no timeout and can run forever, consider setting one"
+	warnTaskDuplicateName = "two tasks in a template have the same name: %s, their progress cannot be told apart"
+	warnDefaultShadowed   = "default %s is shadowed by the hardware data key of the same name and is never used"
 )
 
+// Validator validates workflow templates. The zero value applies the default
+// requirements, every additional field makes the validation stricter.
+type Validator struct {
+	// AllowedHostPaths is the list of host path prefixes that actions are
+	// allowed to mount. An empty list permits every host path.
+	AllowedHostPaths []string
+
+	// DNSSafeActionNames requires the action names to be DNS-1123 labels, for
+	// the runtimes deriving container names from them.
+	DNSSafeActionNames bool
+
+	// AllowDuplicateTaskNames downgrades duplicate task names to a warning, for
+	// legacy templates relying on them. The workflow state and events identify
+	// tasks by name, so the progress of tasks sharing a name cannot be told
+	// apart. Duplicate action names within a task remain an error.
+	AllowDuplicateTaskNames bool
+
+	// GlobalUniqueActionNames requires the action names to be unique across
+	// all the tasks of a template rather than within each task, for the
+	// engines keying the metrics or logs of the actions by their name.
+	GlobalUniqueActionNames bool
+
+	// AllowedRegistries is the list of registry hosts, like docker.io or
+	// quay.io:443, the action images are allowed to come from. Images without
+	// a registry come from docker.io. An empty list permits every registry.
+	AllowedRegistries []string
+
+	// ForbidLatestTag rejects the action images using the latest tag,
+	// explicitly or because they have neither a tag nor a digest. The other
+	// tags are allowed.
+	ForbidLatestTag bool
+
+	// MaxNameLength is the maximum length of the template, task and action
+	// names, defaultMaxNameLength when zero.
+	MaxNameLength int
+
+	// HardwareKeys is the list of the top-level keys of the hardware data the
+	// template is rendered with. ValidateWithWarnings reports the defaults
+	// shadowed by one of them: the hardware data wins, the default is never
+	// used.
+	HardwareKeys []string
+
+	// DisallowDuplicateWorkers rejects the workflows whose tasks render to the
+	// same worker address, for the templates whose tasks are meant to run on
+	// distinct machines. The workers are only known once the template is
+	// rendered, so it is checked by the renders using WithValidator only.
+	DisallowDuplicateWorkers bool
+
+	// FailOnEmptyValue fails the renders printing a field of the data, like
+	// {{ .disk }}, which is present but empty, for the hardware data missing
+	// a value to be caught rather than rendered blank. The value printed by
+	// the pipeline is checked, so {{ .disk | printf "disk=%s" }} never
+	// fails. It is checked by the renders using WithValidator only.
+	FailOnEmptyValue bool
+
+	// CheckImageExists checks with their registry that the action images
+	// exist, once every other requirement is met. The registries are queried
+	// with ImageCheckCredentials, for ImageCheckTimeout at most.
+	CheckImageExists bool
+
+	// ImageCheckTimeout bounds the time spent checking the images of a
+	// template, defaultImageCheckTimeout when zero.
+	ImageCheckTimeout time.Duration
+
+	// ImageCheckCredentials returns the username and the password used with
+	// the registry, like quay.io or docker.io, when it asks for them. The
+	// registries are queried anonymously when nil or when it returns an empty
+	// username.
+	ImageCheckCredentials func(registry string) (username, password string)
+
+	// ImageCheckTransport is the transport used to query the registries,
+	// http.DefaultTransport when nil.
+	ImageCheckTransport http.RoundTripper
+}
+
+// defaultMaxNameLength is the maximum length of the names when the Validator
+// does not set one. The names of 200 characters have always been rejected.
+const defaultMaxNameLength = 199
+
 // Parse parses the template yaml content into a Workflow.
 func Parse(yamlContent []byte) (*Workflow, error) {
-	var workflow Workflow
+	return Validator{}.Parse(yamlContent)
+}
 
-	err := yaml.UnmarshalStrict(yamlContent, &workflow)
+// Parse parses the template yaml content into a Workflow and validates it
+// against the requirements of the Validator.
+func (v Validator) Parse(yamlContent []byte) (*Workflow, error) {
+	workflow, err := unmarshal(yamlContent)
 	if err != nil {
-		return &Workflow{}, errors.Wrap(err, "parsing yaml data")
+		return &Workflow{}, err
 	}
 
-	if err = validate(&workflow); err != nil {
+	if err = v.Validate(workflow); err != nil {
 		return &Workflow{}, errors.Wrap(err, "validating workflow template")
 	}
 
+	return workflow, nil
+}
+
+// unmarshal decodes the template yaml content without validating it. Unknown
+// fields and duplicate keys are rejected. Anchors, aliases and merge keys are
+// supported, so that the actions can share their common fields.
+func unmarshal(yamlContent []byte) (*Workflow, error) {
+	if err := checkYAML(bytes.NewReader(yamlContent)); err != nil {
+		return nil, err
+	}
+	return decodeWorkflow(bytes.NewReader(yamlContent))
+}
+
+// checkYAML parses the yaml content read from r and reports the duplicate
+// keys.
+func checkYAML(r io.Reader) error {
+	var root yamlv3.Node
+	err := yamlv3.NewDecoder(r).Decode(&root)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return errors.Wrap(err, "parsing yaml data")
+	}
+	if err := checkDuplicateKeys(&root); err != nil {
+		return errors.Wrap(err, "parsing yaml data")
+	}
+	return nil
+}
+
+// decodeWorkflow decodes the yaml content read from r into a Workflow,
+// rejecting the unknown fields.
+func decodeWorkflow(r io.Reader) (*Workflow, error) {
+	var workflow Workflow
+	// yaml.v2 strict mode reports the keys of a map merged and then
+	// overridden as duplicates, yaml.v3 does not.
+	dec := yamlv3.NewDecoder(r)
+	dec.KnownFields(true)
+	err := dec.Decode(&workflow)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.Wrap(err, "parsing yaml data")
+	}
 	return &workflow, nil
 }
 
+// checkDuplicateKeys returns an error for the first key defined twice in a
+// mapping, which would silently override the first definition. The keys
+// merged from an alias can be overridden.
+func checkDuplicateKeys(node *yamlv3.Node) error {
+	if node.Kind == yamlv3.MappingNode {
+		keys := map[string]struct{}{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Kind != yamlv3.ScalarNode || key.Tag == "!!merge" {
+				continue
+			}
+			if _, ok := keys[key.Value]; ok {
+				return errors.Errorf("duplicate key %q at line %d", key.Value, key.Line)
+			}
+			keys[key.Value] = struct{}{}
+		}
+	}
+	for _, child := range node.Content {
+		if err := checkDuplicateKeys(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Marshal returns the template YAML of the workflow. The YAML of a valid
+// workflow parses back to the same workflow.
+func (wf *Workflow) Marshal() ([]byte, error) {
+	out, err := yaml.Marshal(wf)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling workflow template")
+	}
+	return out, nil
+}
+
 // MustParse parse a slice of bytes to a template. It an error occurs the
 // function triggers a panic. Common utility for testing purpose.
 func MustParse(yamlContent []byte) *Workflow {
@@ -76,45 +283,359 @@ func RenderTemplate(templateID, templateData string, devices []byte) (string, er
 	return buf.String(), nil
 }
 
+// RenderTemplatePretty renders the workflow template like RenderTemplate, but
+// returns the rendered workflow in a canonical, consistently indented YAML
+// instead of the interpolated template.
+func RenderTemplatePretty(templateID, templateData string, devices []byte) (string, error) {
+	var hardware map[string]interface{}
+	err := json.Unmarshal(devices, &hardware)
+	if err != nil {
+		err = errors.Wrapf(err, errTemplateParsing, templateID)
+		return "", err
+	}
+
+	wf, _, err := RenderTemplateHardware(templateID, templateData, hardware)
+	if err != nil {
+		return "", err
+	}
+	out, err := yaml.Marshal(wf)
+	if err != nil {
+		return "", errors.Wrapf(err, errTemplateParsing, templateID)
+	}
+	return string(out), nil
+}
+
 // RenderTemplateHardware renders the workflow template and returns the Workflow and the interpolated bytes.
-func RenderTemplateHardware(templateID, templateData string, hardware map[string]interface{}) (*Workflow, *bytes.Buffer, error) {
+func RenderTemplateHardware(templateID, templateData string, hardware map[string]interface{}, opts ...RenderOption) (*Workflow, *bytes.Buffer, error) {
+	p, err := parseTemplate(templateID, templateData)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.render(templateID, hardware, opts...)
+}
+
+// ValidateAgainstHardware checks that the template is ready to run on the
+// hardware: it parses, renders with the hardware data without missing keys,
+// and the rendered workflow is valid, with a worker address for every task.
+// The error tells which of the stages failed, prefixed by parsing template,
+// rendering template or validating rendered workflow.
+func ValidateAgainstHardware(templateData string, hardware map[string]interface{}) error {
+	p, err := parseTemplate("", templateData)
+	if err != nil {
+		return errors.Wrap(errors.Cause(err), "parsing template")
+	}
+	buf, err := p.execute(withDefaults(hardware, p.defaults), renderOptions{})
+	if err != nil {
+		return errors.Wrap(err, "rendering template")
+	}
+	wf, err := unmarshal(buf.Bytes())
+	if err != nil {
+		return errors.Wrap(err, "validating rendered workflow")
+	}
+	if err := validateRendered(wf, hardware, Validator{}); err != nil {
+		return errors.Wrap(errors.Cause(err), "validating rendered workflow")
+	}
+	return nil
+}
+
+// varsKey is the key of the template data holding the variables of RenderTemplateWithVars.
+const varsKey = "Vars"
+
+// RenderTemplateWithVars renders the workflow template like RenderTemplate,
+// with the operator supplied variables available under .Vars, apart from the
+// hardware data, like {{ .Vars.ReleaseTag }}. The hardware data cannot have a
+// Vars key.
+func RenderTemplateWithVars(templateID, templateData string, hardware map[string]interface{}, vars map[string]interface{}) (string, error) {
+	if _, ok := hardware[varsKey]; ok {
+		return "", errors.Errorf("hardware data key %s collides with the template variables", varsKey)
+	}
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+	data := make(map[string]interface{}, len(hardware)+1)
+	for k, v := range hardware {
+		data[k] = v
+	}
+	data[varsKey] = vars
+
+	_, buf, err := RenderTemplateHardware(templateID, templateData, data)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// parsedTemplate is a workflow template ready to be rendered. It is safe for
+// concurrent use.
+type parsedTemplate struct {
+	t        *template.Template
+	defaults map[string]interface{}
+}
+
+func parseTemplate(templateID, templateData string) (*parsedTemplate, error) {
 	t := template.New("workflow-template").
 		Option("missingkey=error").
 		Funcs(templateFuncs)
 	_, err := t.Parse(templateData)
 	if err != nil {
 		err = errors.Wrapf(err, errTemplateParsing, templateID)
-		return nil, nil, err
+		return nil, err
+	}
+	if name, ok := undefinedPartial(t); ok {
+		return nil, errors.Wrapf(errors.Errorf(errUndefinedPartial, name), errTemplateParsing, templateID)
+	}
+	t.Funcs(map[string]interface{}{"requiredKey": requiredKey, "optionalKey": optionalKey})
+	rewriteRequired(t)
+
+	defaults, err := templateDefaults(templateData)
+	if err != nil {
+		return nil, errors.Wrapf(err, errTemplateParsing, templateID)
+	}
+	return &parsedTemplate{t: t, defaults: defaults}, nil
+}
+
+// undefinedPartial returns the name of the first partial, the templates
+// declared with {{ define "name" }}, invoked with {{ template "name" }} but
+// never declared. Executing the template would fail only when the invocation
+// is reached.
+func undefinedPartial(t *template.Template) (string, bool) {
+	var names []string
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			names = append(names, partialNames(tmpl.Tree.Root)...)
+		}
+	}
+	for _, name := range names {
+		if partial := t.Lookup(name); partial == nil || partial.Tree == nil {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// partialNames returns the names of the templates invoked by the node.
+func partialNames(node parse.Node) []string {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		var names []string
+		for _, c := range n.Nodes {
+			names = append(names, partialNames(c)...)
+		}
+		return names
+	case *parse.IfNode:
+		return append(partialNames(n.List), partialNames(n.ElseList)...)
+	case *parse.RangeNode:
+		return append(partialNames(n.List), partialNames(n.ElseList)...)
+	case *parse.WithNode:
+		return append(partialNames(n.List), partialNames(n.ElseList)...)
+	case *parse.TemplateNode:
+		return []string{n.Name}
 	}
+	return nil
+}
 
-	buf := new(bytes.Buffer)
-	if err = t.Execute(buf, hardware); err != nil {
+func (p *parsedTemplate) render(templateID string, hardware map[string]interface{}, opts ...RenderOption) (*Workflow, *bytes.Buffer, error) {
+	o := newRenderOptions(opts)
+	buf, err := p.execute(withDefaults(hardware, p.defaults), o)
+	if err != nil {
 		err = errors.Wrapf(err, errTemplateParsing, templateID)
 		return nil, nil, err
 	}
 
-	wf, err := Parse(buf.Bytes())
+	wf, err := unmarshal(buf.Bytes())
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := validateRendered(wf, hardware, o.validator); err != nil {
+		return nil, nil, err
+	}
+
+	if len(wf.Environment) > 0 {
+		mergeEnvironment(wf)
+		out, err := yaml.Marshal(wf)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, errTemplateParsing, templateID)
+		}
+		buf = bytes.NewBuffer(out)
+	}
+	return wf, buf, nil
+}
+
+// validateRendered validates the workflow rendered from the hardware data
+// against the requirements of v.
+func validateRendered(wf *Workflow, hardware map[string]interface{}, v Validator) error {
+	// An empty worker at this point comes from the hardware data, so it is
+	// reported as such before validating the rendered template.
 	for _, task := range wf.Tasks {
 		if task.WorkerAddr == "" {
-			return nil, nil, fmt.Errorf(errInvalidHardwareAddress, hardware)
+			return fmt.Errorf(errInvalidHardwareAddress, hardware)
 		}
 	}
-	return wf, buf, nil
+	if err := v.Validate(wf); err != nil {
+		return errors.Wrap(err, "validating workflow template")
+	}
+	if v.DisallowDuplicateWorkers {
+		workers := map[string]string{}
+		for _, task := range wf.Tasks {
+			if other, ok := workers[task.WorkerAddr]; ok {
+				return errors.Errorf(errTaskDuplicateWorker, other, task.Name, task.WorkerAddr)
+			}
+			workers[task.WorkerAddr] = task.Name
+		}
+	}
+	return nil
+}
+
+// RenderTemplateTo renders the workflow template like RenderTemplateHardware,
+// but executes it into w instead of buffering its output. The output is
+// decoded and validated the same way while it is written, so w may have
+// received a part or the whole of an invalid workflow when an error is
+// returned.
+//
+// The environment of a template is merged into its actions once the whole
+// workflow is known: the templates defining a top-level environment, and the
+// ones which are not valid YAML before rendering, are buffered.
+func RenderTemplateTo(w io.Writer, templateID, templateData string, hardware map[string]interface{}, opts ...RenderOption) error {
+	p, err := parseTemplate(templateID, templateData)
+	if err != nil {
+		return err
+	}
+	if doc, _ := parseMasked(templateData); doc == nil || hasTopLevelKey(doc, "environment") {
+		_, buf, err := p.render(templateID, hardware, opts...)
+		if err != nil {
+			return err
+		}
+		_, err = buf.WriteTo(w)
+		return err
+	}
+	return p.renderTo(w, templateID, hardware, opts...)
+}
+
+func (p *parsedTemplate) renderTo(w io.Writer, templateID string, hardware map[string]interface{}, opts ...RenderOption) error {
+	// The output is checked and decoded as it is written, like unmarshal
+	// does with the whole output.
+	checkR, checkW := io.Pipe()
+	checked := make(chan error, 1)
+	go func() {
+		err := checkYAML(checkR)
+		// The template keeps writing after a parsing error.
+		_, _ = io.Copy(io.Discard, checkR)
+		checked <- err
+	}()
+	decodeR, decodeW := io.Pipe()
+	type result struct {
+		wf  *Workflow
+		err error
+	}
+	decoded := make(chan result, 1)
+	go func() {
+		wf, err := decodeWorkflow(decodeR)
+		_, _ = io.Copy(io.Discard, decodeR)
+		decoded <- result{wf: wf, err: err}
+	}()
+
+	o := newRenderOptions(opts)
+	err := p.executeTo(io.MultiWriter(w, checkW, decodeW), withDefaults(hardware, p.defaults), o)
+	checkW.CloseWithError(err)
+	decodeW.CloseWithError(err)
+	checkErr, res := <-checked, <-decoded
+	if err != nil {
+		return errors.Wrapf(err, errTemplateParsing, templateID)
+	}
+	if checkErr != nil {
+		return checkErr
+	}
+	if res.err != nil {
+		return res.err
+	}
+	if len(res.wf.Environment) > 0 {
+		return errors.New(errStreamedEnvironment)
+	}
+	return validateRendered(res.wf, hardware, o.validator)
 }
 
-// validate validates a workflow template against certain requirements.
+// hasTopLevelKey tells whether the mapping defines the key.
+func hasTopLevelKey(doc *yamlv3.Node, key string) bool {
+	k, _ := topLevelKey(doc, key)
+	return k != nil
+}
+
+// mergeEnvironment copies the template environment into every action. The
+// task and action environments take precedence over the template one.
+func mergeEnvironment(wf *Workflow) {
+	for i, task := range wf.Tasks {
+		for j, action := range task.Actions {
+			for k, v := range wf.Environment {
+				if _, ok := task.Environment[k]; ok {
+					continue
+				}
+				if _, ok := action.Environment[k]; ok {
+					continue
+				}
+				if action.Environment == nil {
+					action.Environment = map[string]string{}
+				}
+				action.Environment[k] = v
+			}
+			wf.Tasks[i].Actions[j].Environment = action.Environment
+		}
+	}
+}
+
+// validate validates a workflow template against the default requirements.
 func validate(wf *Workflow) error {
+	return Validator{}.Validate(wf)
+}
+
+// ValidateWithWarnings validates a workflow template against the default
+// requirements and also reports the practices that are valid but discouraged.
+func ValidateWithWarnings(wf *Workflow) (errs []error, warnings []string) {
+	return Validator{}.ValidateWithWarnings(wf)
+}
+
+// ValidateWithWarnings validates a workflow template against the requirements
+// of the Validator. The errors make the template invalid, the warnings are only
+// informational.
+func (v Validator) ValidateWithWarnings(wf *Workflow) (errs []error, warnings []string) {
+	if err := v.Validate(wf); err != nil {
+		errs = append(errs, err)
+	}
+	taskNames := map[string]struct{}{}
+	for _, task := range wf.Tasks {
+		if _, ok := taskNames[task.Name]; ok && v.AllowDuplicateTaskNames {
+			warnings = append(warnings, fmt.Sprintf(warnTaskDuplicateName, task.Name))
+		}
+		taskNames[task.Name] = struct{}{}
+		for _, action := range task.Actions {
+			if action.Timeout == 0 {
+				warnings = append(warnings, fmt.Sprintf(warnActionNoTimeout, action.Name))
+			}
+		}
+	}
+	for _, key := range v.HardwareKeys {
+		if _, ok := wf.Defaults[key]; ok {
+			warnings = append(warnings, fmt.Sprintf(warnDefaultShadowed, key))
+		}
+	}
+	return errs, warnings
+}
+
+// Validate validates a workflow template against the requirements of the Validator.
+func (v Validator) Validate(wf *Workflow) error {
 	if hasEmptyName(wf.Name) {
 		return errors.New(errEmptyName)
 	}
-	if !hasValidLength(wf.Name) {
-		return errors.Errorf(errInvalidLength, wf.Name)
+	if !v.hasValidLength(wf.Name) {
+		return errors.Errorf(errInvalidLength, v.maxNameLength(), wf.Name)
 	}
 
-	if wf.Version != "0.1" {
+	if wf.Version == "" {
+		return errors.New(errTemplateEmptyVersion)
+	}
+	if wf.Version != TemplateVersion01 && wf.Version != TemplateVersion02 {
 		return errors.Errorf(errTemplateInvalidVersion, wf.Version)
 	}
 
@@ -122,54 +643,311 @@ func validate(wf *Workflow) error {
 		return errors.New("template must have at least one task defined")
 	}
 
+	if err := validateEnvironment(wf.Environment); err != nil {
+		return err
+	}
+
+	if err := validateDefaults(wf.Defaults); err != nil {
+		return err
+	}
+
 	taskNameMap := make(map[string]struct{})
+	globalActionNameMap := make(map[string]struct{})
 	for _, task := range wf.Tasks {
 		if hasEmptyName(task.Name) {
 			return errors.New(errEmptyName)
 		}
-		if !hasValidLength(task.Name) {
-			return errors.Errorf(errInvalidLength, task.Name)
+		if !v.hasValidLength(task.Name) {
+			return errors.Errorf(errInvalidLength, v.maxNameLength(), task.Name)
 		}
 
 		_, ok := taskNameMap[task.Name]
-		if ok {
+		if ok && !v.AllowDuplicateTaskNames {
 			return errors.Errorf(errTaskDuplicateName, task.Name)
 		}
 
+		if task.WorkerAddr == "" {
+			return errors.Errorf(errTaskEmptyWorker, task.Name)
+		}
+
+		if len(task.Actions) == 0 {
+			return errors.Errorf(errTaskNoActions, task.Name)
+		}
+
 		taskNameMap[task.Name] = struct{}{}
+		if err := validateEnvironment(task.Environment); err != nil {
+			return err
+		}
 		actionNameMap := make(map[string]struct{})
 		for _, action := range task.Actions {
 			if hasEmptyName(action.Name) {
 				return errors.New(errEmptyName)
 			}
 
-			if !hasValidLength(action.Name) {
-				return errors.Errorf(errInvalidLength, action.Name)
+			if !v.hasValidLength(action.Name) {
+				return errors.Errorf(errInvalidLength, v.maxNameLength(), action.Name)
+			}
+
+			if v.DNSSafeActionNames && !dnsLabelRegexp.MatchString(action.Name) {
+				return errors.Errorf(errActionNameNotDNSSafe, action.Name)
 			}
 
 			if !hasValidImageName(action.Image) {
 				return errors.Errorf(errActionInvalidImage, action.Image)
 			}
 
+			if registry, ok := v.disallowedRegistry(action.Image); ok {
+				return errors.Errorf(errActionDisallowedReg, action.Name, registry)
+			}
+
+			if v.ForbidLatestTag && usesLatestTag(action.Image) {
+				return errors.Errorf(errActionLatestTag, action.Name)
+			}
+
 			_, ok := actionNameMap[action.Name]
 			if ok {
 				return errors.Errorf(errActionDuplicateName, action.Name)
 			}
 			actionNameMap[action.Name] = struct{}{}
+
+			if _, ok := globalActionNameMap[action.Name]; ok && v.GlobalUniqueActionNames {
+				return errors.Errorf(errActionDuplicateGlobal, action.Name)
+			}
+			globalActionNameMap[action.Name] = struct{}{}
+
+			if err := validateEnvironment(action.Environment); err != nil {
+				return err
+			}
+
+			if action.User != "" && !hasValidUser(action.User) {
+				return errors.Errorf(errActionInvalidUser, action.Name, action.User)
+			}
+
+			if action.WorkingDir != "" && !path.IsAbs(action.WorkingDir) {
+				return errors.Errorf(errActionInvalidWorkDir, action.Name, action.WorkingDir)
+			}
+
+			if action.Network != "" && !hasValidNetworkMode(action.Network) {
+				return errors.Errorf(errActionInvalidNetwork, action.Name, action.Network)
+			}
+
+			for _, capability := range action.Capabilities {
+				if _, ok := KnownCapabilities[capability]; !ok {
+					return errors.Errorf(errActionUnknownCap, action.Name, capability)
+				}
+			}
+
+			if action.IgnoreFailure && wf.Version == TemplateVersion01 {
+				return errors.Errorf(errActionIgnoreFailure, action.Name, wf.Version)
+			}
+
+			if err := validateFiles(action, wf.Version); err != nil {
+				return err
+			}
+
+			// Task volumes are mounted in every action of the task.
+			for _, volume := range append(append([]string{}, task.Volumes...), action.Volumes...) {
+				if hostPath, ok := v.disallowedHostPath(volume); ok {
+					return errors.Errorf(errActionDisallowedVolume, action.Name, hostPath)
+				}
+			}
+		}
+	}
+
+	if v.CheckImageExists {
+		return v.checkImages(wf)
+	}
+	return nil
+}
+
+// disallowedHostPath returns the host path of the volume and true when the
+// volume mounts a host path outside of AllowedHostPaths. Named volumes and
+// volumes without a host path are always allowed.
+func (v Validator) disallowedHostPath(volume string) (string, bool) {
+	if len(v.AllowedHostPaths) == 0 {
+		return "", false
+	}
+	parts := strings.Split(volume, ":")
+	if len(parts) < 2 || !path.IsAbs(parts[0]) {
+		return "", false
+	}
+	hostPath := path.Clean(parts[0])
+	for _, allowed := range v.AllowedHostPaths {
+		allowed = path.Clean(allowed)
+		if hostPath == allowed || allowed == "/" || strings.HasPrefix(hostPath, allowed+"/") {
+			return "", false
+		}
+	}
+	return hostPath, true
+}
+
+// disallowedRegistry returns the registry of the image and true when it is
+// not one of AllowedRegistries.
+func (v Validator) disallowedRegistry(image string) (string, bool) {
+	if len(v.AllowedRegistries) == 0 {
+		return "", false
+	}
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", false
+	}
+	registry := reference.Domain(named)
+	for _, allowed := range v.AllowedRegistries {
+		if strings.EqualFold(registry, allowed) {
+			return "", false
+		}
+	}
+	return registry, true
+}
+
+// validateFiles checks that the files of the action have distinct absolute
+// paths, under a template version supporting them.
+func validateFiles(action Action, version string) error {
+	if len(action.Files) == 0 {
+		return nil
+	}
+	if version == TemplateVersion01 {
+		return errors.Errorf(errActionFiles, action.Name, version)
+	}
+	paths := map[string]struct{}{}
+	for _, file := range action.Files {
+		if !path.IsAbs(file.Path) {
+			return errors.Errorf(errActionFileInvalidPath, action.Name, file.Path)
+		}
+		p := path.Clean(file.Path)
+		if _, ok := paths[p]; ok {
+			return errors.Errorf(errActionFileDuplicate, action.Name, p)
+		}
+		paths[p] = struct{}{}
+	}
+	return nil
+}
+
+// usesLatestTag tells whether the image has the latest tag, or has neither a
+// tag nor a digest and so defaults to it.
+func usesLatestTag(image string) bool {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return false
+	}
+	if tagged, ok := named.(reference.Tagged); ok {
+		return tagged.Tag() == "latest"
+	}
+	_, digested := named.(reference.Digested)
+	return !digested
+}
+
+func validateEnvironment(env map[string]string) error {
+	for k := range env {
+		if !envKeyRegexp.MatchString(k) {
+			return errors.Errorf(errInvalidEnvironmentKey, k)
 		}
 	}
 	return nil
 }
 
+// hasValidUser reports whether user is a user, optionally followed by a group
+// as in user:group, each given by name or by numeric ID.
+func hasValidUser(user string) bool {
+	parts := strings.Split(user, ":")
+	if len(parts) > 2 {
+		return false
+	}
+	for _, p := range parts {
+		if !idRegexp.MatchString(p) && !userRegexp.MatchString(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasValidNetworkMode reports whether mode is one of the network modes, the
+// network of another container as in container:name, or the name of a custom
+// network. Custom names too close to a network mode are rejected as typos.
+func hasValidNetworkMode(mode string) bool {
+	for _, m := range networkModes {
+		if mode == m {
+			return true
+		}
+	}
+	if name := strings.TrimPrefix(mode, "container:"); name != mode {
+		return networkNameRegexp.MatchString(name)
+	}
+	if !networkNameRegexp.MatchString(mode) {
+		return false
+	}
+	for _, m := range networkModes {
+		if editDistance(strings.ToLower(mode), m) <= 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
 func hasEmptyName(name string) bool {
 	return name == ""
 }
 
-func hasValidLength(name string) bool {
-	return len(name) < 200
+func (v Validator) hasValidLength(name string) bool {
+	return len(name) <= v.maxNameLength()
+}
+
+func (v Validator) maxNameLength() int {
+	if v.MaxNameLength > 0 {
+		return v.MaxNameLength
+	}
+	return defaultMaxNameLength
 }
 
+// hasValidImageName checks that the image is a valid reference to a
+// repository. A bare registry host, like quay.io or localhost:5000, is a valid
+// reference to a Docker Hub repository, but is most likely a mistake and is
+// rejected.
 func hasValidImageName(name string) bool {
-	_, err := reference.ParseNormalizedNamed(name)
-	return err == nil
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil || reference.Path(named) == "" {
+		return false
+	}
+	return !isRegistryHost(name)
+}
+
+// isRegistryHost tells whether the image, which has a single path component,
+// looks like a registry host.
+func isRegistryHost(name string) bool {
+	if strings.ContainsAny(name, "/@") {
+		return false
+	}
+	host, _, _ := strings.Cut(name, ":")
+	return host == "localhost" || strings.Contains(host, ".")
 }
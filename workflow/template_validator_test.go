@@ -1,9 +1,13 @@
 package workflow
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/assert"
@@ -147,6 +151,275 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParseAnchors(t *testing.T) {
+	content := `
+version: "0.1"
+name: anchors
+global_timeout: 1800
+tasks:
+  - name: "os-installation"
+    worker: "08:00:27:00:00:01"
+    actions:
+      - &wipe
+        name: "disk-wipe"
+        image: disk-wipe
+        timeout: 90
+        volumes:
+          - /dev:/dev
+        environment: &env
+          MIRROR_HOST: 192.168.1.2
+      - <<: *wipe
+        name: "disk-wipe-nvme"
+        environment:
+          <<: *env
+          DEST_DISK: /dev/nvme0n1
+`
+	wf, err := Parse([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := wf.Tasks[0].Actions
+	assert.Len(t, actions, 2)
+	assert.Equal(t, "disk-wipe-nvme", actions[1].Name)
+	assert.Equal(t, actions[0].Image, actions[1].Image)
+	assert.Equal(t, actions[0].Timeout, actions[1].Timeout)
+	assert.Equal(t, actions[0].Volumes, actions[1].Volumes)
+	assert.Equal(t, map[string]string{"MIRROR_HOST": "192.168.1.2"}, actions[0].Environment)
+	assert.Equal(t, map[string]string{"MIRROR_HOST": "192.168.1.2", "DEST_DISK": "/dev/nvme0n1"}, actions[1].Environment)
+
+	_, err = Parse([]byte(strings.Replace(content, "timeout: 90", "timeout: 90\n        unknown: true", 1)))
+	assert.Error(t, err, "expected unknown fields to be rejected")
+
+	_, err = Parse([]byte(strings.Replace(content, "timeout: 90", "timeout: 90\n        timeout: 60", 1)))
+	assert.Error(t, err, "expected duplicate keys to be rejected")
+}
+
+func TestParseDuplicateKeys(t *testing.T) {
+	content := `
+version: "0.1"
+name: duplicate
+global_timeout: 1800
+tasks:
+  - name: "os-installation"
+    worker: "08:00:27:00:00:01"
+    actions:
+      - name: "disk-wipe"
+        image: disk-wipe
+        timeout: 90
+        image: disk-wipe-v2
+`
+	_, err := Parse([]byte(content))
+	if err == nil || !strings.Contains(err.Error(), `duplicate key "image" at line 12`) {
+		t.Errorf("expected a duplicate key error, got %v", err)
+	}
+}
+
+func TestParseIgnoreFailure(t *testing.T) {
+	content := `
+version: "%s"
+name: cleanup
+global_timeout: 1800
+tasks:
+  - name: "os-installation"
+    worker: "08:00:27:00:00:01"
+    actions:
+      - name: "disk-wipe"
+        image: disk-wipe
+        timeout: 90
+      - name: "cleanup"
+        image: cleanup
+        timeout: 90
+        ignoreFailure: %s
+`
+	testCases := []struct {
+		name          string
+		version       string
+		value         string
+		want          bool
+		expectedError string
+	}{
+		{name: "version 0.2", version: "0.2", value: "true", want: true},
+		{name: "version 0.2 false", version: "0.2", value: "false"},
+		{name: "version 0.1", version: "0.1", value: "true", expectedError: "action cleanup sets ignoreFailure, which requires template version 0.2, got 0.1"},
+		{name: "version 0.1 false", version: "0.1", value: "false"},
+		{name: "not a bool", version: "0.2", value: `"true"`, expectedError: "cannot unmarshal !!str `true` into bool"},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			wf, err := Parse([]byte(fmt.Sprintf(content, test.version, test.value)))
+			if test.expectedError != "" {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.False(t, wf.Tasks[0].Actions[0].IgnoreFailure)
+			assert.Equal(t, test.want, wf.Tasks[0].Actions[1].IgnoreFailure)
+		})
+	}
+}
+
+func TestParseFiles(t *testing.T) {
+	content := `
+version: "%s"
+name: config
+global_timeout: 1800
+tasks:
+  - name: "os-installation"
+    worker: "08:00:27:00:00:01"
+    actions:
+      - name: "write-config"
+        image: writer
+        timeout: 90
+        files:
+%s`
+	testCases := []struct {
+		name          string
+		version       string
+		files         string
+		want          []File
+		expectedError string
+	}{
+		{
+			name:    "version 0.2",
+			version: "0.2",
+			files: `          - path: /etc/motd
+            content: "hello"
+          - path: /etc/netplan/01-static.yaml
+            content: |
+              network:
+                version: 2
+`,
+			want: []File{
+				{Path: "/etc/motd", Content: "hello"},
+				{Path: "/etc/netplan/01-static.yaml", Content: "network:\n  version: 2\n"},
+			},
+		},
+		{name: "empty content", version: "0.2", files: "          - path: /etc/cloud/cloud-init.disabled\n", want: []File{{Path: "/etc/cloud/cloud-init.disabled"}}},
+		{
+			name:          "version 0.1",
+			version:       "0.1",
+			files:         "          - path: /etc/motd\n            content: hello\n",
+			expectedError: "action write-config sets files, which requires template version 0.2, got 0.1",
+		},
+		{
+			name:          "relative path",
+			version:       "0.2",
+			files:         "          - path: etc/motd\n            content: hello\n",
+			expectedError: `action write-config file path must be an absolute path: "etc/motd"`,
+		},
+		{
+			name:          "empty path",
+			version:       "0.2",
+			files:         "          - content: hello\n",
+			expectedError: `action write-config file path must be an absolute path: ""`,
+		},
+		{
+			name:          "duplicate path",
+			version:       "0.2",
+			files:         "          - path: /etc/motd\n          - path: /etc//motd\n",
+			expectedError: "action write-config writes the file /etc/motd twice",
+		},
+		{
+			name:          "content not a string",
+			version:       "0.2",
+			files:         "          - path: /etc/motd\n            content: {motd: hello}\n",
+			expectedError: "cannot unmarshal !!map into string",
+		},
+		{
+			name:          "unknown field",
+			version:       "0.2",
+			files:         "          - path: /etc/motd\n            mode: 0644\n",
+			expectedError: "field mode not found in type workflow.File",
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			wf, err := Parse([]byte(fmt.Sprintf(content, test.version, test.files)))
+			if test.expectedError != "" {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, wf.Tasks[0].Actions[0].Files)
+		})
+	}
+}
+
+func TestParseVersionRequired(t *testing.T) {
+	content := `
+name: no-version
+global_timeout: 1800
+tasks:
+  - name: "os-installation"
+    worker: "08:00:27:00:00:01"
+    actions:
+      - name: "disk-wipe"
+        image: disk-wipe
+        timeout: 90
+`
+	_, err := Parse([]byte(content))
+	assert.EqualError(t, err, "validating workflow template: template version is required")
+
+	_, err = Parse([]byte("version: \"\"\n" + content))
+	assert.EqualError(t, err, "validating workflow template: template version is required")
+
+	_, err = Parse([]byte("version: \"0.3\"\n" + content))
+	assert.EqualError(t, err, "validating workflow template: invalid template version: 0.3")
+}
+
+func TestWorkflowMarshal(t *testing.T) {
+	content := `
+version: "0.2"
+name: marshal
+id: ce2e62ed-826f-4485-a39f-a82bb74338e2
+global_timeout: 1800
+environment:
+  MIRROR: 192.168.1.2
+tasks:
+  - name: "os-installation"
+    worker: "08:00:27:00:00:01"
+    volumes:
+      - /dev:/dev
+    environment:
+      DISK: /dev/sda
+    actions:
+      - name: "disk-wipe"
+        image: quay.io/tinkerbell/disk-wipe:v1
+        timeout: 90
+        command: ["wipe", "--all"]
+        on-timeout: ["echo", "timeout"]
+        on-failure: ["echo", "failure"]
+        volumes:
+          - /statedir:/statedir
+        environment:
+          FORCE: "true"
+        pid: host
+        user: "1000:1000"
+        workingDir: /statedir
+        network: host
+        capabilities: [CAP_SYS_ADMIN]
+        ignoreFailure: true
+      - name: "install"
+        image: install
+        timeout: 600
+`
+	wf, err := Parse([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := wf.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Parse(out)
+	if err != nil {
+		t.Fatalf("the marshaled template does not parse: %v\n%s", err, out)
+	}
+	if diff := cmp.Diff(wf, got); diff != "" {
+		t.Error(diff)
+	}
+}
+
 func TestValidateTemplate(t *testing.T) {
 	testCases := []struct {
 		name          string
@@ -193,6 +466,46 @@ func TestValidateTemplate(t *testing.T) {
 			wf:            workflow(withTaskDuplicateName()),
 			expectedError: true,
 		},
+		{
+			name:          "task worker is empty",
+			wf:            workflow(withTaskEmptyWorker()),
+			expectedError: true,
+		},
+		{
+			name:          "action network mode is invalid",
+			wf:            workflow(withActionInvalidNetwork()),
+			expectedError: true,
+		},
+		{
+			name:          "action capability is unknown",
+			wf:            workflow(withActionUnknownCapability()),
+			expectedError: true,
+		},
+		{
+			name:          "task has no actions",
+			wf:            workflow(withTaskNoActions()),
+			expectedError: true,
+		},
+		{
+			name:          "template environment key is invalid",
+			wf:            workflow(withTemplateInvalidEnvironment()),
+			expectedError: true,
+		},
+		{
+			name:          "action environment key is invalid",
+			wf:            workflow(withActionInvalidEnvironment()),
+			expectedError: true,
+		},
+		{
+			name:          "action user is invalid",
+			wf:            workflow(withActionInvalidUser()),
+			expectedError: true,
+		},
+		{
+			name:          "action working directory is relative",
+			wf:            workflow(withActionRelativeWorkingDir()),
+			expectedError: true,
+		},
 		{
 			name:          "action name is invalid",
 			wf:            workflow(withActionInvalidName()),
@@ -230,6 +543,376 @@ func TestValidateTemplate(t *testing.T) {
 	}
 }
 
+func TestValidateTaskNoActions(t *testing.T) {
+	err := validate(workflow(withTaskNoActions()))
+	assert.EqualError(t, err, "task post-installation has no actions")
+}
+
+func TestHasValidNetworkMode(t *testing.T) {
+	tests := []struct {
+		mode  string
+		valid bool
+	}{
+		{mode: "host", valid: true},
+		{mode: "none", valid: true},
+		{mode: "bridge", valid: true},
+		{mode: "provisioning", valid: true},
+		{mode: "tink_net.1", valid: true},
+		{mode: "container:dhcp", valid: true},
+		{mode: "hostt", valid: false},
+		{mode: "Host", valid: false},
+		{mode: "brige", valid: false},
+		{mode: "nne", valid: false},
+		{mode: "container:", valid: false},
+		{mode: "my network", valid: false},
+		{mode: "-net", valid: false},
+	}
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			assert.Equal(t, test.valid, hasValidNetworkMode(test.mode))
+		})
+	}
+}
+
+func TestHasValidImageName(t *testing.T) {
+	tests := []struct {
+		image string
+		valid bool
+	}{
+		{image: "ubuntu", valid: true},
+		{image: "ubuntu:20.04", valid: true},
+		{image: "quay.io/tinkerbell-actions/image2disk:v1.0.0", valid: true},
+		{image: "localhost:5000/disk-wipe", valid: true},
+		{image: "localhost/disk-wipe:latest", valid: true},
+		{image: "ubuntu@sha256:b5a61709a9a44284d88fb12e5c48db0409cfad5b69d4ff8224077c57302df9cf", valid: true},
+		{image: "action-image-with-$#@-", valid: false},
+		{image: "localhost:5000/", valid: false},
+		{image: "quay.io/", valid: false},
+		{image: "quay.io", valid: false},
+		{image: "quay.io:443", valid: false},
+		{image: "localhost", valid: false},
+		{image: "localhost:5000", valid: false},
+		{image: "", valid: false},
+	}
+	for _, test := range tests {
+		t.Run(test.image, func(t *testing.T) {
+			assert.Equal(t, test.valid, hasValidImageName(test.image))
+		})
+	}
+}
+
+func TestValidatorMaxNameLength(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxLength     int
+		length        int
+		expectedError bool
+	}{
+		{name: "below default limit", length: 199},
+		{name: "default limit", length: 200, expectedError: true},
+		{name: "above default limit", length: 201, expectedError: true},
+		{name: "custom limit", maxLength: 63, length: 63},
+		{name: "above custom limit", maxLength: 63, length: 64, expectedError: true},
+		{name: "custom limit above default", maxLength: 255, length: 255},
+		{name: "custom limit of 200", maxLength: 200, length: 200},
+		{name: "above custom limit of 200", maxLength: 200, length: 201, expectedError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name := strings.Repeat("a", test.length)
+			v := Validator{MaxNameLength: test.maxLength}
+			for _, wf := range []*Workflow{
+				workflow(func(wf *Workflow) { wf.Name = name }),
+				workflow(func(wf *Workflow) { wf.Tasks[0].Name = name }),
+				workflow(func(wf *Workflow) { wf.Tasks[0].Actions[0].Name = name }),
+			} {
+				err := v.Validate(wf)
+				if !test.expectedError {
+					assert.NoError(t, err)
+					continue
+				}
+				limit := test.maxLength
+				if limit == 0 {
+					limit = 199
+				}
+				assert.EqualError(t, err, fmt.Sprintf("name cannot have more than %d characters: %s", limit, name))
+			}
+		})
+	}
+}
+
+func TestValidatorAllowedHostPaths(t *testing.T) {
+	tests := []struct {
+		name          string
+		allowed       []string
+		wf            *Workflow
+		expectedError string
+	}{
+		{
+			name: "empty allowlist permits everything",
+			wf:   workflow(),
+		},
+		{
+			name:    "every host path is allowed",
+			allowed: []string{"/dev", "/lib/firmware", "/statedir"},
+			wf:      workflow(),
+		},
+		{
+			name:          "task volume is not allowed",
+			allowed:       []string{"/dev", "/statedir"},
+			wf:            workflow(),
+			expectedError: "action disk-wipe mounts disallowed host path: /lib/firmware",
+		},
+		{
+			name:          "action volume is not allowed",
+			allowed:       []string{"/dev", "/lib/firmware"},
+			wf:            workflow(),
+			expectedError: "action disk-partition mounts disallowed host path: /statedir",
+		},
+		{
+			name:    "prefix must match a whole path element",
+			allowed: []string{"/dev", "/lib/firmware", "/state"},
+			wf: workflow(func(wf *Workflow) {
+				wf.Tasks[0].Actions[1].Volumes = []string{"/state/../etc:/etc"}
+			}),
+			expectedError: "action disk-partition mounts disallowed host path: /etc",
+		},
+		{
+			name:    "named volumes are allowed",
+			allowed: []string{"/dev", "/lib/firmware"},
+			wf: workflow(func(wf *Workflow) {
+				wf.Tasks[0].Actions[1].Volumes = []string{"statedir:/statedir"}
+				wf.Tasks[0].Actions[3].Volumes = []string{"/statedir"}
+			}),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Validator{AllowedHostPaths: test.allowed}.Validate(test.wf)
+			if test.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestValidatorAllowedRegistries(t *testing.T) {
+	tests := []struct {
+		name          string
+		allowed       []string
+		image         string
+		expectedError string
+	}{
+		{name: "empty allowlist permits everything", image: "quay.io/tinkerbell/disk-wipe"},
+		{name: "implicit docker hub", allowed: []string{"docker.io"}, image: "disk-wipe"},
+		{name: "explicit registry", allowed: []string{"registry.internal:5000"}, image: "registry.internal:5000/disk-wipe:v1"},
+		{name: "registry case", allowed: []string{"Quay.io"}, image: "quay.io/tinkerbell/disk-wipe"},
+		{
+			name:          "disallowed registry",
+			allowed:       []string{"registry.internal:5000"},
+			image:         "quay.io/tinkerbell/disk-wipe",
+			expectedError: "action disk-wipe uses disallowed registry: quay.io",
+		},
+		{
+			name:          "docker hub is not implicitly allowed",
+			allowed:       []string{"registry.internal:5000"},
+			image:         "disk-wipe",
+			expectedError: "action disk-wipe uses disallowed registry: docker.io",
+		},
+		{
+			name:          "port is part of the registry",
+			allowed:       []string{"registry.internal"},
+			image:         "registry.internal:5000/disk-wipe",
+			expectedError: "action disk-wipe uses disallowed registry: registry.internal:5000",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wf := workflow(func(wf *Workflow) {
+				for i := range wf.Tasks[0].Actions {
+					wf.Tasks[0].Actions[i].Image = test.image
+				}
+			})
+			err := Validator{AllowedRegistries: test.allowed}.Validate(wf)
+			if test.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestValidatorForbidLatestTag(t *testing.T) {
+	tests := []struct {
+		name          string
+		image         string
+		expectedError string
+	}{
+		{name: "tagged", image: "quay.io/tinkerbell/disk-wipe:v1"},
+		{name: "mutable tag", image: "quay.io/tinkerbell/disk-wipe:stable"},
+		{name: "digest", image: "quay.io/tinkerbell/disk-wipe@sha256:" + strings.Repeat("a", 64)},
+		{name: "untagged", image: "quay.io/tinkerbell/disk-wipe", expectedError: "action disk-wipe uses forbidden :latest tag"},
+		{name: "latest", image: "disk-wipe:latest", expectedError: "action disk-wipe uses forbidden :latest tag"},
+		{name: "latest with registry port", image: "registry.internal:5000/disk-wipe:latest", expectedError: "action disk-wipe uses forbidden :latest tag"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wf := workflow(func(wf *Workflow) {
+				for i := range wf.Tasks[0].Actions {
+					wf.Tasks[0].Actions[i].Image = test.image
+				}
+			})
+			assert.NoError(t, Validator{}.Validate(wf))
+			err := Validator{ForbidLatestTag: true}.Validate(wf)
+			if test.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestValidatorDNSSafeActionNames(t *testing.T) {
+	tests := []struct {
+		name          string
+		actionName    string
+		expectedError string
+	}{
+		{name: "lowercase and hyphens", actionName: "disk-wipe-2"},
+		{name: "uppercase", actionName: "Disk-Wipe", expectedError: "action name not DNS-safe: Disk-Wipe"},
+		{name: "spaces", actionName: "disk wipe", expectedError: "action name not DNS-safe: disk wipe"},
+		{name: "underscores", actionName: "disk_wipe", expectedError: "action name not DNS-safe: disk_wipe"},
+		{name: "leading hyphen", actionName: "-disk", expectedError: "action name not DNS-safe: -disk"},
+		{name: "trailing hyphen", actionName: "disk-", expectedError: "action name not DNS-safe: disk-"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wf := workflow(func(wf *Workflow) {
+				wf.Tasks[0].Actions[0].Name = test.actionName
+			})
+			assert.NoError(t, Validator{}.Validate(wf), "the default validator only checks the length")
+
+			err := Validator{DNSSafeActionNames: true}.Validate(wf)
+			if test.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestValidateWithWarnings(t *testing.T) {
+	errs, warnings := ValidateWithWarnings(workflow())
+	assert.Empty(t, errs)
+	assert.Empty(t, warnings)
+
+	errs, warnings = ValidateWithWarnings(workflow(withActionNoTimeout()))
+	assert.Empty(t, errs)
+	assert.Equal(t, []string{"action disk-wipe has no timeout and can run forever, consider setting one"}, warnings)
+
+	errs, warnings = ValidateWithWarnings(workflow(withActionNoTimeout(), withActionInvalidImage()))
+	assert.Len(t, errs, 1)
+	assert.Len(t, warnings, 1)
+}
+
+func TestValidatorAllowDuplicateTaskNames(t *testing.T) {
+	v := Validator{AllowDuplicateTaskNames: true}
+
+	errs, warnings := v.ValidateWithWarnings(workflow(withTaskDuplicateName()))
+	assert.Empty(t, errs)
+	assert.Equal(t, []string{"two tasks in a template have the same name: pre-installation, their progress cannot be told apart"}, warnings)
+
+	errs, _ = Validator{}.ValidateWithWarnings(workflow(withTaskDuplicateName()))
+	assert.Len(t, errs, 1)
+
+	assert.Error(t, v.Validate(workflow(withActionDuplicateName())), "duplicate action names remain an error")
+}
+
+func TestValidatorHardwareKeys(t *testing.T) {
+	withDefaults := func(wf *Workflow) {
+		wf.Defaults = map[string]interface{}{"device_1": "08:00:27:00:00:01", "disk": "/dev/sda"}
+	}
+	v := Validator{HardwareKeys: []string{"device_1", "Hardware"}}
+
+	errs, warnings := v.ValidateWithWarnings(workflow(withDefaults))
+	assert.Empty(t, errs)
+	assert.Equal(t, []string{"default device_1 is shadowed by the hardware data key of the same name and is never used"}, warnings)
+
+	_, warnings = Validator{}.ValidateWithWarnings(workflow(withDefaults))
+	assert.Empty(t, warnings)
+}
+
+func TestValidatorGlobalUniqueActionNames(t *testing.T) {
+	withSecondTask := func(actionName string) workflowModifier {
+		return func(wf *Workflow) {
+			wf.Tasks = append(wf.Tasks, Task{
+				Name:       "post-installation",
+				WorkerAddr: "08:00:27:00:00:01",
+				Actions:    []Action{{Name: actionName, Image: "cleanup", Timeout: 60}},
+			})
+		}
+	}
+	v := Validator{GlobalUniqueActionNames: true}
+
+	wf := workflow(withSecondTask("disk-wipe"))
+	assert.NoError(t, Validator{}.Validate(wf), "action names are unique per task by default")
+	assert.EqualError(t, v.Validate(wf), "duplicate action name across tasks: disk-wipe")
+
+	assert.NoError(t, v.Validate(workflow(withSecondTask("cleanup"))))
+	assert.EqualError(t, v.Validate(workflow(withActionDuplicateName())), "two actions in a task cannot have same name: disk-wipe")
+}
+
+func TestValidateCapabilities(t *testing.T) {
+	tests := []struct {
+		name          string
+		capabilities  []string
+		expectedError string
+	}{
+		{name: "none"},
+		{name: "known", capabilities: []string{"CAP_NET_ADMIN", "CAP_SYS_ADMIN"}},
+		{name: "misspelled", capabilities: []string{"CAP_NET_ADMIN", "CAP_NET_ADMN"}, expectedError: "action disk-wipe requests unknown capability: CAP_NET_ADMN"},
+		{name: "without prefix", capabilities: []string{"NET_ADMIN"}, expectedError: "action disk-wipe requests unknown capability: NET_ADMIN"},
+		{name: "lowercase", capabilities: []string{"cap_net_admin"}, expectedError: "action disk-wipe requests unknown capability: cap_net_admin"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validate(workflow(func(wf *Workflow) {
+				wf.Tasks[0].Actions[0].Capabilities = test.capabilities
+			}))
+			if test.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestHasValidUser(t *testing.T) {
+	valid := []string{"root", "1000", "1000:1000", "tink:tink", "_apt", "nobody:65534", "tink-worker"}
+	for _, user := range valid {
+		if !hasValidUser(user) {
+			t.Errorf("expected %q to be a valid user", user)
+		}
+	}
+	invalid := []string{":", "1000:", ":1000", "1000:1000:1000", "Root", "-tink", "tink user", "1000:Admins"}
+	for _, user := range invalid {
+		if hasValidUser(user) {
+			t.Errorf("expected %q to be an invalid user", user)
+		}
+	}
+}
+
 func TestRenderTemplate(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -347,8 +1030,8 @@ tasks:
 				if err == nil {
 					t.Error("expected error, got nil")
 				}
-				if !strings.Contains(err.Error(), `parsing yaml data: yaml: line 2: did not find expected key`) {
-					t.Errorf("\nexpected err: '%s'\ngot:          '%s'", `parsing yaml data: yaml: line 2: did not find expected key`, err)
+				if !strings.Contains(err.Error(), `parsing yaml data: yaml: line 1: did not find expected key`) {
+					t.Errorf("\nexpected err: '%s'\ngot:          '%s'", `parsing yaml data: yaml: line 1: did not find expected key`, err)
 				}
 			},
 		},
@@ -420,6 +1103,413 @@ tasks:
 	}
 }
 
+func TestRenderTemplateHardwareEnvironment(t *testing.T) {
+	templateData := `
+version: "0.1"
+name: test
+global_timeout: 1
+environment:
+  MIRROR_HOST: "{{ .mirror }}"
+  DEST_DISK: /dev/sda
+  VERBOSE: "false"
+tasks:
+  - name: "test"
+    worker: "test"
+    environment:
+      VERBOSE: "true"
+    actions:
+    - name: "default"
+      image: test
+      timeout: 60
+    - name: "override"
+      image: test
+      timeout: 60
+      environment:
+        DEST_DISK: /dev/nvme0n1
+`
+	wf, buf, err := RenderTemplateHardware("test", templateData, map[string]interface{}{"mirror": "192.168.1.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []map[string]string{
+		{"MIRROR_HOST": "192.168.1.2", "DEST_DISK": "/dev/sda"},
+		{"MIRROR_HOST": "192.168.1.2", "DEST_DISK": "/dev/nvme0n1"},
+	}
+	rendered := MustParse(buf.Bytes())
+	for i, env := range expected {
+		if diff := cmp.Diff(env, wf.Tasks[0].Actions[i].Environment); diff != "" {
+			t.Errorf("unexpected environment for action %d (-want +got):\n%s", i, diff)
+		}
+		if diff := cmp.Diff(env, rendered.Tasks[0].Actions[i].Environment); diff != "" {
+			t.Errorf("unexpected rendered environment for action %d (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestRenderTemplateHardwareTimeout(t *testing.T) {
+	templateData := `
+version: "0.1"
+name: test
+global_timeout: 1
+tasks:
+  - name: "test"
+    worker: "{{ .worker }}"
+    actions:
+    - name: "test"
+      image: test
+      timeout: 60
+{{- range .items }}{{ range $.items }}{{ range $.items }}
+# {{ . }}
+{{- end }}{{ end }}{{ end }}
+`
+	items := make([]int, 10000)
+
+	_, _, err := RenderTemplateHardware("test", templateData, map[string]interface{}{"worker": "test", "items": items}, WithRenderTimeout(10*time.Millisecond))
+	if err == nil || !strings.Contains(err.Error(), errTemplateTimeout) {
+		t.Fatalf("expected %q, got %v", errTemplateTimeout, err)
+	}
+
+	wf, _, err := RenderTemplateHardware("test", templateData, map[string]interface{}{"worker": "test", "items": items[:2]}, WithRenderTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "test", wf.Tasks[0].WorkerAddr)
+}
+
+func TestRenderTemplateHardwareMaxSize(t *testing.T) {
+	templateData := `
+version: "0.1"
+name: test
+global_timeout: 1
+tasks:
+  - name: "test"
+    worker: "{{ .worker }}"
+    actions:
+    - name: "test"
+      image: test
+      timeout: 60
+{{- range .items }}
+# {{ . }}
+{{- end }}
+`
+	items := make([]int, 1000)
+
+	_, _, err := RenderTemplateHardware("test", templateData, map[string]interface{}{"worker": "test", "items": items}, WithMaxRenderSize(1024))
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf(errTemplateTooLarge, 1024)) {
+		t.Fatalf("expected %q, got %v", fmt.Sprintf(errTemplateTooLarge, 1024), err)
+	}
+
+	// The size limit also applies to the executions with a timeout.
+	_, _, err = RenderTemplateHardware("test", templateData, map[string]interface{}{"worker": "test", "items": items}, WithMaxRenderSize(1024), WithRenderTimeout(time.Minute))
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf(errTemplateTooLarge, 1024)) {
+		t.Fatalf("expected %q, got %v", fmt.Sprintf(errTemplateTooLarge, 1024), err)
+	}
+
+	// There is no size limit by default.
+	wf, _, err := RenderTemplateHardware("test", templateData, map[string]interface{}{"worker": "test", "items": items})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "test", wf.Tasks[0].WorkerAddr)
+}
+
+func TestRenderTemplateTo(t *testing.T) {
+	hardware := map[string]interface{}{"device_1": "08:00:27:00:00:01"}
+	tests := []struct {
+		name         string
+		templateData string
+		hardware     map[string]interface{}
+		wantErr      string
+	}{
+		{name: "valid", templateData: validTemplate, hardware: hardware},
+		{
+			name:         "environment",
+			templateData: validTemplate + "environment:\n  MIRROR: 192.168.1.2\n",
+			hardware:     hardware,
+		},
+		{
+			name:         "missing hardware key",
+			templateData: validTemplate,
+			hardware:     map[string]interface{}{"device_2": "08:00:27:00:00:01"},
+			wantErr:      `map has no entry for key "device_1"`,
+		},
+		{
+			name:         "empty worker",
+			templateData: validTemplate,
+			hardware:     map[string]interface{}{"device_1": ""},
+			wantErr:      "invalid hardware address",
+		},
+		{
+			name:         "unknown field",
+			templateData: validTemplate + "unknown: true\n",
+			hardware:     hardware,
+			wantErr:      "field unknown not found",
+		},
+		{
+			name:         "invalid",
+			templateData: strings.Replace(validTemplate, "name: hello_world_workflow", `name: ""`, 1),
+			hardware:     hardware,
+			wantErr:      "validating workflow template",
+		},
+		{
+			name:         "duplicate key",
+			templateData: validTemplate + "global_timeout: 300\n",
+			hardware:     hardware,
+			wantErr:      `duplicate key "global_timeout"`,
+		},
+		{
+			name:         "quoted environment",
+			templateData: validTemplate + "\"environment\":\n  MIRROR: 192.168.1.2\n",
+			hardware:     hardware,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			err := RenderTemplateTo(buf, "test", test.templateData, test.hardware)
+
+			_, want, wantErr := RenderTemplateHardware("test", test.templateData, test.hardware)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				assert.EqualError(t, err, wantErr.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, wantErr)
+			assert.Equal(t, want.String(), buf.String())
+		})
+	}
+}
+
+// writeCounter counts the writes of the output.
+type writeCounter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestRenderTemplateToStreams(t *testing.T) {
+	hardware := map[string]interface{}{"device_1": "08:00:27:00:00:01"}
+
+	w := &writeCounter{}
+	assert.NoError(t, RenderTemplateTo(w, "test", validTemplate, hardware))
+	assert.Greater(t, w.writes, 1, "expected the template to be executed into the writer")
+
+	// The environment of the templates defining it is merged before writing.
+	w = &writeCounter{}
+	assert.NoError(t, RenderTemplateTo(w, "test", validTemplate+"environment:\n  MIRROR: 192.168.1.2\n", hardware))
+	assert.Equal(t, 1, w.writes)
+
+	// An environment only known once rendered cannot be merged anymore.
+	withEnvironment := map[string]interface{}{"device_1": "08:00:27:00:00:01", "environment": "environment: {MIRROR: 192.168.1.2}"}
+	err := RenderTemplateTo(&bytes.Buffer{}, "test", validTemplate+"{{ .environment }}\n", withEnvironment)
+	assert.EqualError(t, err, errStreamedEnvironment)
+
+	err = RenderTemplateTo(&bytes.Buffer{}, "test", validTemplate, hardware, WithMaxRenderSize(16))
+	assert.ErrorContains(t, err, fmt.Sprintf(errTemplateTooLarge, 16))
+}
+
+func TestValidateAgainstHardware(t *testing.T) {
+	hardware := map[string]interface{}{"device_1": "08:00:27:00:00:01"}
+	tests := []struct {
+		name          string
+		templateData  string
+		hardware      map[string]interface{}
+		expectedError string
+	}{
+		{name: "valid", templateData: validTemplate, hardware: hardware},
+		{
+			name:          "parse",
+			templateData:  strings.Replace(validTemplate, "{{.device_1}}", "{{.device_1", 1),
+			hardware:      hardware,
+			expectedError: "parsing template: template: workflow-template:",
+		},
+		{
+			name:          "missing key",
+			templateData:  validTemplate,
+			hardware:      map[string]interface{}{"device_2": "08:00:27:00:00:02"},
+			expectedError: `rendering template: template: workflow-template:7:15: executing "workflow-template" at <.device_1>: map has no entry for key "device_1"`,
+		},
+		{
+			name:          "empty worker",
+			templateData:  validTemplate,
+			hardware:      map[string]interface{}{"device_1": ""},
+			expectedError: "validating rendered workflow: failed to render template, invalid hardware address: map[device_1:]",
+		},
+		{
+			name:          "invalid workflow",
+			templateData:  strings.Replace(validTemplate, "hello-world", "{{ .image }}", 1),
+			hardware:      map[string]interface{}{"device_1": "08:00:27:00:00:01", "image": "Hello World"},
+			expectedError: "validating rendered workflow: invalid action image: Hello World",
+		},
+		{
+			name:          "invalid yaml",
+			templateData:  strings.Replace(validTemplate, "timeout: 60", "timeout: {{ .timeout }}", 1),
+			hardware:      map[string]interface{}{"device_1": "08:00:27:00:00:01", "timeout": "[60"},
+			expectedError: "validating rendered workflow: parsing yaml data:",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateAgainstHardware(test.templateData, test.hardware)
+			if test.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestRenderTemplateWithVars(t *testing.T) {
+	templateData := `
+version: "0.1"
+name: release
+global_timeout: 600
+tasks:
+  - name: "install"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "install"
+      image: "quay.io/tinkerbell/installer:{{ .Vars.ReleaseTag }}"
+      timeout: 60
+`
+	hardware := map[string]interface{}{"device_1": "08:00:27:00:00:01"}
+
+	out, err := RenderTemplateWithVars("release", templateData, hardware, map[string]interface{}{"ReleaseTag": "v1.2.3"})
+	assert.NoError(t, err)
+	assert.Contains(t, out, `image: "quay.io/tinkerbell/installer:v1.2.3"`)
+	assert.NotContains(t, hardware, "Vars", "the hardware data is not modified")
+
+	_, err = RenderTemplateWithVars("release", templateData, hardware, nil)
+	assert.ErrorContains(t, err, `map has no entry for key "ReleaseTag"`)
+
+	_, err = RenderTemplateWithVars("release", templateData, map[string]interface{}{"device_1": "08:00:27:00:00:01", "Vars": "x"}, nil)
+	assert.EqualError(t, err, "hardware data key Vars collides with the template variables")
+}
+
+func TestRenderTemplateDisallowDuplicateWorkers(t *testing.T) {
+	templateData := `
+version: "0.1"
+name: cluster
+global_timeout: 600
+tasks:
+  - name: "control-plane"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "install"
+      image: installer
+      timeout: 60
+  - name: "worker"
+    worker: "{{.device_2}}"
+    actions:
+    - name: "install"
+      image: installer
+      timeout: 60
+`
+	distinct := map[string]interface{}{"device_1": "08:00:27:00:00:01", "device_2": "08:00:27:00:00:02"}
+	same := map[string]interface{}{"device_1": "08:00:27:00:00:01", "device_2": "08:00:27:00:00:01"}
+	v := WithValidator(Validator{DisallowDuplicateWorkers: true})
+
+	_, _, err := RenderTemplateHardware("cluster", templateData, distinct, v)
+	assert.NoError(t, err)
+
+	_, _, err = RenderTemplateHardware("cluster", templateData, same, v)
+	assert.EqualError(t, err, "tasks control-plane and worker target the same worker: 08:00:27:00:00:01")
+	err = RenderTemplateTo(io.Discard, "cluster", templateData, same, v)
+	assert.EqualError(t, err, "tasks control-plane and worker target the same worker: 08:00:27:00:00:01")
+
+	_, _, err = RenderTemplateHardware("cluster", templateData, same)
+	assert.NoError(t, err, "duplicate workers are allowed by default")
+}
+
+func TestRenderTemplatePartials(t *testing.T) {
+	templateData := `
+{{- define "wipe" }}
+    - name: "wipe-{{ . }}"
+      image: disk-wipe
+      timeout: 90
+{{- end }}
+version: "0.1"
+name: partials
+global_timeout: 600
+tasks:
+  - name: "wipe-disks"
+    worker: "{{.device_1}}"
+    actions:
+{{- range .disks }}
+{{- template "%s" . }}
+{{- end }}
+`
+	hardware := map[string]interface{}{"device_1": "08:00:27:00:00:01", "disks": []interface{}{"sda", "sdb"}}
+
+	wf, _, err := RenderTemplateHardware("partials", fmt.Sprintf(templateData, "wipe"), hardware)
+	if assert.NoError(t, err) && assert.Len(t, wf.Tasks[0].Actions, 2) {
+		assert.Equal(t, "wipe-sdb", wf.Tasks[0].Actions[1].Name)
+	}
+
+	// The missing partial is reported even when it is never invoked.
+	_, _, err = RenderTemplateHardware("partials", fmt.Sprintf(templateData, "wipe-disk"), map[string]interface{}{"device_1": "08:00:27:00:00:01"})
+	assert.EqualError(t, err, "failed to parse template with ID partials: template references undefined partial: wipe-disk")
+	err = ValidateAgainstHardware(fmt.Sprintf(templateData, "wipe-disk"), hardware)
+	assert.ErrorContains(t, err, "template references undefined partial: wipe-disk")
+}
+
+func TestRenderTemplateFailOnEmptyValue(t *testing.T) {
+	templateData := `
+version: "0.1"
+name: os-install
+global_timeout: 600
+tasks:
+  - name: "install"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "stream-image"
+      image: image2disk
+      timeout: 60
+      command: ["--dest", "{{ .disk }}", "--url", "{{ if .image_url }}{{ .image_url }}{{ else }}http://images/os.raw{{ end }}", "--label", "{{ .image_url | printf "url=%s" }}"]
+{{- range .extra }}
+    - name: "{{ .name }}"
+      image: "{{ .image }}"
+      timeout: 60
+{{- end }}
+`
+	v := WithValidator(Validator{FailOnEmptyValue: true})
+	hardware := func(modify func(map[string]interface{})) map[string]interface{} {
+		hw := map[string]interface{}{
+			"device_1":  "08:00:27:00:00:01",
+			"disk":      "/dev/sda",
+			"image_url": "",
+			"extra":     []interface{}{map[string]interface{}{"name": "reboot", "image": "reboot-action"}},
+		}
+		modify(hw)
+		return hw
+	}
+
+	_, _, err := RenderTemplateHardware("os-install", templateData, hardware(func(map[string]interface{}) {}), v)
+	assert.NoError(t, err, "the empty fields which are not printed are allowed")
+
+	_, _, err = RenderTemplateHardware("os-install", templateData, hardware(func(hw map[string]interface{}) { hw["disk"] = "" }), v)
+	assert.ErrorContains(t, err, ".disk renders to an empty value")
+	err = RenderTemplateTo(io.Discard, "os-install", templateData, hardware(func(hw map[string]interface{}) { hw["disk"] = "" }), v)
+	assert.ErrorContains(t, err, ".disk renders to an empty value")
+
+	_, _, err = RenderTemplateHardware("os-install", templateData, hardware(func(hw map[string]interface{}) {
+		hw["extra"] = []interface{}{map[string]interface{}{"name": "reboot", "image": ""}}
+	}), v)
+	assert.ErrorContains(t, err, ".image renders to an empty value")
+
+	wf, _, err := RenderTemplateHardware("os-install", templateData, hardware(func(hw map[string]interface{}) { hw["disk"] = "" }))
+	if assert.NoError(t, err, "empty values are allowed by default") {
+		assert.Equal(t, []string{"--dest", "", "--url", "http://images/os.raw", "--label", "url="}, wf.Tasks[0].Actions[0].Command)
+	}
+}
+
 func TestRenderTemplateHardwareCustomFuncs(t *testing.T) {
 	cases := []struct {
 		name         string
@@ -838,8 +1928,26 @@ func withTaskDuplicateName() workflowModifier {
 	return func(wf *Workflow) { wf.Tasks = append(wf.Tasks, wf.Tasks[0]) }
 }
 
+func withTaskEmptyWorker() workflowModifier {
+	return func(wf *Workflow) { wf.Tasks[0].WorkerAddr = "" }
+}
+
+func withTaskNoActions() workflowModifier {
+	return func(wf *Workflow) {
+		wf.Tasks = append(wf.Tasks, Task{Name: "post-installation", WorkerAddr: wf.Tasks[0].WorkerAddr, Actions: []Action{}})
+	}
+}
+
 // invalid action modifiers
 
+func withActionInvalidNetwork() workflowModifier {
+	return func(wf *Workflow) { wf.Tasks[0].Actions[0].Network = "hostt" }
+}
+
+func withActionUnknownCapability() workflowModifier {
+	return func(wf *Workflow) { wf.Tasks[0].Actions[0].Capabilities = []string{"CAP_NET_ADMIN", "CAP_NET_ADMN"} }
+}
+
 func withActionInvalidName() workflowModifier {
 	return func(wf *Workflow) { wf.Tasks[0].Actions[0].Name = "" }
 }
@@ -854,6 +1962,22 @@ func withActionDuplicateName() workflowModifier {
 	return func(wf *Workflow) { wf.Tasks[0].Actions = append(wf.Tasks[0].Actions, wf.Tasks[0].Actions[0]) }
 }
 
+func withActionInvalidEnvironment() workflowModifier {
+	return func(wf *Workflow) { wf.Tasks[0].Actions[0].Environment = map[string]string{"DEST-DISK": "/dev/sda"} }
+}
+
+func withActionInvalidUser() workflowModifier {
+	return func(wf *Workflow) { wf.Tasks[0].Actions[0].User = "1000:Admins" }
+}
+
+func withActionRelativeWorkingDir() workflowModifier {
+	return func(wf *Workflow) { wf.Tasks[0].Actions[0].WorkingDir = "statedir" }
+}
+
+func withActionNoTimeout() workflowModifier {
+	return func(wf *Workflow) { wf.Tasks[0].Actions[0].Timeout = 0 }
+}
+
 func withActionInvalidImage() workflowModifier {
 	return func(wf *Workflow) { wf.Tasks[0].Actions[0].Image = "action-image-with-$#@-" }
 }
@@ -872,10 +1996,14 @@ func withTemplateLongName() workflowModifier {
 
 func withTemplateInvalidVersion() workflowModifier {
 	return func(wf *Workflow) {
-		wf.Version = "0.2"
+		wf.Version = "0.3"
 	}
 }
 
+func withTemplateInvalidEnvironment() workflowModifier {
+	return func(wf *Workflow) { wf.Environment = map[string]string{"1MIRROR": "192.168.1.2"} }
+}
+
 func withTemplateNilTasks() workflowModifier {
 	return func(wf *Workflow) {
 		wf.Tasks = nil
@@ -887,3 +2015,59 @@ func withTemplateEmptyTasks() workflowModifier {
 		wf.Tasks = []Task{}
 	}
 }
+
+func TestRenderTemplatePretty(t *testing.T) {
+	templateData := `
+version: "0.1"
+name: pretty
+global_timeout: 1800
+tasks:
+  - name: "os-installation"
+    worker: "{{.device_1}}"
+    volumes:
+         - /dev:/dev
+    actions:
+        - name: "disk-wipe"
+          image: disk-wipe
+          timeout: 90
+          environment:
+               {{- range $i, $disk := .disks }}
+               DISK_{{ $i }}: {{ $disk }}
+               {{- end }}
+`
+	devices := []byte(`{"device_1": "08:00:27:00:00:01", "disks": ["/dev/sda", "/dev/sdb"]}`)
+
+	raw, err := RenderTemplate("pretty", templateData, devices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pretty, err := RenderTemplatePretty("pretty", templateData, devices)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(MustParse([]byte(raw)), MustParse([]byte(pretty))); diff != "" {
+		t.Errorf("the pretty render differs from the raw one (-raw +pretty):\n%s", diff)
+	}
+	expected := `version: "0.1"
+name: pretty
+id: ""
+global_timeout: 1800
+tasks:
+- name: os-installation
+  worker: "08:00:27:00:00:01"
+  actions:
+  - name: disk-wipe
+    image: disk-wipe
+    timeout: 90
+    environment:
+      DISK_0: /dev/sda
+      DISK_1: /dev/sdb
+  volumes:
+  - /dev:/dev
+`
+	assert.Equal(t, expected, pretty)
+
+	_, err = RenderTemplatePretty("pretty", templateData, []byte(`{}`))
+	assert.Error(t, err)
+}
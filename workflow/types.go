@@ -2,11 +2,13 @@ package workflow
 
 // Workflow represents a workflow to be executed.
 type Workflow struct {
-	Version       string `yaml:"version"`
-	Name          string `yaml:"name"`
-	ID            string `yaml:"id"`
-	GlobalTimeout int    `yaml:"global_timeout"`
-	Tasks         []Task `yaml:"tasks"`
+	Version       string                 `yaml:"version"`
+	Name          string                 `yaml:"name"`
+	ID            string                 `yaml:"id"`
+	GlobalTimeout int                    `yaml:"global_timeout"`
+	Tasks         []Task                 `yaml:"tasks"`
+	Environment   map[string]string      `yaml:"environment,omitempty"`
+	Defaults      map[string]interface{} `yaml:"defaults,omitempty"`
 }
 
 // Task represents a task to be executed as part of a workflow.
@@ -29,4 +31,88 @@ type Action struct {
 	Volumes     []string          `yaml:"volumes,omitempty"`
 	Environment map[string]string `yaml:"environment,omitempty"`
 	Pid         string            `yaml:"pid,omitempty"`
+	User        string            `yaml:"user,omitempty"`
+	WorkingDir  string            `yaml:"workingDir,omitempty"`
+	Network     string            `yaml:"network,omitempty"`
+	// Capabilities are the Linux capabilities added to the action container,
+	// among KnownCapabilities.
+	Capabilities []string `yaml:"capabilities,omitempty"`
+	// IgnoreFailure lets the workflow go on when the action fails, for
+	// cleanup actions. It requires the template version 0.2.
+	IgnoreFailure bool `yaml:"ignoreFailure,omitempty"`
+	// Files are written by the worker before the action runs, for the small
+	// configuration files not worth a volume. They require the template
+	// version 0.2.
+	Files []File `yaml:"files,omitempty"`
+}
+
+// File is a file written by the worker for an action.
+type File struct {
+	// Path is the absolute path of the file.
+	Path    string `yaml:"path"`
+	Content string `yaml:"content"`
+}
+
+// The template versions accepted by the validation. Version 0.2 adds the
+// ignoreFailure and files fields of the actions.
+const (
+	TemplateVersion01 = "0.1"
+	TemplateVersion02 = "0.2"
+)
+
+// The network modes of an action, besides the network of another container
+// and custom networks.
+const (
+	NetworkModeHost   = "host"
+	NetworkModeNone   = "none"
+	NetworkModeBridge = "bridge"
+)
+
+// networkModes are the network modes accepted by the validation.
+var networkModes = []string{NetworkModeHost, NetworkModeNone, NetworkModeBridge}
+
+// KnownCapabilities are the Linux capabilities an action can request. New
+// capabilities are added to the set as the kernel introduces them.
+var KnownCapabilities = map[string]struct{}{
+	"CAP_AUDIT_CONTROL":      {},
+	"CAP_AUDIT_READ":         {},
+	"CAP_AUDIT_WRITE":        {},
+	"CAP_BLOCK_SUSPEND":      {},
+	"CAP_BPF":                {},
+	"CAP_CHECKPOINT_RESTORE": {},
+	"CAP_CHOWN":              {},
+	"CAP_DAC_OVERRIDE":       {},
+	"CAP_DAC_READ_SEARCH":    {},
+	"CAP_FOWNER":             {},
+	"CAP_FSETID":             {},
+	"CAP_IPC_LOCK":           {},
+	"CAP_IPC_OWNER":          {},
+	"CAP_KILL":               {},
+	"CAP_LEASE":              {},
+	"CAP_LINUX_IMMUTABLE":    {},
+	"CAP_MAC_ADMIN":          {},
+	"CAP_MAC_OVERRIDE":       {},
+	"CAP_MKNOD":              {},
+	"CAP_NET_ADMIN":          {},
+	"CAP_NET_BIND_SERVICE":   {},
+	"CAP_NET_BROADCAST":      {},
+	"CAP_NET_RAW":            {},
+	"CAP_PERFMON":            {},
+	"CAP_SETFCAP":            {},
+	"CAP_SETGID":             {},
+	"CAP_SETPCAP":            {},
+	"CAP_SETUID":             {},
+	"CAP_SYSLOG":             {},
+	"CAP_SYS_ADMIN":          {},
+	"CAP_SYS_BOOT":           {},
+	"CAP_SYS_CHROOT":         {},
+	"CAP_SYS_MODULE":         {},
+	"CAP_SYS_NICE":           {},
+	"CAP_SYS_PACCT":          {},
+	"CAP_SYS_PTRACE":         {},
+	"CAP_SYS_RAWIO":          {},
+	"CAP_SYS_RESOURCE":       {},
+	"CAP_SYS_TIME":           {},
+	"CAP_SYS_TTY_CONFIG":     {},
+	"CAP_WAKE_ALARM":         {},
 }
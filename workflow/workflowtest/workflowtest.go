@@ -0,0 +1,114 @@
+// Package workflowtest provides helpers to unit test workflow templates
+// without a tink server.
+package workflowtest
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/tink/workflow"
+	"gopkg.in/yaml.v2"
+)
+
+// missingKeyRegexp extracts the key from the error of a template referencing
+// a key missing from the hardware data.
+var missingKeyRegexp = regexp.MustCompile(`map has no entry for key "([^"]*)"`)
+
+// RenderResult is the outcome of rendering a template with Render.
+type RenderResult struct {
+	t testing.TB
+
+	// Workflow is the rendered workflow, nil when the rendering failed.
+	Workflow *workflow.Workflow
+	// YAML is the rendered template, empty when the rendering failed.
+	YAML string
+	// Err is the rendering error.
+	Err error
+}
+
+// Render renders the template with the hardware data. A rendering error is
+// reported as a test failure, naming the missing key when the template
+// references a key the hardware data does not have. Use RenderError for the
+// templates expected to fail.
+func Render(t testing.TB, templateData string, hardware map[string]interface{}) *RenderResult {
+	t.Helper()
+	r := RenderError(t, templateData, hardware)
+	if r.Err != nil {
+		if m := missingKeyRegexp.FindStringSubmatch(r.Err.Error()); m != nil {
+			t.Errorf("template references key %q which is missing from the hardware data: %v", m[1], r.Err)
+		} else {
+			t.Errorf("failed to render the template: %v", r.Err)
+		}
+	}
+	return r
+}
+
+// RenderError renders the template with the hardware data without reporting
+// the rendering error, which is available in the result.
+func RenderError(t testing.TB, templateData string, hardware map[string]interface{}) *RenderResult {
+	t.Helper()
+	wf, buf, err := workflow.RenderTemplateHardware("workflowtest", templateData, hardware)
+	r := &RenderResult{t: t, Workflow: wf, Err: err}
+	if err == nil {
+		r.YAML = buf.String()
+	}
+	return r
+}
+
+// TestRender renders the template with the hardware data and checks that
+// the result matches the expected YAML, see AssertYAML.
+func TestRender(t testing.TB, templateData string, hardware map[string]interface{}, want string) {
+	t.Helper()
+	Render(t, templateData, hardware).AssertYAML(want)
+}
+
+// AssertYAML checks that the rendered workflow matches the expected YAML.
+// Both are compared once decoded, so formatting, quoting and key order do not
+// matter, and the differences are reported as a diff of the canonical YAML.
+func (r *RenderResult) AssertYAML(want string) {
+	r.t.Helper()
+	if r.Err != nil {
+		return
+	}
+	got, err := canonicalYAML(r.YAML)
+	if err != nil {
+		r.t.Errorf("rendered workflow is not valid YAML: %v", err)
+		return
+	}
+	expected, err := canonicalYAML(want)
+	if err != nil {
+		r.t.Errorf("expected workflow is not valid YAML: %v", err)
+		return
+	}
+	if diff := cmp.Diff(strings.Split(expected, "\n"), strings.Split(got, "\n")); diff != "" {
+		r.t.Errorf("unexpected rendered workflow (-want +got):\n%s", diff)
+	}
+}
+
+// AssertError checks that the rendering failed with an error containing msg.
+func (r *RenderResult) AssertError(msg string) {
+	r.t.Helper()
+	if r.Err == nil {
+		r.t.Errorf("expected the rendering to fail with %q, it succeeded", msg)
+		return
+	}
+	if !strings.Contains(r.Err.Error(), msg) {
+		r.t.Errorf("expected the rendering to fail with %q, got: %v", msg, r.Err)
+	}
+}
+
+// canonicalYAML decodes and encodes the YAML document, which sorts the keys
+// and normalizes the formatting.
+func canonicalYAML(doc string) (string, error) {
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(doc), &v); err != nil {
+		return "", err
+	}
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
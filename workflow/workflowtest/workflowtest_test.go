@@ -0,0 +1,72 @@
+package workflowtest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recorder is a testing.TB recording the failures instead of failing.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+const helloWorld = `
+version: "0.1"
+name: hello_world_workflow
+global_timeout: 600
+tasks:
+  - name: "hello world"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "hello_world"
+      image: hello-world
+      timeout: 60
+`
+
+func TestTestRender(t *testing.T) {
+	hardware := map[string]interface{}{"device_1": "08:00:27:00:00:01"}
+
+	TestRender(t, helloWorld, hardware, `
+name: hello_world_workflow
+version: "0.1"
+global_timeout: 600
+tasks:
+- name: hello world
+  worker: "08:00:27:00:00:01"
+  actions:
+  - {name: hello_world, image: hello-world, timeout: 60}
+`)
+
+	r := &recorder{TB: t}
+	TestRender(r, helloWorld, hardware, strings.Replace(helloWorld, "{{.device_1}}", "08:00:27:00:00:02", 1))
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], `-want +got`) || !strings.Contains(r.errors[0], `08:00:27:00:00:02`) {
+		t.Errorf("expected a diff of the worker, got %q", r.errors)
+	}
+}
+
+func TestRenderMissingKey(t *testing.T) {
+	r := &recorder{TB: t}
+	res := Render(r, helloWorld, map[string]interface{}{"device_2": "08:00:27:00:00:01"})
+	res.AssertYAML(helloWorld)
+	if len(r.errors) != 1 || !strings.HasPrefix(r.errors[0], `template references key "device_1" which is missing from the hardware data`) {
+		t.Errorf("expected a single missing key failure, got %q", r.errors)
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	RenderError(t, helloWorld, map[string]interface{}{}).AssertError(`no entry for key "device_1"`)
+
+	r := &recorder{TB: t}
+	RenderError(r, helloWorld, map[string]interface{}{"device_1": "08:00:27:00:00:01"}).AssertError("anything")
+	if len(r.errors) != 1 {
+		t.Errorf("expected a failure when the rendering succeeds, got %q", r.errors)
+	}
+}